@@ -0,0 +1,91 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Alpha quantization range, 0 keeps alpha as is
+const (
+	alphaMinLevels = 2
+	alphaMaxLevels = 256
+)
+
+// checkAlphaLevels returns an error for a level count outside the quantization range
+func checkAlphaLevels(levels int) error {
+	if levels != 0 && (levels < alphaMinLevels || levels > alphaMaxLevels) {
+		return fmt.Errorf("alpha levels %d is out of range %d-%d", levels, alphaMinLevels, alphaMaxLevels)
+	}
+	return nil
+}
+
+// quantizeAlpha reduces the alpha channel to evenly spaced levels. heifsave has no alpha
+// quality option, so fewer levels are what makes the alpha plane of lossy AVIF smaller.
+// Zero levels keeps alpha as is.
+func quantizeAlpha(img *vips.ImageRef, levels int) error {
+	if levels == 0 || !img.HasAlpha() {
+		return nil
+	}
+
+	lut, err := alphaLUT(levels, img.BandFormat() == vips.BandFormatUshort)
+	if err != nil {
+		return err
+	}
+	defer lut.Close()
+
+	alpha, err := img.Copy()
+	if err != nil {
+		return err
+	}
+	defer alpha.Close()
+
+	bands := img.Bands()
+	if err := alpha.ExtractBand(bands-1, 1); err != nil {
+		return err
+	}
+	if err := alpha.Maplut(lut); err != nil {
+		return err
+	}
+	if err := img.ExtractBand(0, bands-1); err != nil {
+		return err
+	}
+	return img.BandJoin(alpha)
+}
+
+// quantizeAlphaValue maps an alpha value to the nearest of levels evenly spaced from 0 to maxValue
+func quantizeAlphaValue(v, maxValue, levels int) int {
+	step := float64(maxValue) / float64(levels-1)
+	return int(math.Round(math.Round(float64(v)/step) * step))
+}
+
+// alphaLUT builds a lookup table covering every 8 or 16-bit alpha value. It is built in Go
+// and loaded as a PNG so the rounding doesn't depend on libvips casting rules.
+func alphaLUT(levels int, sixteenBit bool) (*vips.ImageRef, error) {
+	var lut image.Image
+	if sixteenBit {
+		gray := image.NewGray16(image.Rect(0, 0, math.MaxUint16+1, 1))
+		for v := 0; v <= math.MaxUint16; v++ {
+			gray.SetGray16(v, 0, color.Gray16{Y: uint16(quantizeAlphaValue(v, math.MaxUint16, levels))})
+		}
+		lut = gray
+	} else {
+		gray := image.NewGray(image.Rect(0, 0, math.MaxUint8+1, 1))
+		for v := 0; v <= math.MaxUint8; v++ {
+			gray.SetGray(v, 0, color.Gray{Y: uint8(quantizeAlphaValue(v, math.MaxUint8, levels))})
+		}
+		lut = gray
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, lut); err != nil {
+		return nil, err
+	}
+
+	return vips.NewImageFromBuffer(buf.Bytes())
+}
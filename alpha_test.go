@@ -0,0 +1,78 @@
+package nextgenimage
+
+import (
+	"testing"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+func TestCheckAlphaLevels(t *testing.T) {
+	for _, levels := range []int{0, 2, 16, 256} {
+		if err := checkAlphaLevels(levels); err != nil {
+			t.Errorf("Unexpected error for %d levels: %v", levels, err)
+		}
+	}
+	for _, levels := range []int{1, 257, -1} {
+		if err := checkAlphaLevels(levels); err == nil {
+			t.Errorf("Expected an error for %d levels", levels)
+		}
+	}
+}
+
+func TestQuantizeAlphaValue(t *testing.T) {
+	tests := []struct {
+		v, maxValue, levels, expected int
+	}{
+		{0, 255, 2, 0},
+		{127, 255, 2, 0},
+		{128, 255, 2, 255},
+		{255, 255, 2, 255},
+		{100, 255, 3, 128},
+		{200, 255, 256, 200},
+		{40000, 65535, 2, 65535},
+	}
+	for _, tt := range tests {
+		if got := quantizeAlphaValue(tt.v, tt.maxValue, tt.levels); got != tt.expected {
+			t.Errorf("quantizeAlphaValue(%d, %d, %d) = %d, expected %d", tt.v, tt.maxValue, tt.levels, got, tt.expected)
+		}
+	}
+}
+
+func TestQuantizeAlpha(t *testing.T) {
+	img, err := vips.NewImageFromFile("testdata/png/alpha_semitransparent.png")
+	if err != nil {
+		t.Skip("Test file not available:", err)
+	}
+	defer img.Close()
+
+	if !img.HasAlpha() {
+		t.Skip("Test image has no alpha channel")
+	}
+	bands := img.Bands()
+
+	if err := quantizeAlpha(img, 2); err != nil {
+		t.Fatalf("quantizeAlpha failed: %v", err)
+	}
+	if img.Bands() != bands {
+		t.Errorf("Expected %d bands, got %d", bands, img.Bands())
+	}
+
+	// Two levels leave the alpha band fully transparent or opaque
+	alpha, err := img.Copy()
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	defer alpha.Close()
+	if err := alpha.ExtractBand(bands-1, 1); err != nil {
+		t.Fatalf("ExtractBand failed: %v", err)
+	}
+	pixels, err := alpha.ToBytes()
+	if err != nil {
+		t.Fatalf("ToBytes failed: %v", err)
+	}
+	for i, v := range pixels {
+		if v != 0 && v != 255 {
+			t.Fatalf("Pixel %d has alpha %d after quantizing to two levels", i, v)
+		}
+	}
+}
@@ -1,93 +1,331 @@
 package nextgenimage
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/davidbyttow/govips/v2/vips"
 )
 
 // ToAVIF converts an image to AVIF format
-func (c *Converter) ToAVIF(inputPath, outputPath string) error {
-	// Check input file
-	inputInfo, err := os.Stat(inputPath)
+func (c *Converter) ToAVIF(inputPath, outputPath string) (*ConversionResult, error) {
+	return c.ToAVIFContext(context.Background(), inputPath, outputPath)
+}
+
+// ToAVIFContext converts an image to AVIF format, aborting when ctx is done.
+// On cancellation or deadline it returns ctx.Err() and leaves no file at outputPath.
+// The conversion stops at its next context check, and a libvips call already running
+// finishes in the background first.
+func (c *Converter) ToAVIFContext(ctx context.Context, inputPath, outputPath string) (*ConversionResult, error) {
+	// Read input file
+	inputBuffer, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	outputBuffer, result, err := c.ToAVIFBytesContext(ctx, inputBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeOutputFile(ctx, outputPath, outputBuffer); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ToAVIFStream reads an image from r and writes the AVIF encoded result to w.
+// Nothing is written to w when the conversion fails.
+func (c *Converter) ToAVIFStream(r io.Reader, w io.Writer) error {
+	_, err := c.ToAVIFStreamContext(context.Background(), r, w)
+	return err
+}
+
+// ToAVIFStreamContext is ToAVIFStream aborting when ctx is done, returning the same
+// ConversionResult as ToAVIFContext
+func (c *Converter) ToAVIFStreamContext(ctx context.Context, r io.Reader, w io.Writer) (*ConversionResult, error) {
+	inputBuffer, err := io.ReadAll(r)
 	if err != nil {
-		return fmt.Errorf("failed to stat input file: %w", err)
+		return nil, fmt.Errorf("failed to read input: %w", err)
 	}
 
-	// Load image
-	image, err := vips.NewImageFromFile(inputPath)
+	outputBuffer, result, err := c.ToAVIFBytesContext(ctx, inputBuffer)
 	if err != nil {
-		return fmt.Errorf("failed to load image: %w", NewFormatError(err))
+		return nil, err
+	}
+
+	if _, err := w.Write(outputBuffer); err != nil {
+		return nil, fmt.Errorf("failed to write output: %w", err)
 	}
-	defer image.Close()
 
-	// Auto-rotate based on EXIF orientation
-	err = image.AutoRotate()
+	return result, nil
+}
+
+// ToAVIFBytes converts an in-memory image to AVIF format and returns the encoded bytes
+func (c *Converter) ToAVIFBytes(inputBuffer []byte) ([]byte, error) {
+	outputBuffer, _, err := c.ToAVIFBytesContext(context.Background(), inputBuffer)
+	return outputBuffer, err
+}
+
+// ToAVIFBytesContext is ToAVIFBytes aborting when ctx is done, returning the same
+// ConversionResult as ToAVIFContext
+func (c *Converter) ToAVIFBytesContext(ctx context.Context, inputBuffer []byte) ([]byte, *ConversionResult, error) {
+	var result *ConversionResult
+	outputBuffer, err := runWithContext(ctx, func() ([]byte, error) {
+		outputBuffer, r, err := c.toAVIF(ctx, inputBuffer)
+		result = r
+		return outputBuffer, err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to auto-rotate: %w", NewFormatError(err))
+		return nil, nil, err
 	}
+	return outputBuffer, result, nil
+}
+
+// toAVIF runs the AVIF conversion pipeline, checking ctx between the expensive steps
+func (c *Converter) toAVIF(ctx context.Context, inputBuffer []byte) ([]byte, *ConversionResult, error) {
+	start := time.Now()
 
-	// Detect input format using magic bytes
-	imgType, err := DetectImageType(inputPath)
+	input, err := c.decodeInput(ctx, inputBuffer)
 	if err != nil {
-		return fmt.Errorf("failed to detect image type: %w", err)
+		return nil, nil, err
 	}
+	defer input.Close()
 
-	if !imgType.IsSupported() {
-		return fmt.Errorf("unsupported image format: %s", imgType)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
+	return c.encodeAVIF(ctx, input, start)
+}
 
-	// GIF to AVIF is not supported
-	if imgType == ImageTypeGIF {
-		return NewFormatError(fmt.Errorf("GIF to AVIF conversion is not supported"))
+// encodeAVIF encodes a decoded input as AVIF
+func (c *Converter) encodeAVIF(ctx context.Context, input *decodedInput, start time.Time) ([]byte, *ConversionResult, error) {
+	inputBuffer, imgType, image := input.buf, input.imgType, input.image
+	stripMetadata := input.stripMetadata
+
+	result := &ConversionResult{
+		InputType:    imgType,
+		OutputFormat: ImageTypeAVIF,
+		InputSize:    int64(len(inputBuffer)),
+		Width:        image.Width(),
+		Height:       image.Height(),
+		Frames:       1,
+	}
+	if err := checkDimensions(result.Width, result.Height, avifMaxDimension); err != nil {
+		return nil, nil, err
 	}
 
-	var params *vips.AvifExportParams
+	var params *avifExportParams
 	var outputBuffer []byte
+	var err error
 
 	switch imgType {
 	case ImageTypeJPEG:
 		// JPEG to AVIF: lossy conversion with CQ
-		params = vips.NewAvifExportParams()
+		params = newAVIFExportParams()
 		params.Quality = c.config.JPEGToAVIF.CQ
 		params.Lossless = false
-		params.StripMetadata = true // Strip all metadata during export
+		params.StripMetadata = stripMetadata
+		if err := c.config.JPEGToAVIF.apply(params); err != nil {
+			return nil, nil, err
+		}
 
-		outputBuffer, _, err = image.ExportAvif(params)
+		var cq int
+		outputBuffer, cq, result.SSIM, err = c.jpegToAVIF(ctx, input, params)
 		if err != nil {
-			return fmt.Errorf("failed to export avif: %w", NewFormatError(err))
+			return nil, nil, err
 		}
+		result.Mode = EncoderModeLossy
+		result.Quality = cq
 
 	case ImageTypePNG:
-		// PNG to AVIF: lossless conversion
-		params = vips.NewAvifExportParams()
-		params.Lossless = true
-		params.StripMetadata = true // Strip all metadata during export
+		// PNG to AVIF: lossless by default, optionally lossy
+		params = newAVIFExportParams()
+		params.StripMetadata = stripMetadata
+		if err := c.config.PNGToAVIF.apply(params); err != nil {
+			return nil, nil, err
+		}
+
+		encoding, err := c.pngToAVIF(ctx, image, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		outputBuffer = encoding.buf
+		result.Mode = encoding.mode
+		result.Quality = encoding.quality
+		result.SSIM = encoding.ssim
+		result.ContentClass = encoding.class
+
+	case ImageTypeGIF:
+		// GIF to AVIF: lossy conversion with CQ, palette images gain nothing from lossless AV1
+		params = newAVIFExportParams()
+		params.Quality = c.config.GIFToAVIF.CQ
+		params.Lossless = false
+		params.StripMetadata = stripMetadata
+		if err := c.config.GIFToAVIF.apply(params); err != nil {
+			return nil, nil, err
+		}
+
+		anim, err := parseGIFAnimation(inputBuffer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse gif animation: %w", newKindError(ErrDecodeFailed, err))
+		}
+
+		var cq int
+		outputBuffer, cq, result.SSIM, err = c.gifToAVIF(ctx, input, anim, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		if anim.frames > 1 {
+			result.Frames = anim.frames
+		}
+		result.Mode = EncoderModeLossy
+		result.Quality = cq
 
-		outputBuffer, _, err = image.ExportAvif(params)
+	}
+
+	// Check if output saves enough over the input
+	if err := c.checkSavings(len(inputBuffer), len(outputBuffer)); err != nil {
+		return nil, nil, err
+	}
+	if err := c.checkTargetSize(outputBuffer); err != nil {
+		return nil, nil, err
+	}
+
+	result.SourceQuality = input.quality
+	result.SourceSubsampling = input.subsampling
+	result.Subsampling = outputSubsampling(ImageTypeAVIF, result.Mode, result.Quality, params.chroma)
+	result.OutputSize = int64(len(outputBuffer))
+	result.Elapsed = time.Since(start)
+
+	return outputBuffer, result, nil
+}
+
+// jpegAVIFMaxCQ returns the highest CQ a JPEG may be encoded at
+func (c *Converter) jpegAVIFMaxCQ(input *decodedInput) int {
+	if c.config.JPEGToAVIF.AdaptiveQuality && input.quality > 0 {
+		return adaptiveQualityCap(input.quality, avifMaxQuality)
+	}
+	return avifMaxQuality
+}
+
+// jpegAVIFChroma returns the chroma subsampling a JPEG is encoded with. An explicit setting
+// wins over the subsampling policy.
+func (c *Converter) jpegAVIFChroma(input *decodedInput) AVIFChromaSubsampling {
+	config := c.config.JPEGToAVIF
+	if config.ChromaSubsampling != AVIFSubsamplingAuto || config.Subsampling != SubsamplingMatchSource {
+		return config.ChromaSubsampling
+	}
+	return matchingAVIFChroma(input.subsampling)
+}
+
+// jpegToAVIF encodes a JPEG lossily and returns the output, effective CQ and SSIM.
+// A chroma subsampling matched to the source is passed to libvips as subsample_mode; when
+// libvips rejects that, the JPEG is encoded again with libvips picking the subsampling.
+func (c *Converter) jpegToAVIF(ctx context.Context, input *decodedInput, params *avifExportParams) ([]byte, int, float64, error) {
+	config := c.config.JPEGToAVIF
+	maxCQ := c.jpegAVIFMaxCQ(input)
+
+	// libvips uses the CQ as the AV1 quality, so output size and similarity grow with it
+	encode := func() ([]byte, int, float64, error) {
+		return c.encodeLossy(ctx, input.image, min(config.CQ, maxCQ), 0, maxCQ, func(cq int) ([]byte, error) {
+			params.Quality = cq
+			return exportAVIF(input.image, params)
+		})
+	}
+
+	params.chroma = c.jpegAVIFChroma(input)
+	buf, cq, score, err := encode()
+	if errors.Is(err, ErrEncodeFailed) && params.chroma != config.ChromaSubsampling {
+		params.chroma = AVIFSubsamplingAuto
+		return encode()
+	}
+	return buf, cq, score, err
+}
+
+// gifToAVIF encodes a GIF lossily and returns the output, effective CQ and SSIM. Animated GIFs
+// become image sequences, single frames still images. Target size and SSIM searches encode
+// every frame at each step, and SSIM is scored on the first frame, which viewers show as the
+// still image. GIFs carry no quality estimate, so adaptive quality does not apply.
+func (c *Converter) gifToAVIF(ctx context.Context, input *decodedInput, anim *gifAnimation, params *avifExportParams) ([]byte, int, float64, error) {
+	return c.encodeLossy(ctx, input.image, params.Quality, 0, avifMaxQuality, func(cq int) ([]byte, error) {
+		params.Quality = cq
+		if anim.frames > 1 {
+			return c.gifToAVIFSequence(ctx, input, anim, params)
+		}
+		return exportAVIF(input.image, params)
+	})
+}
+
+// gifToAVIFSequence encodes each GIF frame as a still AVIF and muxes the AV1 data into an
+// AVIF image sequence. libvips only writes still images, and every frame becomes a keyframe.
+func (c *Converter) gifToAVIFSequence(ctx context.Context, input *decodedInput, anim *gifAnimation, params *avifExportParams) ([]byte, error) {
+	// Still AVIFs reach 65536 pixels, the sequence headers one less
+	if err := checkDimensions(anim.width, anim.height, avifSequenceMaxSize); err != nil {
+		return nil, err
+	}
+
+	animImage, err := input.animatedImage()
+	if err != nil {
+		return nil, err
+	}
+
+	pageHeight := animImage.PageHeight()
+	if pageHeight <= 0 || animImage.Height()/pageHeight != anim.frames {
+		return nil, newKindError(ErrDecodeFailed, fmt.Errorf("decoded frame count does not match the gif (%d frames)", anim.frames))
+	}
+
+	// Only AV1 data goes into the sequence, so frames carry no metadata
+	frameParams := *params
+	frameParams.StripMetadata = true
+
+	frames := make([]*avifStill, 0, anim.frames)
+	for i := 0; i < anim.frames; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		frameBuffer, err := exportAVIFFrame(animImage, i, pageHeight, &frameParams)
 		if err != nil {
-			return fmt.Errorf("failed to export avif: %w", NewFormatError(err))
+			return nil, fmt.Errorf("failed to export avif frame %d: %w", i, newKindError(ErrEncodeFailed, err))
 		}
 
+		frame, err := parseAVIFStill(frameBuffer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read avif frame %d: %w", i, newKindError(ErrEncodeFailed, err))
+		}
+		frames = append(frames, frame)
 	}
 
-	// Check if output is smaller than input
-	if int64(len(outputBuffer)) >= inputInfo.Size() {
-		return NewFormatError(fmt.Errorf("output file size (%d) is not smaller than input (%d)", len(outputBuffer), inputInfo.Size()))
+	// AVIF sequences count plays like WebP does
+	outputBuffer, err := muxAVIFSequence(frames, anim.frameDurations(), anim.webpLoopCount())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write avif image sequence: %w", newKindError(ErrEncodeFailed, err))
 	}
 
-	// Create output directory if needed
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	return outputBuffer, nil
+}
+
+// exportAVIFFrame encodes one page of an animated image as a still AVIF
+func exportAVIFFrame(animImage *vips.ImageRef, page, pageHeight int, params *avifExportParams) ([]byte, error) {
+	frame, err := animImage.Copy()
+	if err != nil {
+		return nil, err
 	}
+	defer frame.Close()
 
-	// Write output file
-	if err := os.WriteFile(outputPath, outputBuffer, 0600); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+	// Treat the strip of pages as one image so ExtractArea crops a single page
+	if err := frame.SetPageHeight(frame.Height()); err != nil {
+		return nil, err
+	}
+	if err := frame.ExtractArea(0, page*pageHeight, frame.Width(), pageHeight); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return params.export(frame)
 }
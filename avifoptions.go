@@ -0,0 +1,158 @@
+package nextgenimage
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// AVIFChromaSubsampling selects how AVIF stores chroma
+type AVIFChromaSubsampling int
+
+const (
+	// AVIFSubsamplingAuto lets libvips decide: 4:4:4 for lossless and Q 90 or higher,
+	// 4:2:0 otherwise (default)
+	AVIFSubsamplingAuto AVIFChromaSubsampling = iota
+	// AVIFSubsampling444 keeps full resolution chroma
+	AVIFSubsampling444
+	// AVIFSubsampling420 halves chroma resolution in both directions
+	AVIFSubsampling420
+)
+
+// AVIFEncoder selects the AV1 encoder libheif uses
+type AVIFEncoder int
+
+const (
+	// AVIFEncoderAuto uses the first AV1 encoder libheif finds (default)
+	AVIFEncoderAuto AVIFEncoder = iota
+	AVIFEncoderAOM
+	AVIFEncoderRav1e
+	AVIFEncoderSVT
+)
+
+// AVIF encoder option ranges
+const (
+	avifMinEffort     = 1
+	avifMaxEffort     = 9
+	avifDefaultEffort = 5
+	avifDefaultDepth  = 8
+	avifFullChromaQ   = 90 // libvips keeps full chroma for lossless and from this Q
+)
+
+// AVIFEncoderOptions tunes the AV1 encoder, trading encode time against size
+type AVIFEncoderOptions struct {
+	Effort            int                   // CPU effort 1-9, higher is slower and smaller (default: 5)
+	BitDepth          int                   // 8, 10 or 12 bits per sample (default: 8)
+	ChromaSubsampling AVIFChromaSubsampling // Default: AVIFSubsamplingAuto
+	Encoder           AVIFEncoder           // Default: AVIFEncoderAuto
+}
+
+// withDefaults fills in unset options
+func (o AVIFEncoderOptions) withDefaults() AVIFEncoderOptions {
+	if o.Effort == 0 {
+		o.Effort = avifDefaultEffort
+	}
+	if o.BitDepth == 0 {
+		o.BitDepth = avifDefaultDepth
+	}
+	return o
+}
+
+// apply validates the options and sets them on the export parameters
+func (o AVIFEncoderOptions) apply(params *avifExportParams) error {
+	if o.Effort < avifMinEffort || o.Effort > avifMaxEffort {
+		return fmt.Errorf("avif effort %d is out of range %d-%d", o.Effort, avifMinEffort, avifMaxEffort)
+	}
+	switch o.BitDepth {
+	case 8, 10, 12:
+	default:
+		return fmt.Errorf("avif bit depth %d is not 8, 10 or 12", o.BitDepth)
+	}
+	if o.ChromaSubsampling < AVIFSubsamplingAuto || o.ChromaSubsampling > AVIFSubsampling420 {
+		return fmt.Errorf("unknown avif chroma subsampling %d", o.ChromaSubsampling)
+	}
+	if o.Encoder < AVIFEncoderAuto || o.Encoder > AVIFEncoderSVT {
+		return fmt.Errorf("unknown avif encoder %d", o.Encoder)
+	}
+
+	params.Effort = o.Effort
+	params.Bitdepth = o.BitDepth
+	params.chroma = o.ChromaSubsampling
+	params.encoder = o.Encoder
+	return nil
+}
+
+// avifExportParams adds the heifsave options govips doesn't pass to its export parameters
+type avifExportParams struct {
+	vips.AvifExportParams
+	chroma  AVIFChromaSubsampling
+	encoder AVIFEncoder
+}
+
+// newAVIFExportParams returns the govips defaults with libvips choosing subsampling and encoder
+func newAVIFExportParams() *avifExportParams {
+	return &avifExportParams{AvifExportParams: *vips.NewAvifExportParams()}
+}
+
+// direct returns true when an option needs the direct libvips save. A chroma subsampling
+// libvips would pick by itself at the quality doesn't.
+func (p *avifExportParams) direct() bool {
+	if p.encoder != AVIFEncoderAuto {
+		return true
+	}
+	switch p.chroma {
+	case AVIFSubsampling444:
+		return !p.Lossless && p.Quality < avifFullChromaQ
+	case AVIFSubsampling420:
+		return !p.Lossless && p.Quality >= avifFullChromaQ
+	default:
+		return false
+	}
+}
+
+// saveOptions returns the heifsave options for the direct libvips save
+func (p *avifExportParams) saveOptions() *saveOptions {
+	options := &saveOptions{suffix: ".avif"}
+	options.set("compression", "av1")
+	options.set("lossless", p.Lossless)
+	if p.Quality > 0 {
+		options.set("Q", p.Quality)
+	}
+	options.set("bitdepth", p.Bitdepth)
+	options.set("effort", p.Effort)
+	// Lossless output always keeps full chroma
+	switch {
+	case p.Lossless:
+	case p.chroma == AVIFSubsampling444:
+		options.set("subsample_mode", "off")
+	case p.chroma == AVIFSubsampling420:
+		options.set("subsample_mode", "on")
+	}
+	switch p.encoder {
+	case AVIFEncoderAOM:
+		options.set("encoder", "aom")
+	case AVIFEncoderRav1e:
+		options.set("encoder", "rav1e")
+	case AVIFEncoderSVT:
+		options.set("encoder", "svt")
+	}
+	return options
+}
+
+// export encodes the image as AVIF, through govips unless an option needs the direct save
+func (p *avifExportParams) export(image *vips.ImageRef) ([]byte, error) {
+	if p.direct() {
+		return saveImage(image, p.saveOptions(), p.StripMetadata)
+	}
+	buf, _, err := image.ExportAvif(&p.AvifExportParams)
+	return buf, err
+}
+
+// exportAVIF encodes the image as AVIF
+func exportAVIF(image *vips.ImageRef, params *avifExportParams) ([]byte, error) {
+	buf, err := params.export(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export avif: %w", newKindError(ErrEncodeFailed, err))
+	}
+	return buf, nil
+}
@@ -0,0 +1,157 @@
+package nextgenimage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+func TestAVIFEncoderOptionsApply(t *testing.T) {
+	defaults := AVIFEncoderOptions{}.withDefaults()
+	if defaults.Effort != 5 || defaults.BitDepth != 8 {
+		t.Errorf("Expected effort 5 and 8-bit by default, got %+v", defaults)
+	}
+
+	params := &avifExportParams{}
+	options := AVIFEncoderOptions{Effort: 9, BitDepth: 12, ChromaSubsampling: AVIFSubsampling444, Encoder: AVIFEncoderSVT}
+	if err := options.apply(params); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if params.Effort != 9 || params.Bitdepth != 12 || params.chroma != AVIFSubsampling444 || params.encoder != AVIFEncoderSVT {
+		t.Errorf("Expected effort 9, 12-bit, 4:4:4 and SVT, got %+v", params)
+	}
+
+	invalid := []AVIFEncoderOptions{
+		{Effort: 10, BitDepth: 8},
+		{Effort: 5, BitDepth: 9},
+		{Effort: 5, BitDepth: 8, ChromaSubsampling: 3},
+		{Effort: 5, BitDepth: 8, Encoder: -1},
+	}
+	for _, options := range invalid {
+		if err := options.apply(&avifExportParams{}); err == nil {
+			t.Errorf("Expected an error for %+v", options)
+		}
+	}
+}
+
+func TestAVIFSaveOptions(t *testing.T) {
+	params := &avifExportParams{AvifExportParams: vips.AvifExportParams{Quality: 30, Effort: 4, Bitdepth: 10}}
+	if params.direct() {
+		t.Error("Default subsampling and encoder should export through govips")
+	}
+
+	// libvips picks 4:2:0 below Q 90 and 4:4:4 from it by itself
+	for _, p := range []avifExportParams{
+		{AvifExportParams: vips.AvifExportParams{Quality: 30}, chroma: AVIFSubsampling420},
+		{AvifExportParams: vips.AvifExportParams{Quality: 95}, chroma: AVIFSubsampling444},
+		{AvifExportParams: vips.AvifExportParams{Lossless: true}, chroma: AVIFSubsampling420},
+	} {
+		if p.direct() {
+			t.Errorf("Expected an export through govips for %+v", p)
+		}
+	}
+
+	tests := []struct {
+		chroma   AVIFChromaSubsampling
+		encoder  AVIFEncoder
+		lossless bool
+		expected string
+	}{
+		{AVIFSubsampling444, AVIFEncoderAuto, false, ".avif[compression=av1,lossless=false,Q=30,bitdepth=10,effort=4,subsample_mode=off]"},
+		{AVIFSubsampling420, AVIFEncoderAOM, false, ".avif[compression=av1,lossless=false,Q=30,bitdepth=10,effort=4,subsample_mode=on,encoder=aom]"},
+		{AVIFSubsamplingAuto, AVIFEncoderRav1e, false, ".avif[compression=av1,lossless=false,Q=30,bitdepth=10,effort=4,encoder=rav1e]"},
+		{AVIFSubsampling420, AVIFEncoderSVT, true, ".avif[compression=av1,lossless=true,Q=30,bitdepth=10,effort=4,encoder=svt]"},
+	}
+	for _, tt := range tests {
+		params.chroma, params.encoder, params.Lossless = tt.chroma, tt.encoder, tt.lossless
+		if !params.direct() {
+			t.Errorf("Expected a direct save for %+v", tt)
+		}
+		if got := params.saveOptions().String(); got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+// av1ConfigFlags returns the bit depth and whether chroma is subsampled from an AVIF's av1C
+func av1ConfigFlags(t *testing.T, path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	still, err := parseAVIFStill(data)
+	if err != nil {
+		t.Fatalf("Failed to parse AVIF: %v", err)
+	}
+	av1C, ok := still.color.property("av1C")
+	if !ok || len(av1C.raw) < isoBoxHeaderSize+3 {
+		t.Fatal("Missing av1C property")
+	}
+
+	// high_bitdepth, twelve_bit, monochrome, subsampling_x, subsampling_y
+	flags := av1C.raw[isoBoxHeaderSize+2]
+	depth := 8
+	if flags&0x40 != 0 {
+		depth = 10
+		if flags&0x20 != 0 {
+			depth = 12
+		}
+	}
+	return depth, flags&0x08 != 0 && flags&0x04 != 0
+}
+
+func TestAVIFEncoderOptions(t *testing.T) {
+	jpegPath := "testdata/test_original.jpg"
+	pngPath := "testdata/test_original.png"
+	for _, path := range []string{jpegPath, pngPath} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Skip("Test file not found:", path)
+			return
+		}
+	}
+	tempDir := t.TempDir()
+
+	// Lossy JPEG output is 4:2:0 at the requested bit depth, leaving subsampling to libvips
+	config := ConverterConfig{}
+	config.JPEGToAVIF.Subsampling = SubsamplingEncoderDefault
+	config.JPEGToAVIF.BitDepth = 10
+	config.JPEGToAVIF.Effort = 2
+	outputPath := filepath.Join(tempDir, "jpeg.avif")
+	if _, err := NewConverter(config).ToAVIF(jpegPath, outputPath); err != nil {
+		t.Fatalf("JPEG to AVIF failed: %v", err)
+	}
+	if depth, subsampled := av1ConfigFlags(t, outputPath); depth != 10 || !subsampled {
+		t.Errorf("Expected 10-bit 4:2:0, got %d-bit subsampled=%v", depth, subsampled)
+	}
+
+	// Full chroma is kept at a low CQ when asked for, whatever the policy
+	config = ConverterConfig{}
+	config.JPEGToAVIF.Subsampling = SubsamplingEncoderDefault
+	config.JPEGToAVIF.ChromaSubsampling = AVIFSubsampling444
+	config.JPEGToAVIF.Effort = 2
+	result, err := NewConverter(config).ToAVIF(jpegPath, outputPath)
+	if err != nil {
+		t.Fatalf("JPEG to AVIF with 4:4:4 failed: %v", err)
+	}
+	if _, subsampled := av1ConfigFlags(t, outputPath); subsampled || result.Subsampling != "4:4:4" {
+		t.Errorf("Expected 4:4:4 at CQ %d, got subsampled=%v and %s", result.Quality, subsampled, result.Subsampling)
+	}
+
+	// Lossless PNG output keeps full chroma
+	config = ConverterConfig{}
+	config.PNGToAVIF.Effort = 2
+	outputPath = filepath.Join(tempDir, "png.avif")
+	_, err = NewConverter(config).ToAVIF(pngPath, outputPath)
+	var formatErr *FormatError
+	if err != nil && !errors.As(err, &formatErr) {
+		t.Fatalf("PNG to AVIF failed: %v", err)
+	}
+	if err == nil {
+		if _, subsampled := av1ConfigFlags(t, outputPath); subsampled {
+			t.Error("Expected 4:4:4 for lossless output")
+		}
+	}
+}
@@ -0,0 +1,974 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// AVIF image sequence constants
+const (
+	avifTimescale         = 1000 // Sample durations are in milliseconds
+	avifAlphaURN          = "urn:mpeg:mpegB:cicp:systems:auxiliary:alpha"
+	avifIndefiniteLength  = math.MaxUint64
+	avifCompressorName    = "AOM Coding"
+	isoLanguageUndefined  = 0x55C4 // "und" packed as ISO 639-2/T
+	isoFixedPointOne      = 0x00010000
+	isoFixedPointOneShort = 0x0100
+	isoVisualResolution   = 0x00480000 // 72 dpi
+	avifSequenceMaxSize   = 65535      // Sample entries store 16-bit sizes, tkhd 16.16 fixed point
+)
+
+// isoUnityMatrix is the identity transformation matrix of mvhd and tkhd
+var isoUnityMatrix = []uint32{isoFixedPointOne, 0, 0, 0, isoFixedPointOne, 0, 0, 0, 0x40000000}
+
+// avifProperty is an item property box from the ipco container
+type avifProperty struct {
+	boxType   string
+	raw       []byte // Whole box including the header
+	essential bool
+}
+
+// avifItem is a coded image item with its data and properties
+type avifItem struct {
+	data  []byte
+	props []avifProperty
+}
+
+// property returns the first associated property of the given type
+func (i *avifItem) property(boxType string) (avifProperty, bool) {
+	for _, prop := range i.props {
+		if prop.boxType == boxType {
+			return prop, true
+		}
+	}
+	return avifProperty{}, false
+}
+
+// avifStill is a still AVIF image with an optional alpha plane
+type avifStill struct {
+	width  int
+	height int
+	color  avifItem
+	alpha  *avifItem
+}
+
+// avifLocation is the data location of an item from the iloc box
+type avifLocation struct {
+	construction int
+	baseOffset   uint64
+	extents      [][2]uint64 // Offset, length
+}
+
+// avifItems gives access to the items of a still AVIF file
+type avifItems struct {
+	buf        []byte
+	types      map[uint32]string
+	locations  map[uint32]avifLocation
+	properties map[uint32][]avifProperty
+}
+
+// load returns the data and properties of a coded AV1 item
+func (items avifItems) load(id uint32) (avifItem, error) {
+	if items.types[id] != "av01" {
+		return avifItem{}, fmt.Errorf("unsupported AVIF item type %q", items.types[id])
+	}
+	loc, ok := items.locations[id]
+	if !ok {
+		return avifItem{}, fmt.Errorf("missing location for AVIF item %d", id)
+	}
+	if loc.construction != 0 {
+		return avifItem{}, fmt.Errorf("unsupported AVIF item construction method %d", loc.construction)
+	}
+
+	var data []byte
+	for _, extent := range loc.extents {
+		start := loc.baseOffset + extent[0]
+		if extent[1] > uint64(len(items.buf)) || start > uint64(len(items.buf))-extent[1] {
+			return avifItem{}, fmt.Errorf("AVIF item %d data is out of range", id)
+		}
+		data = append(data, items.buf[start:start+extent[1]]...)
+	}
+
+	item := avifItem{data: data, props: items.properties[id]}
+	if _, ok := item.property("av1C"); !ok {
+		return avifItem{}, fmt.Errorf("missing av1C property for AVIF item %d", id)
+	}
+	return item, nil
+}
+
+// parseAVIFStill extracts the primary AV1 item and its alpha auxiliary item from a still AVIF
+func parseAVIFStill(buf []byte) (*avifStill, error) {
+	boxes, err := readISOBoxes(buf)
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := findISOBox(boxes, "meta")
+	if !ok {
+		return nil, fmt.Errorf("missing AVIF meta box")
+	}
+	_, _, metaData, err := readISOFullBox(meta.data)
+	if err != nil {
+		return nil, err
+	}
+	children, err := readISOBoxes(metaData)
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := readAVIFPrimaryItem(children)
+	if err != nil {
+		return nil, err
+	}
+	itemTypes, err := readAVIFItemTypes(children)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := readAVIFItemLocations(children)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := readAVIFItemProperties(children)
+	if err != nil {
+		return nil, err
+	}
+	references, err := readAVIFItemReferences(children, "auxl")
+	if err != nil {
+		return nil, err
+	}
+
+	items := avifItems{buf: buf, types: itemTypes, locations: locations, properties: properties}
+	still := &avifStill{}
+	if still.color, err = items.load(primary); err != nil {
+		return nil, err
+	}
+
+	ispe, ok := still.color.property("ispe")
+	if !ok || len(ispe.raw) < isoBoxHeaderSize+isoFullBoxHeaderSize+8 {
+		return nil, fmt.Errorf("missing AVIF image size")
+	}
+	r := isoReader{buf: ispe.raw[isoBoxHeaderSize+isoFullBoxHeaderSize:]}
+	still.width = int(r.uint(4))
+	still.height = int(r.uint(4))
+
+	if id, ok := findAVIFAlphaItem(primary, properties, references); ok {
+		alpha, err := items.load(id)
+		if err != nil {
+			return nil, err
+		}
+		still.alpha = &alpha
+	}
+
+	return still, nil
+}
+
+// findAVIFAlphaItem returns the auxiliary item holding the alpha plane of the primary item
+func findAVIFAlphaItem(primary uint32, properties map[uint32][]avifProperty, references map[uint32]uint32) (uint32, bool) {
+	for from, to := range references {
+		if to != primary {
+			continue
+		}
+		for _, prop := range properties[from] {
+			if prop.boxType == "auxC" && bytes.Contains(prop.raw, []byte(avifAlphaURN)) {
+				return from, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// readAVIFPrimaryItem reads the primary item ID from the pitm box
+func readAVIFPrimaryItem(boxes []isoBox) (uint32, error) {
+	pitm, ok := findISOBox(boxes, "pitm")
+	if !ok {
+		return 0, fmt.Errorf("missing AVIF primary item")
+	}
+	version, _, data, err := readISOFullBox(pitm.data)
+	if err != nil {
+		return 0, err
+	}
+
+	r := isoReader{buf: data}
+	id := uint32(r.uint(itemIDSize(version, 1)))
+	return id, r.err
+}
+
+// readAVIFItemTypes maps item IDs to item types from the iinf box
+func readAVIFItemTypes(boxes []isoBox) (map[uint32]string, error) {
+	iinf, ok := findISOBox(boxes, "iinf")
+	if !ok {
+		return nil, fmt.Errorf("missing AVIF item info")
+	}
+	version, _, data, err := readISOFullBox(iinf.data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := isoReader{buf: data}
+	r.uint(itemIDSize(version, 1)) // Entry count
+	if r.err != nil {
+		return nil, r.err
+	}
+	entries, err := readISOBoxes(data[r.pos:])
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[uint32]string)
+	for _, infe := range entries {
+		if infe.boxType != "infe" {
+			continue
+		}
+		version, _, data, err := readISOFullBox(infe.data)
+		if err != nil {
+			return nil, err
+		}
+		if version < 2 {
+			return nil, fmt.Errorf("unsupported item info entry version %d", version)
+		}
+
+		r := isoReader{buf: data}
+		id := uint32(r.uint(itemIDSize(version, 3)))
+		r.uint(2) // Protection index
+		itemType := r.fourCC()
+		if r.err != nil {
+			return nil, r.err
+		}
+		types[id] = itemType
+	}
+
+	return types, nil
+}
+
+// readAVIFItemLocations reads the data locations from the iloc box
+func readAVIFItemLocations(boxes []isoBox) (map[uint32]avifLocation, error) {
+	iloc, ok := findISOBox(boxes, "iloc")
+	if !ok {
+		return nil, fmt.Errorf("missing AVIF item locations")
+	}
+	version, _, data, err := readISOFullBox(iloc.data)
+	if err != nil {
+		return nil, err
+	}
+	if version > 2 {
+		return nil, fmt.Errorf("unsupported item location version %d", version)
+	}
+
+	r := isoReader{buf: data}
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), 0
+	if version > 0 {
+		indexSize = int(sizes & 0x0F)
+	}
+
+	locations := make(map[uint32]avifLocation)
+	count := r.uint(itemIDSize(version, 2))
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		id := uint32(r.uint(itemIDSize(version, 2)))
+		var loc avifLocation
+		if version > 0 {
+			loc.construction = int(r.uint(2) & 0x0F)
+		}
+		r.uint(2) // Data reference index
+		loc.baseOffset = r.uint(baseOffsetSize)
+
+		extents := r.uint(2)
+		for j := uint64(0); j < extents && r.err == nil; j++ {
+			r.uint(indexSize)
+			offset := r.uint(offsetSize)
+			length := r.uint(lengthSize)
+			loc.extents = append(loc.extents, [2]uint64{offset, length})
+		}
+		locations[id] = loc
+	}
+
+	return locations, r.err
+}
+
+// readAVIFItemProperties maps item IDs to their associated properties from the iprp box
+func readAVIFItemProperties(boxes []isoBox) (map[uint32][]avifProperty, error) {
+	iprp, ok := findISOBox(boxes, "iprp")
+	if !ok {
+		return nil, fmt.Errorf("missing AVIF item properties")
+	}
+	children, err := readISOBoxes(iprp.data)
+	if err != nil {
+		return nil, err
+	}
+	ipco, ok := findISOBox(children, "ipco")
+	if !ok {
+		return nil, fmt.Errorf("missing AVIF item property container")
+	}
+	container, err := readISOBoxes(ipco.data)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := make(map[uint32][]avifProperty)
+	for _, ipma := range children {
+		if ipma.boxType != "ipma" {
+			continue
+		}
+		version, flags, data, err := readISOFullBox(ipma.data)
+		if err != nil {
+			return nil, err
+		}
+
+		r := isoReader{buf: data}
+		count := r.uint(4)
+		for i := uint64(0); i < count && r.err == nil; i++ {
+			id := uint32(r.uint(itemIDSize(version, 1)))
+			associations := r.uint(1)
+			for j := uint64(0); j < associations && r.err == nil; j++ {
+				// The essential flag is the top bit, followed by a 1-based property index
+				var essential bool
+				var index int
+				if flags&1 != 0 {
+					v := r.uint(2)
+					essential, index = v&0x8000 != 0, int(v&0x7FFF)
+				} else {
+					v := r.uint(1)
+					essential, index = v&0x80 != 0, int(v&0x7F)
+				}
+				if index == 0 {
+					continue
+				}
+				if index > len(container) {
+					return nil, fmt.Errorf("invalid AVIF property index %d", index)
+				}
+				prop := container[index-1]
+				properties[id] = append(properties[id], avifProperty{boxType: prop.boxType, raw: prop.raw, essential: essential})
+			}
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	return properties, nil
+}
+
+// readAVIFItemReferences maps referencing item IDs to the first item they reference with the given type
+func readAVIFItemReferences(boxes []isoBox, refType string) (map[uint32]uint32, error) {
+	references := make(map[uint32]uint32)
+	iref, ok := findISOBox(boxes, "iref")
+	if !ok {
+		return references, nil
+	}
+	version, _, data, err := readISOFullBox(iref.data)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readISOBoxes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.boxType != refType {
+			continue
+		}
+		r := isoReader{buf: entry.data}
+		from := uint32(r.uint(itemIDSize(version, 1)))
+		if r.uint(2) > 0 {
+			references[from] = uint32(r.uint(itemIDSize(version, 1)))
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	return references, nil
+}
+
+// itemIDSize returns the item ID field size, which grows to 32 bits from the given box version
+func itemIDSize(version, wideFrom byte) int {
+	if version >= wideFrom {
+		return 4
+	}
+	return 2
+}
+
+// muxAVIFSequence builds an AVIF image sequence from still frames encoded with identical settings.
+// Durations are in milliseconds and plays counts full playbacks with 0 meaning forever.
+// The first frame doubles as the primary still image for decoders without sequence support.
+func muxAVIFSequence(frames []*avifStill, durations []int, plays int) ([]byte, error) {
+	if len(frames) == 0 || len(frames) != len(durations) {
+		return nil, fmt.Errorf("expected one duration per frame, got %d frames and %d durations", len(frames), len(durations))
+	}
+
+	first := frames[0]
+	colorConfig, _ := first.color.property("av1C")
+	var alphaConfig avifProperty
+	if first.alpha != nil {
+		alphaConfig, _ = first.alpha.property("av1C")
+	}
+
+	if first.width > avifSequenceMaxSize || first.height > avifSequenceMaxSize {
+		return nil, fmt.Errorf("frame size %dx%d exceeds the %d pixel limit of image sequences", first.width, first.height, avifSequenceMaxSize)
+	}
+
+	// A track has a single sample description, so every frame must match the first one
+	for i, frame := range frames {
+		if frame.width != first.width || frame.height != first.height {
+			return nil, fmt.Errorf("frame %d is %dx%d, expected %dx%d", i, frame.width, frame.height, first.width, first.height)
+		}
+		if (frame.alpha == nil) != (first.alpha == nil) {
+			return nil, fmt.Errorf("frame %d alpha plane does not match the first frame", i)
+		}
+		config, _ := frame.color.property("av1C")
+		if !sameAV1Config(config.raw, colorConfig.raw) {
+			return nil, fmt.Errorf("frame %d was encoded with a different AV1 configuration", i)
+		}
+	}
+
+	var oneLoop uint64
+	for _, d := range durations {
+		if d <= 0 {
+			return nil, fmt.Errorf("frame durations must be positive")
+		}
+		oneLoop += uint64(d)
+	}
+	trackDuration := oneLoop * uint64(plays)
+	if plays == 0 {
+		trackDuration = avifIndefiniteLength
+	}
+
+	// Offsets into mdat depend on the header size, which does not depend on the offsets
+	build := func(mdatStart uint64) ([]byte, []byte) {
+		var mdat []byte
+		colorOffsets := make([]uint64, len(frames))
+		colorSizes := make([]uint32, len(frames))
+		alphaOffsets := make([]uint64, len(frames))
+		alphaSizes := make([]uint32, len(frames))
+		for i, frame := range frames {
+			colorOffsets[i] = mdatStart + uint64(len(mdat))
+			colorSizes[i] = uint32(len(frame.color.data))
+			mdat = append(mdat, frame.color.data...)
+			if frame.alpha != nil {
+				alphaOffsets[i] = mdatStart + uint64(len(mdat))
+				alphaSizes[i] = uint32(len(frame.alpha.data))
+				mdat = append(mdat, frame.alpha.data...)
+			}
+		}
+
+		var header []byte
+		header = append(header, avifSequenceFileType()...)
+		header = append(header, avifSequenceMeta(first, colorOffsets[0], alphaOffsets[0])...)
+
+		colorEntry := avifSampleEntry(first, colorConfig.raw, first.color.props, false)
+		tracks := [][]byte{avifTrack(1, 0, "pict", first, colorEntry, durations, colorOffsets, colorSizes, oneLoop, trackDuration, plays != 1)}
+		if first.alpha != nil {
+			alphaEntry := avifSampleEntry(first, alphaConfig.raw, nil, true)
+			tracks = append(tracks, avifTrack(2, 1, "auxv", first, alphaEntry, durations, alphaOffsets, alphaSizes, oneLoop, trackDuration, plays != 1))
+		}
+		header = append(header, avifMovie(uint32(len(tracks)+1), trackDuration, tracks)...)
+
+		return header, mdat
+	}
+
+	header, _ := build(0)
+	mdatStart := uint64(len(header) + isoBoxHeaderSize)
+	header, mdat := build(mdatStart)
+	if mdatStart+uint64(len(mdat)) > math.MaxUint32 {
+		return nil, fmt.Errorf("AVIF image sequence is too large")
+	}
+
+	return append(header, isoBoxBytes("mdat", mdat)...), nil
+}
+
+// sameAV1Config compares the profile, bit depth and chroma layout of two av1C boxes
+func sameAV1Config(a, b []byte) bool {
+	const header = isoBoxHeaderSize
+	if len(a) < header+3 || len(b) < header+3 {
+		return false
+	}
+	// marker/version, seq_profile (top 3 bits), then tier, depth and subsampling flags
+	return a[header] == b[header] && a[header+1]>>5 == b[header+1]>>5 && a[header+2] == b[header+2]
+}
+
+// avifSequenceFileType builds the ftyp box of an image sequence that also has a primary image
+func avifSequenceFileType() []byte {
+	var w isoWriter
+	w.bytes([]byte("avis"))
+	w.u32(0)
+	for _, brand := range []string{"avif", "avis", "msf1", "iso8", "mif1", "miaf"} {
+		w.bytes([]byte(brand))
+	}
+	return isoBoxBytes("ftyp", w.buf)
+}
+
+// avifSequenceMeta builds the meta box describing the first frame as the primary image
+func avifSequenceMeta(first *avifStill, colorOffset, alphaOffset uint64) []byte {
+	items := []*avifItem{&first.color}
+	offsets := []uint64{colorOffset}
+	if first.alpha != nil {
+		items = append(items, first.alpha)
+		offsets = append(offsets, alphaOffset)
+	}
+
+	var hdlr isoWriter
+	hdlr.u32(0)
+	hdlr.bytes([]byte("pict"))
+	hdlr.zeros(12 + 1) // Reserved, empty name
+
+	var pitm isoWriter
+	pitm.u16(1)
+
+	// Offsets and lengths are 32 bits, no base offset
+	var iloc isoWriter
+	iloc.u8(0x44)
+	iloc.u8(0x00)
+	iloc.u16(uint16(len(items)))
+	for i, item := range items {
+		iloc.u16(uint16(i + 1))
+		iloc.u16(0) // Data reference index
+		iloc.u16(1) // Extent count
+		iloc.u32(uint32(offsets[i]))
+		iloc.u32(uint32(len(item.data)))
+	}
+
+	var iinf isoWriter
+	iinf.u16(uint16(len(items)))
+	for i := range items {
+		var infe isoWriter
+		infe.u16(uint16(i + 1))
+		infe.u16(0) // Protection index
+		infe.bytes([]byte("av01"))
+		infe.u8(0) // Empty name
+		iinf.bytes(isoFullBoxBytes("infe", 2, 0, infe.buf))
+	}
+
+	// Properties shared by both items, such as ispe, are stored once
+	var ipco [][]byte
+	var ipma isoWriter
+	ipma.u32(uint32(len(items)))
+	for i, item := range items {
+		ipma.u16(uint16(i + 1))
+		ipma.u8(uint8(len(item.props)))
+		for _, prop := range item.props {
+			index := -1
+			for j, existing := range ipco {
+				if bytes.Equal(existing, prop.raw) {
+					index = j
+				}
+			}
+			if index < 0 {
+				ipco = append(ipco, prop.raw)
+				index = len(ipco) - 1
+			}
+			association := uint8(index + 1)
+			if prop.essential {
+				association |= 0x80
+			}
+			ipma.u8(association)
+		}
+	}
+
+	boxes := [][]byte{
+		isoFullBoxBytes("hdlr", 0, 0, hdlr.buf),
+		isoFullBoxBytes("pitm", 0, 0, pitm.buf),
+		isoFullBoxBytes("iloc", 0, 0, iloc.buf),
+		isoFullBoxBytes("iinf", 0, 0, iinf.buf),
+	}
+	if first.alpha != nil {
+		var auxl isoWriter
+		auxl.u16(2) // Alpha item
+		auxl.u16(1) // Reference count
+		auxl.u16(1) // Color item
+		boxes = append(boxes, isoFullBoxBytes("iref", 0, 0, isoBoxBytes("auxl", auxl.buf)))
+	}
+	boxes = append(boxes, isoBoxBytes("iprp", isoBoxBytes("ipco", ipco...), isoFullBoxBytes("ipma", 0, 0, ipma.buf)))
+
+	return isoFullBoxBytes("meta", 0, 0, boxes...)
+}
+
+// avifMovie builds the moov box holding the sequence tracks
+func avifMovie(nextTrackID uint32, duration uint64, tracks [][]byte) []byte {
+	var mvhd isoWriter
+	mvhd.u64(0) // Creation time
+	mvhd.u64(0) // Modification time
+	mvhd.u32(avifTimescale)
+	mvhd.u64(duration)
+	mvhd.u32(isoFixedPointOne)      // Rate
+	mvhd.u16(isoFixedPointOneShort) // Volume
+	mvhd.zeros(2 + 8)               // Reserved
+	for _, v := range isoUnityMatrix {
+		mvhd.u32(v)
+	}
+	mvhd.zeros(24) // Pre-defined
+	mvhd.u32(nextTrackID)
+
+	return isoBoxBytes("moov", append([][]byte{isoFullBoxBytes("mvhd", 1, 0, mvhd.buf)}, tracks...)...)
+}
+
+// avifTrack builds a trak box with one sample per frame and one chunk per sample.
+// Alpha tracks reference the color track they belong to.
+func avifTrack(trackID, auxOf uint32, handler string, first *avifStill, sampleEntry []byte, durations []int, offsets []uint64, sizes []uint32, oneLoop, trackDuration uint64, repeat bool) []byte {
+	const trackEnabled, trackInMovie = 0x1, 0x2
+
+	var tkhd isoWriter
+	tkhd.u64(0) // Creation time
+	tkhd.u64(0) // Modification time
+	tkhd.u32(trackID)
+	tkhd.u32(0) // Reserved
+	tkhd.u64(trackDuration)
+	tkhd.zeros(8) // Reserved
+	tkhd.u16(0)   // Layer
+	tkhd.u16(0)   // Alternate group
+	tkhd.u16(0)   // Volume
+	tkhd.u16(0)   // Reserved
+	for _, v := range isoUnityMatrix {
+		tkhd.u32(v)
+	}
+	tkhd.u32(uint32(first.width) << 16)
+	tkhd.u32(uint32(first.height) << 16)
+
+	boxes := [][]byte{isoFullBoxBytes("tkhd", 1, trackEnabled|trackInMovie, tkhd.buf)}
+
+	if auxOf > 0 {
+		var auxl isoWriter
+		auxl.u32(auxOf)
+		boxes = append(boxes, isoBoxBytes("tref", isoBoxBytes("auxl", auxl.buf)))
+	}
+
+	// A repeating edit list plays the media again until the track duration is reached
+	var elst isoWriter
+	elst.u32(1) // Entry count
+	elst.u64(oneLoop)
+	elst.u64(0) // Media time
+	elst.u16(1) // Media rate
+	elst.u16(0)
+	var elstFlags uint32
+	if repeat {
+		elstFlags = 1
+	}
+	boxes = append(boxes, isoBoxBytes("edts", isoFullBoxBytes("elst", 1, elstFlags, elst.buf)))
+
+	var mdhd isoWriter
+	mdhd.u32(0) // Creation time
+	mdhd.u32(0) // Modification time
+	mdhd.u32(avifTimescale)
+	mdhd.u32(uint32(min(oneLoop, math.MaxUint32)))
+	mdhd.u16(isoLanguageUndefined)
+	mdhd.u16(0)
+
+	var hdlr isoWriter
+	hdlr.u32(0)
+	hdlr.bytes([]byte(handler))
+	hdlr.zeros(12 + 1) // Reserved, empty name
+
+	var vmhd isoWriter
+	vmhd.zeros(8) // Graphics mode, opcolor
+
+	var dref isoWriter
+	dref.u32(1)
+	dref.bytes(isoFullBoxBytes("url ", 0, 1)) // Media is in this file
+
+	var stsd isoWriter
+	stsd.u32(1)
+	stsd.bytes(sampleEntry)
+
+	// Consecutive samples with the same duration share an entry
+	var stts isoWriter
+	var runs [][2]uint32
+	for _, d := range durations {
+		if n := len(runs); n > 0 && runs[n-1][1] == uint32(d) {
+			runs[n-1][0]++
+		} else {
+			runs = append(runs, [2]uint32{1, uint32(d)})
+		}
+	}
+	stts.u32(uint32(len(runs)))
+	for _, run := range runs {
+		stts.u32(run[0])
+		stts.u32(run[1])
+	}
+
+	var stsc isoWriter
+	stsc.u32(1)
+	stsc.u32(1) // First chunk
+	stsc.u32(1) // Samples per chunk
+	stsc.u32(1) // Sample description index
+
+	var stsz isoWriter
+	stsz.u32(0) // Sizes differ
+	stsz.u32(uint32(len(sizes)))
+	for _, size := range sizes {
+		stsz.u32(size)
+	}
+
+	var stco isoWriter
+	stco.u32(uint32(len(offsets)))
+	for _, offset := range offsets {
+		stco.u32(uint32(offset))
+	}
+
+	// Without an stss box every sample is a sync sample, which holds for all-keyframe sequences
+	stbl := isoBoxBytes("stbl",
+		isoFullBoxBytes("stsd", 0, 0, stsd.buf),
+		isoFullBoxBytes("stts", 0, 0, stts.buf),
+		isoFullBoxBytes("stsc", 0, 0, stsc.buf),
+		isoFullBoxBytes("stsz", 0, 0, stsz.buf),
+		isoFullBoxBytes("stco", 0, 0, stco.buf),
+	)
+	minf := isoBoxBytes("minf",
+		isoFullBoxBytes("vmhd", 0, 1, vmhd.buf),
+		isoBoxBytes("dinf", isoFullBoxBytes("dref", 0, 0, dref.buf)),
+		stbl,
+	)
+	mdia := isoBoxBytes("mdia",
+		isoFullBoxBytes("mdhd", 0, 0, mdhd.buf),
+		isoFullBoxBytes("hdlr", 0, 0, hdlr.buf),
+		minf,
+	)
+	boxes = append(boxes, mdia)
+
+	return isoBoxBytes("trak", boxes...)
+}
+
+// avifSampleEntry builds the av01 visual sample entry. The color track carries the
+// colr properties of the primary item, the alpha track an auxi box naming its role.
+func avifSampleEntry(first *avifStill, av1C []byte, props []avifProperty, alpha bool) []byte {
+	var w isoWriter
+	w.zeros(6)  // Reserved
+	w.u16(1)    // Data reference index
+	w.zeros(16) // Pre-defined, reserved
+	w.u16(uint16(first.width))
+	w.u16(uint16(first.height))
+	w.u32(isoVisualResolution)
+	w.u32(isoVisualResolution)
+	w.u32(0) // Reserved
+	w.u16(1) // Frame count
+
+	compressor := make([]byte, 32)
+	compressor[0] = byte(len(avifCompressorName))
+	copy(compressor[1:], avifCompressorName)
+	w.bytes(compressor)
+
+	w.u16(0x0018) // Depth
+	w.u16(0xFFFF) // Pre-defined
+
+	w.bytes(av1C)
+	for _, prop := range props {
+		if prop.boxType == "colr" {
+			w.bytes(prop.raw)
+		}
+	}
+
+	// Every frame is an intra-only keyframe that references nothing
+	var ccst isoWriter
+	ccst.u32(1<<31 | 1<<30)
+	w.bytes(isoFullBoxBytes("ccst", 0, 0, ccst.buf))
+
+	if alpha {
+		w.bytes(isoFullBoxBytes("auxi", 0, 0, append([]byte(avifAlphaURN), 0)))
+	}
+
+	return isoBoxBytes("av01", w.buf)
+}
+
+// avifSequence holds the timing information of an AVIF image sequence
+type avifSequence struct {
+	tracks int
+	plays  int           // 0 means forever
+	frames int           // Sample count of the first track
+	timing []avifTimeRun // Sample durations of the first track, as stored in stts
+}
+
+// avifTimeRun is a stts entry: count consecutive samples lasting duration milliseconds each
+type avifTimeRun struct {
+	count    int
+	duration int
+}
+
+// readAVIFSequence reads the frame durations and play count of an AVIF image sequence.
+// It returns nil for still images.
+func readAVIFSequence(buf []byte) (*avifSequence, error) {
+	boxes, err := readISOBoxes(buf)
+	if err != nil {
+		return nil, err
+	}
+	moov, ok := findISOBox(boxes, "moov")
+	if !ok {
+		return nil, nil
+	}
+	children, err := readISOBoxes(moov.data)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := &avifSequence{}
+	for _, trak := range children {
+		if trak.boxType != "trak" {
+			continue
+		}
+		seq.tracks++
+		if seq.tracks > 1 {
+			continue
+		}
+		if err := seq.readTrack(trak.data, len(buf)); err != nil {
+			return nil, err
+		}
+	}
+
+	return seq, nil
+}
+
+// readTrack reads the sample count, durations and play count of a trak box
+func (seq *avifSequence) readTrack(trak []byte, fileSize int) error {
+	tkhd, err := findISOPath(trak, "tkhd")
+	if err != nil {
+		return err
+	}
+	elst, err := findISOPath(trak, "edts", "elst")
+	if err != nil {
+		return err
+	}
+	mdhd, err := findISOPath(trak, "mdia", "mdhd")
+	if err != nil {
+		return err
+	}
+	stts, err := findISOPath(trak, "mdia", "minf", "stbl", "stts")
+	if err != nil {
+		return err
+	}
+	stsz, err := findISOPath(trak, "mdia", "minf", "stbl", "stsz")
+	if err != nil {
+		return err
+	}
+
+	timescale, err := readAVIFTimescale(mdhd)
+	if err != nil {
+		return err
+	}
+	if seq.frames, err = readAVIFSampleCount(stsz, fileSize); err != nil {
+		return err
+	}
+	if seq.timing, err = readAVIFTiming(stts, seq.frames, timescale); err != nil {
+		return err
+	}
+	seq.plays, err = readAVIFPlays(tkhd, elst)
+	return err
+}
+
+// readAVIFTimescale reads the media timescale from a mdhd box
+func readAVIFTimescale(mdhd []byte) (uint64, error) {
+	version, _, data, err := readISOFullBox(mdhd)
+	if err != nil {
+		return 0, err
+	}
+	r := isoReader{buf: data}
+	timeSize := 4
+	if version == 1 {
+		timeSize = 8
+	}
+	r.skip(2 * timeSize) // Creation and modification time
+	timescale := r.uint(4)
+	if r.err != nil || timescale == 0 {
+		return 0, fmt.Errorf("invalid media header")
+	}
+	return timescale, nil
+}
+
+// readAVIFTiming reads the runs of a stts box in milliseconds. The counts are untrusted,
+// so runs are kept as they are and must add up to the frames counted in stsz.
+func readAVIFTiming(stts []byte, frames int, timescale uint64) ([]avifTimeRun, error) {
+	_, _, data, err := readISOFullBox(stts)
+	if err != nil {
+		return nil, err
+	}
+	r := isoReader{buf: data}
+	entries := r.uint(4)
+	var timing []avifTimeRun
+	var total uint64
+	for i := uint64(0); i < entries && r.err == nil; i++ {
+		count, delta := r.uint(4), r.uint(4)
+		total += count
+		if total > uint64(frames) {
+			return nil, fmt.Errorf("time to sample box lists more than %d samples", frames)
+		}
+		if count > 0 {
+			timing = append(timing, avifTimeRun{count: int(count), duration: int(delta * 1000 / timescale)})
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if total != uint64(frames) {
+		return nil, fmt.Errorf("time to sample box lists %d of %d samples", total, frames)
+	}
+	return timing, nil
+}
+
+// readAVIFPlays reads the play count from the track duration in tkhd and the edit in elst,
+// with 0 meaning forever
+func readAVIFPlays(tkhd, elst []byte) (int, error) {
+	version, _, data, err := readISOFullBox(tkhd)
+	if err != nil {
+		return 0, err
+	}
+	r := isoReader{buf: data}
+	var trackDuration uint64
+	var indefinite bool
+	if version == 1 {
+		r.skip(8 + 8 + 4 + 4) // Times, track ID, reserved
+		trackDuration = r.uint(8)
+		indefinite = trackDuration == math.MaxUint64
+	} else {
+		r.skip(4 + 4 + 4 + 4)
+		trackDuration = r.uint(4)
+		indefinite = trackDuration == math.MaxUint32
+	}
+
+	version, flags, data, err := readISOFullBox(elst)
+	if err != nil {
+		return 0, err
+	}
+	r = isoReader{buf: data}
+	r.uint(4) // Entry count
+	timeSize := 4
+	if version == 1 {
+		timeSize = 8
+	}
+	segment := r.uint(timeSize)
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	// Same interpretation as libavif: a repeating edit plays until the track duration ends
+	switch {
+	case flags&1 == 0 || segment == 0:
+		return 1, nil
+	case indefinite:
+		return 0, nil
+	default:
+		return int((trackDuration + segment - 1) / segment), nil
+	}
+}
+
+// readAVIFSampleCount reads the sample count of a stsz box. Every sample takes at least one
+// byte of the file, so the count is rejected when the file of size fileSize cannot hold it.
+func readAVIFSampleCount(stsz []byte, fileSize int) (int, error) {
+	_, _, data, err := readISOFullBox(stsz)
+	if err != nil {
+		return 0, err
+	}
+	r := isoReader{buf: data}
+	sampleSize, count := r.uint(4), r.uint(4)
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	// Without a common size, every sample has a 4 byte entry in the box
+	limit := uint64(len(data)-8) / 4
+	if sampleSize != 0 {
+		limit = uint64(fileSize) / sampleSize
+	}
+	if count > limit {
+		return 0, fmt.Errorf("sample size box lists %d samples, more than the file can hold", count)
+	}
+	return int(count), nil
+}
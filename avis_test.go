@@ -0,0 +1,367 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildTestAVIF builds a still AVIF the way libheif lays it out: ftyp, meta, then mdat,
+// with an iloc base offset and an alpha item referencing the primary item
+func buildTestAVIF(color, alpha []byte, width, height int) []byte {
+	var ispe isoWriter
+	ispe.u32(uint32(width))
+	ispe.u32(uint32(height))
+	props := [][]byte{
+		isoFullBoxBytes("ispe", 0, 0, ispe.buf),
+		isoBoxBytes("av1C", []byte{0x81, 0x00, 0x0C, 0x00}),
+		isoBoxBytes("colr", []byte("nclx"), []byte{0, 1, 0, 13, 0, 6, 0x80}),
+		isoFullBoxBytes("auxC", 0, 0, append([]byte(avifAlphaURN), 0)),
+		isoBoxBytes("av1C", []byte{0x81, 0x00, 0x1C, 0x00}),
+	}
+
+	var hdlr isoWriter
+	hdlr.u32(0)
+	hdlr.bytes([]byte("pict"))
+	hdlr.zeros(13)
+
+	var pitm isoWriter
+	pitm.u16(1)
+
+	var iinf isoWriter
+	iinf.u16(2)
+	for id := uint16(1); id <= 2; id++ {
+		var infe isoWriter
+		infe.u16(id)
+		infe.u16(0)
+		infe.bytes([]byte("av01"))
+		infe.u8(0)
+		iinf.bytes(isoFullBoxBytes("infe", 2, 0, infe.buf))
+	}
+
+	var auxl isoWriter
+	auxl.u16(2)
+	auxl.u16(1)
+	auxl.u16(1)
+
+	var ipma isoWriter
+	ipma.u32(2)
+	ipma.u16(1)
+	ipma.u8(3)
+	ipma.bytes([]byte{1, 0x80 | 2, 3})
+	ipma.u16(2)
+	ipma.u8(3)
+	ipma.bytes([]byte{1, 0x80 | 5, 0x80 | 4})
+
+	// iloc version 1 with a base offset pointing at the mdat payload
+	iloc := func(base uint32) []byte {
+		var w isoWriter
+		w.u8(0x44)
+		w.u8(0x40)
+		w.u16(2)
+		for id, extent := range [][2]int{{0, len(color)}, {len(color), len(alpha)}} {
+			w.u16(uint16(id + 1))
+			w.u16(0) // Construction method 0
+			w.u16(0)
+			w.u32(base)
+			w.u16(1)
+			w.u32(uint32(extent[0]))
+			w.u32(uint32(extent[1]))
+		}
+		return isoFullBoxBytes("iloc", 1, 0, w.buf)
+	}
+
+	build := func(base uint32) []byte {
+		ftyp := isoBoxBytes("ftyp", []byte("avif"), make([]byte, 4), []byte("mif1"), []byte("avif"))
+		meta := isoFullBoxBytes("meta", 0, 0,
+			isoFullBoxBytes("hdlr", 0, 0, hdlr.buf),
+			isoFullBoxBytes("pitm", 0, 0, pitm.buf),
+			iloc(base),
+			isoFullBoxBytes("iinf", 0, 0, iinf.buf),
+			isoFullBoxBytes("iref", 0, 0, isoBoxBytes("auxl", auxl.buf)),
+			isoBoxBytes("iprp", isoBoxBytes("ipco", props...), isoFullBoxBytes("ipma", 0, 0, ipma.buf)),
+		)
+		return append(ftyp, meta...)
+	}
+
+	header := build(0)
+	header = build(uint32(len(header) + isoBoxHeaderSize))
+	return append(header, isoBoxBytes("mdat", color, alpha)...)
+}
+
+func TestParseAVIFStill(t *testing.T) {
+	color := []byte("color-frame-data")
+	alpha := []byte("alpha")
+	still, err := parseAVIFStill(buildTestAVIF(color, alpha, 200, 100))
+	if err != nil {
+		t.Fatalf("parseAVIFStill failed: %v", err)
+	}
+
+	if still.width != 200 || still.height != 100 {
+		t.Errorf("Expected 200x100, got %dx%d", still.width, still.height)
+	}
+	if !bytes.Equal(still.color.data, color) {
+		t.Errorf("Unexpected color data: %q", still.color.data)
+	}
+	if still.alpha == nil || !bytes.Equal(still.alpha.data, alpha) {
+		t.Fatalf("Unexpected alpha item: %+v", still.alpha)
+	}
+
+	av1C, ok := still.color.property("av1C")
+	if !ok || !av1C.essential {
+		t.Error("Expected an essential av1C property on the color item")
+	}
+	if _, ok := still.color.property("colr"); !ok {
+		t.Error("Expected a colr property on the color item")
+	}
+	if _, ok := still.alpha.property("auxC"); !ok {
+		t.Error("Expected an auxC property on the alpha item")
+	}
+}
+
+func TestParseAVIFStillInvalid(t *testing.T) {
+	valid := buildTestAVIF([]byte("color"), []byte("alpha"), 16, 16)
+
+	invalid := map[string][]byte{
+		"empty":     nil,
+		"truncated": valid[:len(valid)-4],
+		"no meta":   isoBoxBytes("ftyp", []byte("avif"), make([]byte, 4)),
+	}
+
+	for name, buf := range invalid {
+		if _, err := parseAVIFStill(buf); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// readTrackSamples returns the sample data of a track using its stsz and stco boxes
+func readTrackSamples(t *testing.T, buf []byte, track int) [][]byte {
+	t.Helper()
+
+	moov, err := findISOPath(buf, "moov")
+	if err != nil {
+		t.Fatalf("Missing moov: %v", err)
+	}
+	boxes, err := readISOBoxes(moov)
+	if err != nil {
+		t.Fatalf("Invalid moov: %v", err)
+	}
+
+	var traks []isoBox
+	for _, box := range boxes {
+		if box.boxType == "trak" {
+			traks = append(traks, box)
+		}
+	}
+	if track >= len(traks) {
+		t.Fatalf("Missing track %d", track)
+	}
+
+	stsz, err := findISOPath(traks[track].data, "mdia", "minf", "stbl", "stsz")
+	if err != nil {
+		t.Fatalf("Missing stsz: %v", err)
+	}
+	stco, err := findISOPath(traks[track].data, "mdia", "minf", "stbl", "stco")
+	if err != nil {
+		t.Fatalf("Missing stco: %v", err)
+	}
+
+	count := int(binary.BigEndian.Uint32(stsz[8:]))
+	var samples [][]byte
+	for i := 0; i < count; i++ {
+		size := int(binary.BigEndian.Uint32(stsz[12+4*i:]))
+		offset := int(binary.BigEndian.Uint32(stco[8+4*i:]))
+		samples = append(samples, buf[offset:offset+size])
+	}
+	return samples
+}
+
+// sequenceDurations expands the stts runs of a sequence into per-frame durations
+func sequenceDurations(seq *avifSequence) []int {
+	var durations []int
+	for _, run := range seq.timing {
+		for i := 0; i < run.count; i++ {
+			durations = append(durations, run.duration)
+		}
+	}
+	return durations
+}
+
+func TestMuxAVIFSequence(t *testing.T) {
+	var frames []*avifStill
+	for _, name := range []string{"first", "second", "third"} {
+		still, err := parseAVIFStill(buildTestAVIF([]byte(name+"-color"), []byte(name+"-alpha"), 32, 16))
+		if err != nil {
+			t.Fatalf("parseAVIFStill failed: %v", err)
+		}
+		frames = append(frames, still)
+	}
+
+	tests := []struct {
+		name  string
+		plays int
+	}{
+		{"Forever", 0},
+		{"Once", 1},
+		{"FourTimes", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			durations := []int{40, 40, 200}
+			buf, err := muxAVIFSequence(frames, durations, tt.plays)
+			if err != nil {
+				t.Fatalf("muxAVIFSequence failed: %v", err)
+			}
+
+			if DetectImageTypeFromBytes(buf) != ImageTypeAVIF {
+				t.Errorf("Expected AVIF, got %s", DetectImageTypeFromBytes(buf))
+			}
+
+			seq, err := readAVIFSequence(buf)
+			if err != nil {
+				t.Fatalf("readAVIFSequence failed: %v", err)
+			}
+			if seq == nil {
+				t.Fatal("Expected an image sequence")
+			}
+			if seq.tracks != 2 {
+				t.Errorf("Expected color and alpha tracks, got %d", seq.tracks)
+			}
+			if seq.plays != tt.plays {
+				t.Errorf("Expected %d plays, got %d", tt.plays, seq.plays)
+			}
+			if durations := sequenceDurations(seq); seq.frames != 3 || len(durations) != 3 || durations[0] != 40 || durations[1] != 40 || durations[2] != 200 {
+				t.Errorf("Unexpected durations: %v", durations)
+			}
+
+			// The first frame is also the primary still image
+			still, err := parseAVIFStill(buf)
+			if err != nil {
+				t.Fatalf("Primary image is unreadable: %v", err)
+			}
+			if string(still.color.data) != "first-color" || still.alpha == nil || string(still.alpha.data) != "first-alpha" {
+				t.Errorf("Unexpected primary image: %q", still.color.data)
+			}
+
+			for track, suffix := range []string{"-color", "-alpha"} {
+				samples := readTrackSamples(t, buf, track)
+				for i, name := range []string{"first", "second", "third"} {
+					if string(samples[i]) != name+suffix {
+						t.Errorf("Track %d sample %d: expected %q, got %q", track, i, name+suffix, samples[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMuxAVIFSequenceInvalid(t *testing.T) {
+	small, _ := parseAVIFStill(buildTestAVIF([]byte("a"), []byte("b"), 16, 16))
+	large, _ := parseAVIFStill(buildTestAVIF([]byte("a"), []byte("b"), 32, 32))
+
+	if _, err := muxAVIFSequence(nil, nil, 0); err == nil {
+		t.Error("Expected error for no frames")
+	}
+	if _, err := muxAVIFSequence([]*avifStill{small, small}, []int{100}, 0); err == nil {
+		t.Error("Expected error for missing durations")
+	}
+	if _, err := muxAVIFSequence([]*avifStill{small, small}, []int{100, 0}, 0); err == nil {
+		t.Error("Expected error for zero duration")
+	}
+	if _, err := muxAVIFSequence([]*avifStill{small, large}, []int{100, 100}, 0); err == nil {
+		t.Error("Expected error for frames of different sizes")
+	}
+}
+
+func TestMuxAVIFSequenceMaxSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		valid         bool
+	}{
+		{avifSequenceMaxSize, avifSequenceMaxSize, true},
+		{avifSequenceMaxSize + 1, 16, false},
+		{16, avifSequenceMaxSize + 1, false},
+	}
+
+	for _, tt := range tests {
+		still, err := parseAVIFStill(buildTestAVIF([]byte("a"), []byte("b"), tt.width, tt.height))
+		if err != nil {
+			t.Fatalf("parseAVIFStill failed: %v", err)
+		}
+		buf, err := muxAVIFSequence([]*avifStill{still, still}, []int{100, 100}, 0)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("%dx%d: expected error", tt.width, tt.height)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%dx%d: muxAVIFSequence failed: %v", tt.width, tt.height, err)
+		}
+
+		// The track header keeps the size in 16.16 fixed point
+		tkhd, err := findISOPath(buf, "moov", "trak", "tkhd")
+		if err != nil {
+			t.Fatalf("Missing track header: %v", err)
+		}
+		_, _, data, _ := readISOFullBox(tkhd)
+		r := isoReader{buf: data}
+		r.skip(8 + 8 + 4 + 4 + 8 + 8 + 2 + 2 + 2 + 2 + 36) // Up to the width
+		if width, height := r.uint(4)>>16, r.uint(4)>>16; r.err != nil || width != uint64(tt.width) || height != uint64(tt.height) {
+			t.Errorf("Expected track size %dx%d, got %dx%d", tt.width, tt.height, width, height)
+		}
+	}
+}
+
+func TestReadAVIFSequenceStill(t *testing.T) {
+	seq, err := readAVIFSequence(buildTestAVIF([]byte("color"), []byte("alpha"), 16, 16))
+	if err != nil || seq != nil {
+		t.Errorf("Expected no sequence for a still image, got %+v, %v", seq, err)
+	}
+}
+
+func TestReadAVIFSequenceSampleCounts(t *testing.T) {
+	var frames []*avifStill
+	for _, name := range []string{"first", "second"} {
+		still, err := parseAVIFStill(buildTestAVIF([]byte(name+"-color"), nil, 16, 16))
+		if err != nil {
+			t.Fatalf("parseAVIFStill failed: %v", err)
+		}
+		frames = append(frames, still)
+	}
+	buf, err := muxAVIFSequence(frames, []int{100, 100}, 0)
+	if err != nil {
+		t.Fatalf("muxAVIFSequence failed: %v", err)
+	}
+
+	// The first stts entry follows the box type, the full box header and the entry count
+	stts := bytes.Index(buf, []byte("stts")) + 4 + isoFullBoxHeaderSize + 4
+	stsz := bytes.Index(buf, []byte("stsz")) + 4 + isoFullBoxHeaderSize
+
+	tests := []struct {
+		name   string
+		offset int
+		value  uint32
+	}{
+		{"stts count beyond stsz", stts, 0xFFFFFFFF},
+		{"stts count below stsz", stts, 1},
+		{"stsz count beyond the box", stsz + 4, 0xFFFFFFFF},
+		{"common sample size beyond the file", stsz, 0x7FFFFFFF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crafted := append([]byte{}, buf...)
+			binary.BigEndian.PutUint32(crafted[tt.offset:], tt.value)
+			if tt.offset == stsz {
+				// A common size with a huge count must not be taken at its word either
+				binary.BigEndian.PutUint32(crafted[stsz+4:], 0xFFFFFFFF)
+			}
+			if _, err := readAVIFSequence(crafted); err == nil {
+				t.Error("Expected error for crafted sample counts")
+			}
+		})
+	}
+}
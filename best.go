@@ -0,0 +1,122 @@
+package nextgenimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BestReason explains why ToBest kept a format
+type BestReason string
+
+const (
+	BestReasonSmaller  BestReason = "smaller"  // Both formats met the targets, the winner is smaller or WebP on a tie
+	BestReasonTargets  BestReason = "targets"  // The other format missed the savings, size or SSIM targets
+	BestReasonFallback BestReason = "fallback" // The other format could not encode the image
+)
+
+// BestResult describes the output ToBest kept
+type BestResult struct {
+	*ConversionResult                   // The winner, OutputFormat tells which one
+	OutputPath        string            // Path of the written file
+	Reason            BestReason        // Why the winner was kept
+	WebP              *ConversionResult // WebP result, nil when it failed
+	AVIF              *ConversionResult // AVIF result, nil when it failed
+	WebPError         error             // Why WebP failed, nil otherwise
+	AVIFError         error             // Why AVIF failed, nil otherwise
+}
+
+// ToBest converts an image to both WebP and AVIF and writes only the smaller one to outputDir,
+// named after the input with a .webp or .avif extension
+func (c *Converter) ToBest(inputPath, outputDir string) (*BestResult, error) {
+	return c.ToBestContext(context.Background(), inputPath, outputDir)
+}
+
+// ToBestContext is ToBest aborting when ctx is done.
+// On cancellation or deadline it returns ctx.Err() and leaves no file in outputDir.
+// As with ToWebPContext, a libvips call already running finishes in the background first.
+func (c *Converter) ToBestContext(ctx context.Context, inputPath, outputDir string) (*BestResult, error) {
+	// Read input file
+	inputBuffer, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	var best *BestResult
+	outputBuffer, err := runWithContext(ctx, func() ([]byte, error) {
+		outputBuffer, r, err := c.toBest(ctx, inputBuffer)
+		best = r
+		return outputBuffer, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	best.OutputPath = filepath.Join(outputDir, base+best.OutputFormat.Extensions()[0])
+	if err := writeOutputFile(ctx, best.OutputPath, outputBuffer); err != nil {
+		return nil, err
+	}
+
+	return best, nil
+}
+
+// toBest decodes the input once, encodes it as WebP and AVIF and returns the winner.
+// Animated GIFs that cannot become an AVIF sequence fall back to WebP.
+func (c *Converter) toBest(ctx context.Context, inputBuffer []byte) ([]byte, *BestResult, error) {
+	buffers, results, err := c.toTargets(ctx, inputBuffer, []OutputSpec{{Format: ImageTypeWebP}, {Format: ImageTypeAVIF}})
+	if err != nil {
+		return nil, nil, err
+	}
+	webpBuffer, webpResult, webpErr := buffers[0], results[0].Result, results[0].Err
+	avifBuffer, avifResult, avifErr := buffers[1], results[1].Result, results[1].Err
+	for _, err := range []error{webpErr, avifErr} {
+		if err := bestCandidateError(err); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	best := &BestResult{
+		WebP:      webpResult,
+		AVIF:      avifResult,
+		WebPError: webpErr,
+		AVIFError: avifErr,
+	}
+	switch {
+	case webpErr != nil && avifErr != nil:
+		return nil, nil, fmt.Errorf("neither WebP nor AVIF is usable: %w", errors.Join(webpErr, avifErr))
+	case avifErr != nil:
+		best.ConversionResult, best.Reason = webpResult, bestReasonFor(avifErr)
+		return webpBuffer, best, nil
+	case webpErr != nil:
+		best.ConversionResult, best.Reason = avifResult, bestReasonFor(webpErr)
+		return avifBuffer, best, nil
+	case len(avifBuffer) < len(webpBuffer):
+		best.ConversionResult, best.Reason = avifResult, BestReasonSmaller
+		return avifBuffer, best, nil
+	default:
+		// WebP wins ties, more clients decode it
+		best.ConversionResult, best.Reason = webpResult, BestReasonSmaller
+		return webpBuffer, best, nil
+	}
+}
+
+// bestCandidateError returns the errors that must abort ToBest rather than lose one format:
+// cancellation and invalid configuration. Encoding failures and missed targets return nil.
+func bestCandidateError(err error) error {
+	if err == nil || errors.As(err, new(*FormatError)) {
+		return nil
+	}
+	return err
+}
+
+// bestReasonFor explains a win from the error of the losing format
+func bestReasonFor(err error) BestReason {
+	if errors.Is(err, ErrEncodeFailed) || errors.Is(err, ErrDecodeFailed) || errors.Is(err, ErrImageTooLarge) {
+		return BestReasonFallback
+	}
+	return BestReasonTargets
+}
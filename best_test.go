@@ -0,0 +1,89 @@
+package nextgenimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBestCandidateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		abort  bool
+		reason BestReason
+	}{
+		{"not smaller", NewFormatError(&InsufficientSavingsError{InputSize: 100, OutputSize: 120}), false, BestReasonTargets},
+		{"SSIM floor", NewFormatError(fmt.Errorf("output does not reach SSIM 0.9900")), false, BestReasonTargets},
+		{"encode failed", newKindError(ErrEncodeFailed, errors.New("vips error")), false, BestReasonFallback},
+		{"too large", checkDimensions(20000, 100, webpMaxDimension), false, BestReasonFallback},
+		{"cancelled", context.Canceled, true, ""},
+		{"invalid config", fmt.Errorf("avif effort 10 is out of range 1-9"), true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := bestCandidateError(tt.err); (err != nil) != tt.abort {
+				t.Errorf("Expected abort=%v, got %v", tt.abort, err)
+			}
+			if tt.abort {
+				return
+			}
+			if reason := bestReasonFor(tt.err); reason != tt.reason {
+				t.Errorf("Expected reason %s, got %s", tt.reason, reason)
+			}
+		})
+	}
+}
+
+func TestToBest(t *testing.T) {
+	inputPaths := []string{"testdata/test_original.jpg", "testdata/test_original.png", "testdata/test_original.gif"}
+	for _, path := range inputPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Skip("Test file not found:", path)
+			return
+		}
+	}
+	converter := NewConverter(ConverterConfig{})
+
+	for _, inputPath := range inputPaths {
+		t.Run(filepath.Base(inputPath), func(t *testing.T) {
+			outputDir := t.TempDir()
+			best, err := converter.ToBest(inputPath, outputDir)
+			if errors.As(err, new(*FormatError)) {
+				t.Skipf("Neither format is usable: %v", err)
+			}
+			if err != nil {
+				t.Fatalf("ToBest failed: %v", err)
+			}
+
+			// Only the winner is written
+			entries, err := os.ReadDir(outputDir)
+			if err != nil {
+				t.Fatalf("Failed to list output: %v", err)
+			}
+			if len(entries) != 1 || filepath.Join(outputDir, entries[0].Name()) != best.OutputPath {
+				t.Errorf("Expected only %s in the output directory, got %v", best.OutputPath, entries)
+			}
+			detected, err := DetectImageType(best.OutputPath)
+			if err != nil || detected != best.OutputFormat {
+				t.Errorf("Expected a %s file, detected %s (%v)", best.OutputFormat, detected, err)
+			}
+
+			if best.Reason == BestReasonSmaller {
+				if best.WebP == nil || best.AVIF == nil {
+					t.Fatal("Expected both results when the smaller one wins")
+				}
+				for _, other := range []*ConversionResult{best.WebP, best.AVIF} {
+					if best.OutputSize > other.OutputSize {
+						t.Errorf("Winner %s (%d bytes) is larger than %s (%d bytes)", best.OutputFormat, best.OutputSize, other.OutputFormat, other.OutputSize)
+					}
+				}
+			}
+			t.Logf("%s won (%s): %d bytes", best.OutputFormat, best.Reason, best.OutputSize)
+		})
+	}
+}
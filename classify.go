@@ -0,0 +1,157 @@
+package nextgenimage
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// ContentClass labels what kind of content an image holds
+type ContentClass string
+
+const (
+	ContentClassPhoto      ContentClass = "photo"
+	ContentClassGraphic    ContentClass = "graphic"
+	ContentClassScreenshot ContentClass = "screenshot"
+)
+
+// Classification thresholds
+const (
+	classifyHardEdge        = 48   // Luma step between neighbors counted as a hard edge
+	photoMinColors          = 4096 // Photos have many distinct colors...
+	photoMaxFlatRatio       = 0.5  // ...and noise, so few neighbors are identical
+	screenshotMinEdgeRatio  = 0.02 // Text and UI have dense hard edges...
+	screenshotMinFlatRatio  = 0.6  // ...on flat backgrounds...
+	screenshotMaxAlphaRatio = 0.01 // ...and are opaque
+)
+
+// contentFeatures summarizes the pixels the classifier looks at
+type contentFeatures struct {
+	uniqueColors      int     // Distinct RGB colors among visible pixels
+	flatRatio         float64 // Neighbor pairs with identical luma
+	edgeRatio         float64 // Neighbor pairs with a hard luma edge
+	partialAlphaRatio float64 // Pixels that are neither opaque nor fully transparent
+}
+
+// classify labels content from its features
+func (f contentFeatures) classify() ContentClass {
+	switch {
+	case f.uniqueColors >= photoMinColors && f.flatRatio < photoMaxFlatRatio:
+		return ContentClassPhoto
+	case f.edgeRatio >= screenshotMinEdgeRatio && f.flatRatio >= screenshotMinFlatRatio &&
+		f.partialAlphaRatio < screenshotMaxAlphaRatio:
+		return ContentClassScreenshot
+	default:
+		return ContentClassGraphic
+	}
+}
+
+// measureContent computes the features of 8-bit pixels with 3 (RGB) or 4 (RGBA) bands
+func measureContent(pix []byte, width, height, bands int) contentFeatures {
+	var f contentFeatures
+	if width == 0 || height == 0 {
+		return f
+	}
+
+	// One bit per 24-bit color
+	seen := make([]uint64, 1<<24/64)
+	luma := make([]int, width*height)
+	partialAlpha := 0
+	for p := range luma {
+		px := pix[p*bands : p*bands+bands]
+		r, g, b := int(px[0]), int(px[1]), int(px[2])
+		luma[p] = (299*r + 587*g + 114*b) / 1000
+
+		if bands == 4 {
+			if px[3] == 0 {
+				continue
+			}
+			if px[3] < 255 {
+				partialAlpha++
+			}
+		}
+		color := r<<16 | g<<8 | b
+		if seen[color/64]&(1<<(color%64)) == 0 {
+			seen[color/64] |= 1 << (color % 64)
+			f.uniqueColors++
+		}
+	}
+
+	flat, edges, pairs := 0, 0, 0
+	countPair := func(a, b int) {
+		d := a - b
+		if d < 0 {
+			d = -d
+		}
+		switch {
+		case d == 0:
+			flat++
+		case d >= classifyHardEdge:
+			edges++
+		}
+		pairs++
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := y*width + x
+			if x+1 < width {
+				countPair(luma[p], luma[p+1])
+			}
+			if y+1 < height {
+				countPair(luma[p], luma[p+width])
+			}
+		}
+	}
+
+	if pairs > 0 {
+		f.flatRatio = float64(flat) / float64(pairs)
+		f.edgeRatio = float64(edges) / float64(pairs)
+	}
+	f.partialAlphaRatio = float64(partialAlpha) / float64(len(luma))
+	return f
+}
+
+// classifyContent reads the pixels of an image as 8-bit sRGB and classifies them
+func classifyContent(image *vips.ImageRef) (ContentClass, error) {
+	pixels, err := image.Copy()
+	if err != nil {
+		return "", err
+	}
+	defer pixels.Close()
+
+	if err := pixels.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return "", err
+	}
+	if pixels.BandFormat() != vips.BandFormatUchar {
+		if err := pixels.Cast(vips.BandFormatUchar); err != nil {
+			return "", err
+		}
+	}
+
+	bands := pixels.Bands()
+	if bands != 3 && bands != 4 {
+		return "", fmt.Errorf("unexpected band count %d", bands)
+	}
+	pix, err := pixels.ToBytes()
+	if err != nil {
+		return "", err
+	}
+
+	return measureContent(pix, pixels.Width(), pixels.Height(), bands).classify(), nil
+}
+
+// ClassifyContent decodes an image and labels it as a photo, a graphic or a screenshot,
+// the same way the automatic PNG strategy does
+func ClassifyContent(inputBuffer []byte) (ContentClass, error) {
+	image, err := vips.NewImageFromBuffer(inputBuffer)
+	if err != nil {
+		return "", fmt.Errorf("failed to load image: %w", newKindError(ErrDecodeFailed, err))
+	}
+	defer image.Close()
+
+	class, err := classifyContent(image)
+	if err != nil {
+		return "", fmt.Errorf("failed to classify content: %w", NewFormatError(err))
+	}
+	return class, nil
+}
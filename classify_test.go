@@ -0,0 +1,126 @@
+package nextgenimage
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// syntheticPixels builds RGB pixels from a per-pixel function
+func syntheticPixels(width, height int, pixel func(x, y int) (byte, byte, byte)) []byte {
+	pix := make([]byte, 0, width*height*3)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b := pixel(x, y)
+			pix = append(pix, r, g, b)
+		}
+	}
+	return pix
+}
+
+func TestClassifyContentFeatures(t *testing.T) {
+	const size = 128
+	rng := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name     string
+		pix      []byte
+		expected ContentClass
+	}{
+		{
+			// A gradient with sensor noise
+			name: "photo",
+			pix: syntheticPixels(size, size, func(x, y int) (byte, byte, byte) {
+				noise := func() byte { return byte(rng.Intn(16)) }
+				return byte(x) + noise(), byte(y) + noise(), byte(x+y)/2 + noise()
+			}),
+			expected: ContentClassPhoto,
+		},
+		{
+			// Smooth bands of a few colors, like a logo or a chart
+			name: "graphic",
+			pix: syntheticPixels(size, size, func(x, y int) (byte, byte, byte) {
+				return byte(x * 2), 64, 192
+			}),
+			expected: ContentClassGraphic,
+		},
+		{
+			// Dark strokes every few pixels on a white background, like text
+			name: "screenshot",
+			pix: syntheticPixels(size, size, func(x, y int) (byte, byte, byte) {
+				if y%8 < 5 && x%6 == 0 {
+					return 0, 0, 0
+				}
+				return 255, 255, 255
+			}),
+			expected: ContentClassScreenshot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features := measureContent(tt.pix, size, size, 3)
+			if class := features.classify(); class != tt.expected {
+				t.Errorf("Expected %s, got %s (%+v)", tt.expected, class, features)
+			}
+		})
+	}
+}
+
+func TestMeasureContentAlpha(t *testing.T) {
+	// Half transparent, a quarter semi-transparent, a quarter opaque
+	pix := []byte{
+		10, 10, 10, 0, 20, 20, 20, 0,
+		30, 30, 30, 128, 40, 40, 40, 255,
+	}
+	features := measureContent(pix, 2, 2, 4)
+	if features.uniqueColors != 2 {
+		t.Errorf("Expected transparent pixels to be ignored, got %d colors", features.uniqueColors)
+	}
+	if features.partialAlphaRatio != 0.25 {
+		t.Errorf("Expected a partial alpha ratio of 0.25, got %f", features.partialAlphaRatio)
+	}
+}
+
+func TestPNGAuto(t *testing.T) {
+	inputPath := "testdata/test_original.png"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+	tempDir := t.TempDir()
+
+	input, err := os.ReadFile(inputPath)
+	if err != nil {
+		t.Fatalf("Failed to read input: %v", err)
+	}
+	class, err := ClassifyContent(input)
+	if err != nil {
+		t.Fatalf("Classification failed: %v", err)
+	}
+	t.Logf("Classified %s as %s", inputPath, class)
+
+	config := ConverterConfig{}
+	config.PNGToWebP.Auto = true
+	config.PNGToAVIF.Auto = true
+	converter := NewConverter(config)
+
+	for name, convert := range map[string]func(in, out string) (*ConversionResult, error){
+		"webp": converter.ToWebP,
+		"avif": converter.ToAVIF,
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := convert(inputPath, filepath.Join(tempDir, "test."+name))
+			if err != nil {
+				t.Skipf("Conversion not applicable: %v", err)
+			}
+			if result.ContentClass != class {
+				t.Errorf("Expected content class %s, got %s", class, result.ContentClass)
+			}
+			if (class == ContentClassPhoto) != (result.Mode == EncoderModeLossy) {
+				t.Errorf("Unexpected mode %s for %s content", result.Mode, class)
+			}
+		})
+	}
+}
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"github.com/ideamans/go-next-gen-image"
@@ -11,40 +9,82 @@ import (
 )
 
 var (
-	avifCQ int
+	avifCQ               int
+	avifMaxBytes         int64
+	avifMinCQ            int
+	avifSSIM             float64
+	avifMinSavingsBytes  int64
+	avifMinSavingsRatio  float64
+	avifEffort           int
+	avifBitDepth         int
+	avifChroma           string
+	avifEncoder          string
+	avifPNGLossy         bool
+	avifPNGCQ            int
+	avifPNGAlphaLevels   int
+	avifPNGTryAll        bool
+	avifPNGAuto          bool
+	avifMatchSubsampling bool
+	avifAdaptiveQuality  bool
 )
 
+// avifChromaChoices are the values of --chroma-subsampling
+var avifChromaChoices = []choice[nextgenimage.AVIFChromaSubsampling]{
+	{"auto", nextgenimage.AVIFSubsamplingAuto},
+	{"444", nextgenimage.AVIFSubsampling444},
+	{"420", nextgenimage.AVIFSubsampling420},
+}
+
+// avifEncoderChoices are the values of --encoder
+var avifEncoderChoices = []choice[nextgenimage.AVIFEncoder]{
+	{"auto", nextgenimage.AVIFEncoderAuto},
+	{"aom", nextgenimage.AVIFEncoderAOM},
+	{"rav1e", nextgenimage.AVIFEncoderRav1e},
+	{"svt", nextgenimage.AVIFEncoderSVT},
+}
+
 var avifCmd = &cobra.Command{
 	Use:   "avif <input-file> <output-file>",
 	Short: "Convert image to AVIF format",
-	Long: `Convert JPEG or PNG images to AVIF format.
+	Long: `Convert JPEG, PNG, or GIF images to AVIF format.
 	
 JPEG images are converted using lossy compression with configurable CQ value.
 PNG images are converted using lossless compression.
-GIF to AVIF conversion is not supported.`,
+GIF images are converted using lossy compression with the same CQ value.
+Animated GIFs become AVIF image sequences preserving frame timing and loop count.`,
 	Args: cobra.ExactArgs(2),
 	RunE: runAVIF,
 }
 
 func init() {
-	avifCmd.Flags().IntVar(&avifCQ, "cq", 25, "JPEG to AVIF CQ value (1-63, lower is better quality)")
+	avifCmd.Flags().IntVar(&avifCQ, "cq", 25, "JPEG and GIF to AVIF CQ value (1-100, higher is better quality)")
+	avifCmd.Flags().Int64Var(&avifMaxBytes, "max-bytes", 0, "Maximum output size in bytes, searching the highest lossy CQ that fits (0 disables)")
+	avifCmd.Flags().Float64Var(&avifSSIM, "target-ssim", 0, "Pick the lowest lossy CQ whose output reaches this SSIM (0-1, 0 disables)")
+	avifCmd.Flags().IntVar(&avifMinCQ, "min-cq", 1, "Lowest CQ the --max-bytes and --target-ssim searches may pick (1-100)")
+	avifCmd.Flags().BoolVar(&avifPNGLossy, "png-lossy", false, "Use lossy compression for PNG to AVIF, for photos saved as PNG")
+	avifCmd.Flags().IntVar(&avifPNGCQ, "png-cq", 25, "PNG to AVIF lossy CQ value (1-100, higher is better quality)")
+	avifCmd.Flags().IntVar(&avifPNGAlphaLevels, "png-alpha-levels", 0, "Quantize PNG to AVIF lossy alpha to this many levels (2-256, 0 keeps it exact)")
+	avifCmd.Flags().BoolVar(&avifPNGTryAll, "png-try-all", false, "Try lossless and lossy compression for PNG to AVIF and keep the smaller")
+	avifCmd.Flags().BoolVar(&avifPNGAuto, "png-auto", false, "Classify PNG content and pick lossless or lossy compression for AVIF")
+	avifCmd.Flags().BoolVar(&avifAdaptiveQuality, "adaptive-quality", false, "Cap the JPEG CQ by the estimated source quality")
+	avifCmd.Flags().IntVar(&avifEffort, "effort", 5, "Encoder CPU effort (1-9, higher is slower and smaller)")
+	avifCmd.Flags().IntVar(&avifBitDepth, "bit-depth", 8, "Bits per sample (8, 10 or 12)")
+	avifCmd.Flags().StringVar(&avifChroma, "chroma-subsampling", "auto", "Lossy chroma subsampling (auto, 444 or 420)")
+	avifCmd.Flags().StringVar(&avifEncoder, "encoder", "auto", "AV1 encoder (auto, aom, rav1e or svt)")
+	avifCmd.Flags().BoolVar(&avifMatchSubsampling, "match-subsampling", true, "Encode 4:4:4 JPEG sources with full chroma and others with 4:2:0 (false leaves it to libvips)")
+	avifCmd.Flags().Int64Var(&avifMinSavingsBytes, "min-savings-bytes", 0, "Reject outputs saving fewer bytes than this over the input")
+	avifCmd.Flags().Float64Var(&avifMinSavingsRatio, "min-savings-ratio", 0, "Reject outputs saving less than this fraction of the input size (0-1)")
 }
 
 func runAVIF(cmd *cobra.Command, args []string) error {
 	inputPath := args[0]
 	outputPath := args[1]
 
-	// Validate CQ
-	if avifCQ < 1 || avifCQ > 63 {
-		return fmt.Errorf("CQ must be between 1 and 63")
+	if err := validateAVIFFlags(); err != nil {
+		return err
 	}
-
-	// Check if input file exists
-	if _, err := os.Stat(inputPath); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("input file not found: %s", inputPath)
-		}
-		return fmt.Errorf("failed to access input file: %w", err)
+	if err := checkInputFile(inputPath); err != nil {
+		return err
 	}
 
 	// Log start
@@ -52,60 +92,101 @@ func runAVIF(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Converting %s to AVIF...\n", filepath.Base(inputPath))
 	}
 	if verbose {
-		fmt.Printf("[INFO] Input: %s\n", inputPath)
-		fmt.Printf("[INFO] Output: %s\n", outputPath)
-		fmt.Printf("[INFO] CQ: %d\n", avifCQ)
+		logAVIFSettings(inputPath, outputPath)
 	}
 
-	// Create converter with configuration
-	config := nextgenimage.ConverterConfig{}
-	config.JPEGToAVIF.CQ = avifCQ
-
-	converter := nextgenimage.NewConverter(config)
-
-	// Get file sizes for comparison
-	inputInfo, _ := os.Stat(inputPath)
-	inputSize := inputInfo.Size()
+	converter := nextgenimage.NewConverter(avifConfig())
 
 	// Perform conversion
-	err := converter.ToAVIF(inputPath, outputPath)
-	if err != nil {
-		var formatErr *nextgenimage.FormatError
-		if errors.As(err, &formatErr) {
-			if !quiet {
-				fmt.Printf("✗ %s → %s (FormatError: %v)\n", 
-					filepath.Base(inputPath), 
-					filepath.Base(outputPath),
-					err)
-			}
-			return formatErr
-		}
-		return fmt.Errorf("conversion failed: %w", err)
-	}
-
-	// Get output file size
-	outputInfo, err := os.Stat(outputPath)
+	result, err := converter.ToAVIF(inputPath, outputPath)
 	if err != nil {
-		return fmt.Errorf("failed to stat output file: %w", err)
+		return conversionError(inputPath, outputPath, err)
 	}
-	outputSize := outputInfo.Size()
 
-	// Calculate size reduction
-	reduction := float64(inputSize-outputSize) / float64(inputSize) * 100
+	reportConversion(inputPath, outputPath, result)
+	return nil
+}
 
-	// Log success
-	if !quiet {
-		fmt.Printf("✓ %s → %s (%s → %s, %.1f%%)\n",
-			filepath.Base(inputPath),
-			filepath.Base(outputPath),
-			formatBytes(inputSize),
-			formatBytes(outputSize),
-			reduction)
+// validateAVIFFlags checks the avif command flags
+func validateAVIFFlags() error {
+	if err := checkRanges(
+		intRange{"CQ", avifCQ, 1, 100},
+		intRange{"min CQ", avifMinCQ, 1, 100},
+		intRange{"PNG CQ", avifPNGCQ, 1, 100},
+		intRange{"effort", avifEffort, 1, 9},
+	); err != nil {
+		return err
 	}
+	if avifPNGAlphaLevels != 0 {
+		if err := checkRanges(intRange{"PNG alpha levels", avifPNGAlphaLevels, 2, 256}); err != nil {
+			return err
+		}
+	}
+	if avifBitDepth != 8 && avifBitDepth != 10 && avifBitDepth != 12 {
+		return fmt.Errorf("bit depth must be 8, 10 or 12")
+	}
+	if _, err := parseChoice("chroma subsampling", avifChroma, avifChromaChoices); err != nil {
+		return err
+	}
+	if _, err := parseChoice("encoder", avifEncoder, avifEncoderChoices); err != nil {
+		return err
+	}
+	if err := checkTargetFlags(avifMaxBytes, avifSSIM); err != nil {
+		return err
+	}
+	return checkSavingsFlags(avifMinSavingsBytes, avifMinSavingsRatio)
+}
 
-	if verbose {
-		fmt.Printf("[INFO] Successfully converted: %s → %s\n", inputPath, outputPath)
+// logAVIFSettings prints the settings of an avif conversion in verbose mode
+func logAVIFSettings(inputPath, outputPath string) {
+	fmt.Printf("[INFO] Input: %s\n", inputPath)
+	fmt.Printf("[INFO] Output: %s\n", outputPath)
+	fmt.Printf("[INFO] CQ: %d\n", avifCQ)
+	if avifPNGLossy || avifPNGTryAll || avifPNGAuto {
+		fmt.Printf("[INFO] PNG lossy CQ: %d, alpha levels: %d\n", avifPNGCQ, avifPNGAlphaLevels)
+	}
+	fmt.Printf("[INFO] Effort: %d, bit depth: %d, chroma subsampling: %s, encoder: %s\n",
+		avifEffort, avifBitDepth, avifChroma, avifEncoder)
+	if avifSSIM > 0 {
+		fmt.Printf("[INFO] Target SSIM: %.4f (min CQ: %d)\n", avifSSIM, avifMinCQ)
 	}
+	if avifMaxBytes > 0 {
+		fmt.Printf("[INFO] Max bytes: %d (min CQ: %d)\n", avifMaxBytes, avifMinCQ)
+	}
+	if avifMinSavingsBytes > 0 || avifMinSavingsRatio > 0 {
+		fmt.Printf("[INFO] Min savings: %d bytes, %.1f%%\n", avifMinSavingsBytes, avifMinSavingsRatio*100)
+	}
+}
 
-	return nil
-}
\ No newline at end of file
+// avifConfig builds the converter configuration from the avif command flags
+func avifConfig() nextgenimage.ConverterConfig {
+	config := nextgenimage.ConverterConfig{}
+	config.JPEGToAVIF.CQ = avifCQ
+	config.GIFToAVIF.CQ = avifCQ
+	// The choices were checked by validateAVIFFlags
+	chroma, _ := parseChoice("chroma subsampling", avifChroma, avifChromaChoices)
+	encoder, _ := parseChoice("encoder", avifEncoder, avifEncoderChoices)
+	options := nextgenimage.AVIFEncoderOptions{
+		Effort:            avifEffort,
+		BitDepth:          avifBitDepth,
+		ChromaSubsampling: chroma,
+		Encoder:           encoder,
+	}
+	config.JPEGToAVIF.AVIFEncoderOptions = options
+	config.JPEGToAVIF.AdaptiveQuality = avifAdaptiveQuality
+	config.JPEGToAVIF.Subsampling = subsamplingPolicy(avifMatchSubsampling)
+	config.PNGToAVIF.AVIFEncoderOptions = options
+	config.GIFToAVIF.AVIFEncoderOptions = options
+	config.PNGToAVIF.Lossy = avifPNGLossy
+	config.PNGToAVIF.CQ = avifPNGCQ
+	config.PNGToAVIF.AlphaLevels = avifPNGAlphaLevels
+	config.PNGToAVIF.TryAll = avifPNGTryAll
+	config.PNGToAVIF.Auto = avifPNGAuto
+	config.TargetSize.MaxBytes = avifMaxBytes
+	config.TargetSize.MinQuality = avifMinCQ
+	config.TargetSSIM.Score = avifSSIM
+	config.TargetSSIM.MinQuality = avifMinCQ
+	config.MinSavingsBytes = avifMinSavingsBytes
+	config.MinSavingsRatio = avifMinSavingsRatio
+	return config
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/ideamans/go-next-gen-image"
+)
+
+// Exit codes, one per error kind so scripts can tell them apart
+const (
+	exitOK                = 0
+	exitError             = 1 // Usage, I/O and other system errors
+	exitFormatError       = 2 // Any other FormatError
+	exitUnsupportedFormat = 3
+	exitNotSmaller        = 4
+	exitDecodeFailed      = 5
+	exitEncodeFailed      = 6
+	exitImageTooLarge     = 7
+)
+
+// exitCode maps a command error to the process exit code
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, nextgenimage.ErrUnsupportedFormat):
+		return exitUnsupportedFormat
+	case errors.Is(err, nextgenimage.ErrNotSmaller):
+		return exitNotSmaller
+	case errors.Is(err, nextgenimage.ErrDecodeFailed):
+		return exitDecodeFailed
+	case errors.Is(err, nextgenimage.ErrEncodeFailed):
+		return exitEncodeFailed
+	case errors.Is(err, nextgenimage.ErrImageTooLarge):
+		return exitImageTooLarge
+	}
+
+	var formatErr *nextgenimage.FormatError
+	if errors.As(err, &formatErr) {
+		return exitFormatError
+	}
+	return exitError
+}
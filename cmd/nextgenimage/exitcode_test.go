@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ideamans/go-next-gen-image"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"Success", nil, exitOK},
+		{"System error", errors.New("permission denied"), exitError},
+		{"Format error", nextgenimage.NewFormatError(errors.New("bad data")), exitFormatError},
+		{"Unsupported format", nextgenimage.NewFormatError(fmt.Errorf("bmp: %w", nextgenimage.ErrUnsupportedFormat)), exitUnsupportedFormat},
+		{"Not smaller", nextgenimage.NewFormatError(&nextgenimage.InsufficientSavingsError{InputSize: 10, OutputSize: 12}), exitNotSmaller},
+		{"Decode failed", fmt.Errorf("conversion failed: %w", nextgenimage.ErrDecodeFailed), exitDecodeFailed},
+		{"Encode failed", nextgenimage.ErrEncodeFailed, exitEncodeFailed},
+		{"Image too large", nextgenimage.NewFormatError(&nextgenimage.ImageTooLargeError{Width: 20000, Height: 10, MaxWidth: 16383, MaxHeight: 16383}), exitImageTooLarge},
+	}
+
+	codes := map[int]string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("Expected exit code %d, got %d", tt.want, got)
+			}
+		})
+		if other, ok := codes[tt.want]; ok {
+			t.Errorf("%s and %s share exit code %d", tt.name, other, tt.want)
+		}
+		codes[tt.want] = tt.name
+	}
+}
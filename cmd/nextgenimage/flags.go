@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ideamans/go-next-gen-image"
+)
+
+// intRange is an inclusive range an integer flag must fall in
+type intRange struct {
+	name     string
+	value    int
+	min, max int
+}
+
+// checkRanges returns an error for the first flag outside its range
+func checkRanges(ranges ...intRange) error {
+	for _, r := range ranges {
+		if r.value < r.min || r.value > r.max {
+			return fmt.Errorf("%s must be between %d and %d", r.name, r.min, r.max)
+		}
+	}
+	return nil
+}
+
+// choice is one accepted value of a string flag
+type choice[T any] struct {
+	name  string
+	value T
+}
+
+// parseChoice returns the value a string flag names, or an error listing the accepted names
+func parseChoice[T any](flag, value string, choices []choice[T]) (T, error) {
+	names := make([]string, len(choices))
+	for i, c := range choices {
+		if c.name == value {
+			return c.value, nil
+		}
+		names[i] = c.name
+	}
+	var zero T
+	return zero, fmt.Errorf("%s must be one of %s", flag, strings.Join(names, ", "))
+}
+
+// subsamplingPolicy returns the chroma subsampling policy of the --match-subsampling flag
+func subsamplingPolicy(match bool) nextgenimage.SubsamplingPolicy {
+	if match {
+		return nextgenimage.SubsamplingMatchSource
+	}
+	return nextgenimage.SubsamplingEncoderDefault
+}
+
+// checkTargetFlags validates the --max-bytes and --target-ssim flags
+func checkTargetFlags(maxBytes int64, targetSSIM float64) error {
+	if maxBytes < 0 {
+		return fmt.Errorf("max bytes must not be negative")
+	}
+	if targetSSIM < 0 || targetSSIM > 1 {
+		return fmt.Errorf("target SSIM must be between 0 and 1")
+	}
+	return nil
+}
+
+// checkSavingsFlags validates the --min-savings-bytes and --min-savings-ratio flags
+func checkSavingsFlags(minBytes int64, minRatio float64) error {
+	if minBytes < 0 {
+		return fmt.Errorf("min savings bytes must not be negative")
+	}
+	if minRatio < 0 || minRatio > 1 {
+		return fmt.Errorf("min savings ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// checkInputFile returns an error when the input file cannot be accessed
+func checkInputFile(inputPath string) error {
+	if _, err := os.Stat(inputPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("input file not found: %s", inputPath)
+		}
+		return fmt.Errorf("failed to access input file: %w", err)
+	}
+	return nil
+}
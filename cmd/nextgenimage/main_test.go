@@ -102,7 +102,8 @@ to next-generation formats (WebP, AVIF) following best practices.`,
 	
 JPEG images are converted using lossy compression with configurable quality.
 PNG images are converted using lossless compression, with optional near-lossless.
-GIF images are converted to animated WebP preserving animation properties.`,
+GIF images are converted to animated WebP preserving animation properties,
+losslessly by default, with optional lossy compression.`,
 				Args: cobra.ExactArgs(2),
 				RunE: runWebP,
 			}
@@ -149,9 +150,15 @@ func TestAVIFCommand(t *testing.T) {
 		},
 		{
 			name:          "invalid CQ",
-			args:          []string{"avif", "--cq", "100", "input.jpg", "output.avif"},
+			args:          []string{"avif", "--cq", "101", "input.jpg", "output.avif"},
 			expectError:   true,
-			errorContains: "CQ must be between 1 and 63",
+			errorContains: "CQ must be between 1 and 100",
+		},
+		{
+			name:          "invalid encoder",
+			args:          []string{"avif", "--encoder", "x265", "input.jpg", "output.avif"},
+			expectError:   true,
+			errorContains: "encoder must be one of auto, aom, rav1e, svt",
 		},
 		{
 			name:          "non-existent input file",
@@ -183,15 +190,17 @@ to next-generation formats (WebP, AVIF) following best practices.`,
 			avifTestCmd := &cobra.Command{
 				Use:   "avif <input-file> <output-file>",
 				Short: "Convert image to AVIF format",
-				Long: `Convert JPEG or PNG images to AVIF format.
+				Long: `Convert JPEG, PNG, or GIF images to AVIF format.
 	
 JPEG images are converted using lossy compression with configurable CQ value.
 PNG images are converted using lossless compression.
-GIF to AVIF conversion is not supported.`,
+GIF images are converted using lossy compression with the same CQ value.
+Animated GIFs become AVIF image sequences preserving frame timing and loop count.`,
 				Args: cobra.ExactArgs(2),
 				RunE: runAVIF,
 			}
-			avifTestCmd.Flags().IntVar(&avifCQ, "cq", 25, "JPEG to AVIF CQ value (1-63, lower is better quality)")
+			avifTestCmd.Flags().IntVar(&avifCQ, "cq", 25, "JPEG and GIF to AVIF CQ value (1-100, higher is better quality)")
+			avifTestCmd.Flags().StringVar(&avifEncoder, "encoder", "auto", "AV1 encoder (auto, aom, rav1e or svt)")
 			
 			cmd.AddCommand(avifTestCmd)
 
@@ -250,7 +259,8 @@ to next-generation formats (WebP, AVIF) following best practices.`,
 	
 JPEG images are converted using lossy compression with configurable quality.
 PNG images are converted using lossless compression, with optional near-lossless.
-GIF images are converted to animated WebP preserving animation properties.`,
+GIF images are converted to animated WebP preserving animation properties,
+losslessly by default, with optional lossy compression.`,
 		Args: cobra.ExactArgs(2),
 		RunE: runWebP,
 	}
@@ -314,15 +324,16 @@ to next-generation formats (WebP, AVIF) following best practices.`,
 	avifTestCmd := &cobra.Command{
 		Use:   "avif <input-file> <output-file>",
 		Short: "Convert image to AVIF format",
-		Long: `Convert JPEG or PNG images to AVIF format.
+		Long: `Convert JPEG, PNG, or GIF images to AVIF format.
 	
 JPEG images are converted using lossy compression with configurable CQ value.
 PNG images are converted using lossless compression.
-GIF to AVIF conversion is not supported.`,
+GIF images are converted using lossy compression with the same CQ value.
+Animated GIFs become AVIF image sequences preserving frame timing and loop count.`,
 		Args: cobra.ExactArgs(2),
 		RunE: runAVIF,
 	}
-	avifTestCmd.Flags().IntVar(&avifCQ, "cq", 25, "JPEG to AVIF CQ value (1-63, lower is better quality)")
+	avifTestCmd.Flags().IntVar(&avifCQ, "cq", 25, "JPEG and GIF to AVIF CQ value (1-100, higher is better quality)")
 	
 	cmd.AddCommand(avifTestCmd)
 
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ideamans/go-next-gen-image"
+)
+
+// conversionError reports a failed conversion, returning FormatErrors as they are so the
+// exit code tells them apart
+func conversionError(inputPath, outputPath string, err error) error {
+	var formatErr *nextgenimage.FormatError
+	if errors.As(err, &formatErr) {
+		if !quiet {
+			fmt.Printf("✗ %s → %s (FormatError: %v)\n",
+				filepath.Base(inputPath),
+				filepath.Base(outputPath),
+				err)
+		}
+		return formatErr
+	}
+	return fmt.Errorf("conversion failed: %w", err)
+}
+
+// reportConversion prints the outcome of a successful conversion
+func reportConversion(inputPath, outputPath string, result *nextgenimage.ConversionResult) {
+	if !quiet {
+		fmt.Printf("✓ %s → %s (%s → %s, %.1f%%)\n",
+			filepath.Base(inputPath),
+			filepath.Base(outputPath),
+			formatBytes(result.InputSize),
+			formatBytes(result.OutputSize),
+			result.SizeReduction())
+	}
+
+	if verbose {
+		fmt.Printf("[INFO] Dimensions: %dx%d, frames: %d\n", result.Width, result.Height, result.Frames)
+		fmt.Printf("[INFO] Mode: %s, quality: %d\n", result.Mode, result.Quality)
+		if result.SourceQuality > 0 {
+			fmt.Printf("[INFO] Estimated source quality: %d\n", result.SourceQuality)
+		}
+		if result.SourceSubsampling != "" {
+			fmt.Printf("[INFO] Chroma subsampling: %s → %s\n", result.SourceSubsampling, result.Subsampling)
+		}
+		if result.ContentClass != "" {
+			fmt.Printf("[INFO] Content: %s\n", result.ContentClass)
+		}
+		if result.SSIM > 0 {
+			fmt.Printf("[INFO] SSIM: %.4f\n", result.SSIM)
+		}
+		fmt.Printf("[INFO] Elapsed: %s\n", result.Elapsed)
+		fmt.Printf("[INFO] Successfully converted: %s → %s\n", inputPath, outputPath)
+	}
+}
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"github.com/ideamans/go-next-gen-image"
@@ -13,8 +11,38 @@ import (
 var (
 	webpQuality         int
 	webpTryNearLossless bool
+	webpGIFLossy        bool
+	webpGIFQuality      int
+	webpGIFAlphaQuality int
+	webpGIFTryBoth      bool
+	webpMaxBytes        int64
+	webpMinQuality      int
+	webpTargetSSIM      float64
+	webpMinSavingsBytes int64
+	webpMinSavingsRatio float64
+	webpEffort          int
+	webpPreset          string
+	webpSmartSubsample  bool
+	webpMatchSubsample  bool
+	webpNearLossless    int
+	webpPNGLossy        bool
+	webpPNGQuality      int
+	webpPNGAlphaQuality int
+	webpPNGTryAll       bool
+	webpPNGAuto         bool
+	webpAdaptiveQuality bool
 )
 
+// webpPresetChoices are the values of --preset
+var webpPresetChoices = []choice[nextgenimage.WebPPreset]{
+	{"default", nextgenimage.WebPPresetDefault},
+	{"picture", nextgenimage.WebPPresetPicture},
+	{"photo", nextgenimage.WebPPresetPhoto},
+	{"drawing", nextgenimage.WebPPresetDrawing},
+	{"icon", nextgenimage.WebPPresetIcon},
+	{"text", nextgenimage.WebPPresetText},
+}
+
 var webpCmd = &cobra.Command{
 	Use:   "webp <input-file> <output-file>",
 	Short: "Convert image to WebP format",
@@ -22,7 +50,8 @@ var webpCmd = &cobra.Command{
 	
 JPEG images are converted using lossy compression with configurable quality.
 PNG images are converted using lossless compression, with optional near-lossless.
-GIF images are converted to animated WebP preserving animation properties.`,
+GIF images are converted to animated WebP preserving animation properties,
+losslessly by default, with optional lossy compression.`,
 	Args: cobra.ExactArgs(2),
 	RunE: runWebP,
 }
@@ -30,23 +59,37 @@ GIF images are converted to animated WebP preserving animation properties.`,
 func init() {
 	webpCmd.Flags().IntVarP(&webpQuality, "quality", "q", 80, "JPEG to WebP quality (1-100)")
 	webpCmd.Flags().BoolVar(&webpTryNearLossless, "try-near-lossless", false, "Try near-lossless compression for PNG to WebP")
+	webpCmd.Flags().BoolVar(&webpGIFLossy, "gif-lossy", false, "Use lossy compression for GIF to WebP")
+	webpCmd.Flags().IntVar(&webpGIFQuality, "gif-quality", 80, "GIF to WebP lossy quality (1-100)")
+	webpCmd.Flags().IntVar(&webpGIFAlphaQuality, "gif-alpha-quality", 100, "GIF to WebP lossy alpha quality (1-100, lower compresses alpha more)")
+	webpCmd.Flags().Int64Var(&webpMaxBytes, "max-bytes", 0, "Maximum output size in bytes, searching the highest lossy quality that fits (0 disables)")
+	webpCmd.Flags().IntVar(&webpMinQuality, "min-quality", 1, "Lowest quality the --max-bytes and --target-ssim searches may pick (1-100)")
+	webpCmd.Flags().Float64Var(&webpTargetSSIM, "target-ssim", 0, "Pick the lowest lossy quality whose output reaches this SSIM (0-1, 0 disables)")
+	webpCmd.Flags().BoolVar(&webpGIFTryBoth, "gif-try-both", false, "Try lossless and lossy compression for GIF to WebP and keep the smaller")
+	webpCmd.Flags().BoolVar(&webpAdaptiveQuality, "adaptive-quality", false, "Cap the JPEG quality by the estimated source quality")
+	webpCmd.Flags().IntVar(&webpEffort, "effort", 4, "Reduction effort (1-6, higher is slower and smaller)")
+	webpCmd.Flags().StringVar(&webpPreset, "preset", "default", "libwebp preset (default, picture, photo, drawing, icon or text)")
+	webpCmd.Flags().BoolVar(&webpSmartSubsample, "smart-subsample", false, "Use sharp YUV conversion for lossy output")
+	webpCmd.Flags().BoolVar(&webpMatchSubsample, "match-subsampling", true, "Use sharp YUV conversion for 4:4:4 JPEG sources (false leaves it to --smart-subsample)")
+	webpCmd.Flags().BoolVar(&webpPNGLossy, "png-lossy", false, "Use lossy compression for PNG to WebP, for photos saved as PNG")
+	webpCmd.Flags().IntVar(&webpPNGQuality, "png-quality", 80, "PNG to WebP lossy quality (1-100)")
+	webpCmd.Flags().IntVar(&webpPNGAlphaQuality, "png-alpha-quality", 100, "PNG to WebP lossy alpha quality (1-100, lower compresses alpha more)")
+	webpCmd.Flags().BoolVar(&webpPNGTryAll, "png-try-all", false, "Try lossless, near-lossless and lossy compression for PNG to WebP and keep the smallest")
+	webpCmd.Flags().BoolVar(&webpPNGAuto, "png-auto", false, "Classify PNG content and pick lossless, near-lossless or lossy compression for WebP")
+	webpCmd.Flags().IntVar(&webpNearLossless, "near-lossless-level", 100, "Near-lossless preprocessing level for PNG (1-100, lower is smaller)")
+	webpCmd.Flags().Int64Var(&webpMinSavingsBytes, "min-savings-bytes", 0, "Reject outputs saving fewer bytes than this over the input")
+	webpCmd.Flags().Float64Var(&webpMinSavingsRatio, "min-savings-ratio", 0, "Reject outputs saving less than this fraction of the input size (0-1)")
 }
 
 func runWebP(cmd *cobra.Command, args []string) error {
 	inputPath := args[0]
 	outputPath := args[1]
 
-	// Validate quality
-	if webpQuality < 1 || webpQuality > 100 {
-		return fmt.Errorf("quality must be between 1 and 100")
+	if err := validateWebPFlags(); err != nil {
+		return err
 	}
-
-	// Check if input file exists
-	if _, err := os.Stat(inputPath); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("input file not found: %s", inputPath)
-		}
-		return fmt.Errorf("failed to access input file: %w", err)
+	if err := checkInputFile(inputPath); err != nil {
+		return err
 	}
 
 	// Log start
@@ -54,66 +97,104 @@ func runWebP(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Converting %s to WebP...\n", filepath.Base(inputPath))
 	}
 	if verbose {
-		fmt.Printf("[INFO] Input: %s\n", inputPath)
-		fmt.Printf("[INFO] Output: %s\n", outputPath)
-		fmt.Printf("[INFO] Quality: %d\n", webpQuality)
-		if webpTryNearLossless {
-			fmt.Printf("[INFO] Near-lossless: enabled\n")
-		}
+		logWebPSettings(inputPath, outputPath)
 	}
 
-	// Create converter with configuration
-	config := nextgenimage.ConverterConfig{}
-	config.JPEGToWebP.Quality = webpQuality
-	config.PNGToWebP.TryNearLossless = webpTryNearLossless
-
-	converter := nextgenimage.NewConverter(config)
-
-	// Get file sizes for comparison
-	inputInfo, _ := os.Stat(inputPath)
-	inputSize := inputInfo.Size()
+	converter := nextgenimage.NewConverter(webpConfig())
 
 	// Perform conversion
-	err := converter.ToWebP(inputPath, outputPath)
-	if err != nil {
-		var formatErr *nextgenimage.FormatError
-		if errors.As(err, &formatErr) {
-			if !quiet {
-				fmt.Printf("✗ %s → %s (FormatError: %v)\n", 
-					filepath.Base(inputPath), 
-					filepath.Base(outputPath),
-					err)
-			}
-			return formatErr
-		}
-		return fmt.Errorf("conversion failed: %w", err)
-	}
-
-	// Get output file size
-	outputInfo, err := os.Stat(outputPath)
+	result, err := converter.ToWebP(inputPath, outputPath)
 	if err != nil {
-		return fmt.Errorf("failed to stat output file: %w", err)
+		return conversionError(inputPath, outputPath, err)
 	}
-	outputSize := outputInfo.Size()
 
-	// Calculate size reduction
-	reduction := float64(inputSize-outputSize) / float64(inputSize) * 100
+	reportConversion(inputPath, outputPath, result)
+	return nil
+}
 
-	// Log success
-	if !quiet {
-		fmt.Printf("✓ %s → %s (%s → %s, %.1f%%)\n",
-			filepath.Base(inputPath),
-			filepath.Base(outputPath),
-			formatBytes(inputSize),
-			formatBytes(outputSize),
-			reduction)
+// validateWebPFlags checks the webp command flags
+func validateWebPFlags() error {
+	if err := checkRanges(
+		intRange{"quality", webpQuality, 1, 100},
+		intRange{"min quality", webpMinQuality, 1, 100},
+		intRange{"effort", webpEffort, 1, 6},
+		intRange{"PNG quality", webpPNGQuality, 1, 100},
+		intRange{"PNG alpha quality", webpPNGAlphaQuality, 1, 100},
+		intRange{"near-lossless level", webpNearLossless, 1, 100},
+		intRange{"GIF quality", webpGIFQuality, 1, 100},
+		intRange{"GIF alpha quality", webpGIFAlphaQuality, 1, 100},
+	); err != nil {
+		return err
+	}
+	if _, err := parseChoice("preset", webpPreset, webpPresetChoices); err != nil {
+		return err
+	}
+	if err := checkTargetFlags(webpMaxBytes, webpTargetSSIM); err != nil {
+		return err
 	}
+	return checkSavingsFlags(webpMinSavingsBytes, webpMinSavingsRatio)
+}
 
-	if verbose {
-		fmt.Printf("[INFO] Successfully converted: %s → %s\n", inputPath, outputPath)
+// logWebPSettings prints the settings of a webp conversion in verbose mode
+func logWebPSettings(inputPath, outputPath string) {
+	fmt.Printf("[INFO] Input: %s\n", inputPath)
+	fmt.Printf("[INFO] Output: %s\n", outputPath)
+	fmt.Printf("[INFO] Quality: %d\n", webpQuality)
+	fmt.Printf("[INFO] Effort: %d, preset: %s, smart subsample: %v\n", webpEffort, webpPreset, webpSmartSubsample)
+	if webpTryNearLossless {
+		fmt.Printf("[INFO] Near-lossless: enabled (level %d)\n", webpNearLossless)
+	}
+	if webpPNGLossy || webpPNGTryAll || webpPNGAuto {
+		fmt.Printf("[INFO] PNG lossy quality: %d, alpha quality: %d\n", webpPNGQuality, webpPNGAlphaQuality)
+	}
+	if webpTargetSSIM > 0 {
+		fmt.Printf("[INFO] Target SSIM: %.4f (min quality: %d)\n", webpTargetSSIM, webpMinQuality)
+	}
+	if webpMaxBytes > 0 {
+		fmt.Printf("[INFO] Max bytes: %d (min quality: %d)\n", webpMaxBytes, webpMinQuality)
+	}
+	if webpGIFLossy || webpGIFTryBoth {
+		fmt.Printf("[INFO] GIF lossy quality: %d, alpha quality: %d\n", webpGIFQuality, webpGIFAlphaQuality)
+	}
+	if webpMinSavingsBytes > 0 || webpMinSavingsRatio > 0 {
+		fmt.Printf("[INFO] Min savings: %d bytes, %.1f%%\n", webpMinSavingsBytes, webpMinSavingsRatio*100)
 	}
+}
 
-	return nil
+// webpConfig builds the converter configuration from the webp command flags
+func webpConfig() nextgenimage.ConverterConfig {
+	config := nextgenimage.ConverterConfig{}
+	config.JPEGToWebP.Quality = webpQuality
+	config.JPEGToWebP.Subsampling = subsamplingPolicy(webpMatchSubsample)
+	config.PNGToWebP.TryNearLossless = webpTryNearLossless
+	config.GIFToWebP.Lossy = webpGIFLossy
+	config.GIFToWebP.Quality = webpGIFQuality
+	config.GIFToWebP.AlphaQuality = webpGIFAlphaQuality
+	config.GIFToWebP.TryBoth = webpGIFTryBoth
+	config.PNGToWebP.NearLosslessLevel = webpNearLossless
+	config.PNGToWebP.Lossy = webpPNGLossy
+	config.PNGToWebP.Quality = webpPNGQuality
+	config.PNGToWebP.AlphaQuality = webpPNGAlphaQuality
+	config.PNGToWebP.TryAll = webpPNGTryAll
+	config.PNGToWebP.Auto = webpPNGAuto
+	// The preset was checked by validateWebPFlags
+	preset, _ := parseChoice("preset", webpPreset, webpPresetChoices)
+	options := nextgenimage.WebPEncoderOptions{
+		Effort:         webpEffort,
+		Preset:         preset,
+		SmartSubsample: webpSmartSubsample,
+	}
+	config.JPEGToWebP.WebPEncoderOptions = options
+	config.PNGToWebP.WebPEncoderOptions = options
+	config.JPEGToWebP.AdaptiveQuality = webpAdaptiveQuality
+	config.GIFToWebP.WebPEncoderOptions = options
+	config.TargetSize.MaxBytes = webpMaxBytes
+	config.TargetSize.MinQuality = webpMinQuality
+	config.TargetSSIM.Score = webpTargetSSIM
+	config.TargetSSIM.MinQuality = webpMinQuality
+	config.MinSavingsBytes = webpMinSavingsBytes
+	config.MinSavingsRatio = webpMinSavingsRatio
+	return config
 }
 
 func formatBytes(bytes int64) string {
@@ -127,4 +208,4 @@ func formatBytes(bytes int64) string {
 		exp++
 	}
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
\ No newline at end of file
+}
@@ -0,0 +1,62 @@
+package nextgenimage
+
+import (
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// ColorManagement selects how images with an embedded ICC profile are handled
+type ColorManagement int
+
+const (
+	// ColorManagementNone leaves pixels as decoded. Profiles are then kept or stripped
+	// according to MetadataPolicy.ICC (default).
+	ColorManagementNone ColorManagement = iota
+	// ColorManagementSRGB converts pixels from the embedded profile to sRGB before encoding,
+	// so wide-gamut images such as AdobeRGB still look right once the profile is stripped.
+	ColorManagementSRGB
+	// ColorManagementKeepProfile leaves pixels untouched and always embeds the original profile.
+	ColorManagementKeepProfile
+)
+
+// metadataPolicy returns the metadata policy adjusted for the color management mode
+func (c *Converter) metadataPolicy() MetadataPolicy {
+	policy := c.config.Metadata
+	if c.config.ColorManagement == ColorManagementKeepProfile {
+		policy.ICC = true
+	}
+	return policy
+}
+
+// applyColorManagement transforms the image to sRGB (or gray) when the mode asks for it.
+// Images without an embedded profile are assumed to be sRGB already and are left alone.
+func applyColorManagement(image *vips.ImageRef, mode ColorManagement) error {
+	if mode != ColorManagementSRGB || !image.HasICCProfile() {
+		return nil
+	}
+
+	targetProfile := vips.SRGBIEC6196621ICCProfilePath
+	if image.Bands() <= 2 {
+		targetProfile = vips.SGrayV2MicroICCProfilePath
+	}
+
+	return image.TransformICCProfile(targetProfile)
+}
+
+// convertCMYKToSRGB converts CMYK and YCCK JPEGs to sRGB.
+// libjpeg decodes YCCK to CMYK and libvips undoes the inverted values Adobe applications
+// write (signalled by the APP14 segment), so both arrive here as plain CMYK pixels.
+// The embedded profile is used when there is one, otherwise the CMYK profile bundled with libvips.
+func convertCMYKToSRGB(image *vips.ImageRef, inputBuffer []byte) error {
+	// An unreadable header still decoded fine, so rely on the interpretation alone then
+	info, _ := parseJPEGColorInfo(inputBuffer)
+	if !info.isCMYK() && image.Interpretation() != vips.InterpretationCMYK {
+		return nil
+	}
+
+	if image.HasICCProfile() {
+		return image.TransformICCProfile(vips.SRGBIEC6196621ICCProfilePath)
+	}
+
+	// OptimizeICCProfile imports untagged CMYK through the bundled profile and exports sRGB
+	return image.OptimizeICCProfile()
+}
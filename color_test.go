@@ -0,0 +1,195 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// averageSRGB returns the mean pixel value of an image after converting it to sRGB
+// through its embedded profile, which is what a color-managed browser displays
+func averageSRGB(t *testing.T, imagePath string) float64 {
+	t.Helper()
+
+	image, err := vips.NewImageFromFile(imagePath)
+	if err != nil {
+		t.Fatalf("Failed to load image: %v", err)
+	}
+	defer image.Close()
+
+	if image.HasICCProfile() {
+		if err := image.TransformICCProfile(vips.SRGBIEC6196621ICCProfilePath); err != nil {
+			t.Fatalf("Failed to transform to sRGB: %v", err)
+		}
+	}
+
+	avg, err := image.Average()
+	if err != nil {
+		t.Fatalf("Failed to compute average: %v", err)
+	}
+	return avg
+}
+
+func TestMetadataPolicyWithColorManagement(t *testing.T) {
+	config := ConverterConfig{}
+	config.ColorManagement = ColorManagementKeepProfile
+	converter := NewConverter(config)
+
+	if !converter.metadataPolicy().ICC {
+		t.Error("ColorManagementKeepProfile should force the ICC profile to be kept")
+	}
+
+	if NewConverter(ConverterConfig{}).metadataPolicy().ICC {
+		t.Error("Default config should not keep the ICC profile")
+	}
+}
+
+func TestColorManagementSRGB(t *testing.T) {
+	inputPath := "testdata/jpeg/icc_adobergb.jpg"
+	tempDir := t.TempDir()
+
+	config := ConverterConfig{}
+	config.ColorManagement = ColorManagementSRGB
+	converter := NewConverter(config)
+
+	expected := averageSRGB(t, inputPath)
+
+	for _, f := range []struct {
+		ext     string
+		convert func(string, string) (*ConversionResult, error)
+	}{
+		{"webp", converter.ToWebP},
+		{"avif", converter.ToAVIF},
+	} {
+		t.Run(f.ext, func(t *testing.T) {
+			outputPath := filepath.Join(tempDir, "adobergb."+f.ext)
+			if _, err := f.convert(inputPath, outputPath); err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+
+			_, _, hasICC := checkMetadata(t, outputPath)
+			if hasICC {
+				t.Error("ICC profile should be stripped after conversion to sRGB")
+			}
+
+			// Without a profile the output is displayed as sRGB and should match the source
+			actual := averageSRGB(t, outputPath)
+			if math.Abs(actual-expected) > 2.0 {
+				t.Errorf("Average color drifted: expected %.2f, got %.2f", expected, actual)
+			}
+		})
+	}
+}
+
+func TestColorManagementKeepProfile(t *testing.T) {
+	inputPath := "testdata/jpeg/icc_adobergb.jpg"
+	tempDir := t.TempDir()
+
+	origImage, err := vips.NewImageFromFile(inputPath)
+	if err != nil {
+		t.Fatalf("Failed to load original image: %v", err)
+	}
+	defer origImage.Close()
+
+	origICCData := origImage.GetICCProfile()
+	if len(origICCData) == 0 {
+		t.Skip("Original image has no ICC profile")
+	}
+
+	config := ConverterConfig{}
+	config.ColorManagement = ColorManagementKeepProfile
+	converter := NewConverter(config)
+
+	outputPath := filepath.Join(tempDir, "adobergb.webp")
+	if _, err := converter.ToWebP(inputPath, outputPath); err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	convImage, err := vips.NewImageFromFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to load converted image: %v", err)
+	}
+	defer convImage.Close()
+
+	if !bytes.Equal(convImage.GetICCProfile(), origICCData) {
+		t.Error("Original ICC profile was not embedded")
+	}
+}
+
+// meanRGB returns the mean red, green and blue values of an image on a 0-255 scale
+func meanRGB(img image.Image) [3]float64 {
+	var sum [3]float64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			sum[0] += float64(c.R)
+			sum[1] += float64(c.G)
+			sum[2] += float64(c.B)
+		}
+	}
+	n := float64(bounds.Dx() * bounds.Dy())
+	return [3]float64{sum[0] / n, sum[1] / n, sum[2] / n}
+}
+
+func TestCMYKJPEGConversion(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	tempDir := t.TempDir()
+
+	for _, inputPath := range []string{"testdata/jpeg/colorspace_cmyk.jpg", "testdata/jpeg/critical_cmyk_lowquality.jpg"} {
+		t.Run(filepath.Base(inputPath), func(t *testing.T) {
+			// Go's decoder handles YCCK and Adobe's inverted CMYK independently of libvips,
+			// and a naive CMYK to RGB conversion is close enough to catch inverted or swapped channels
+			file, err := os.Open(inputPath)
+			if err != nil {
+				t.Fatalf("Failed to open input file: %v", err)
+			}
+			reference, err := jpeg.Decode(file)
+			file.Close()
+			if err != nil {
+				t.Fatalf("Failed to decode reference: %v", err)
+			}
+			expected := meanRGB(reference)
+
+			for _, f := range []struct {
+				ext     string
+				convert func(string, string) (*ConversionResult, error)
+			}{
+				{"webp", converter.ToWebP},
+				{"avif", converter.ToAVIF},
+			} {
+				outputPath := filepath.Join(tempDir, filepath.Base(inputPath)+"."+f.ext)
+				if _, err := f.convert(inputPath, outputPath); err != nil {
+					t.Fatalf("%s conversion failed: %v", f.ext, err)
+				}
+
+				convImage, err := vips.NewImageFromFile(outputPath)
+				if err != nil {
+					t.Fatalf("Failed to load converted image: %v", err)
+				}
+				if convImage.Bands() != 3 {
+					t.Errorf("%s: expected 3 bands, got %d", f.ext, convImage.Bands())
+				}
+				decoded, err := convImage.ToImage(vips.NewDefaultPNGExportParams())
+				convImage.Close()
+				if err != nil {
+					t.Fatalf("Failed to decode converted image: %v", err)
+				}
+
+				actual := meanRGB(decoded)
+				for i, name := range []string{"red", "green", "blue"} {
+					if math.Abs(actual[i]-expected[i]) > 25 {
+						t.Errorf("%s: mean %s is %.1f, expected about %.1f", f.ext, name, actual[i], expected[i])
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package nextgenimage
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/davidbyttow/govips/v2/vips"
 )
@@ -26,14 +29,52 @@ func (e *FormatError) Unwrap() error {
 // ConverterConfig holds configuration for image conversion
 type ConverterConfig struct {
 	JPEGToWebP struct {
-		Quality int // Default: 80
+		Quality            int               // Default: 80
+		Subsampling        SubsamplingPolicy // Default: SubsamplingMatchSource, sharp YUV for 4:4:4 sources
+		AdaptiveQuality    bool              // Default: false, cap the quality by the estimated source quality
+		WebPEncoderOptions                   // Default: effort 4, default preset
 	}
 	PNGToWebP struct {
-		TryNearLossless bool // Default: false
+		TryNearLossless    bool // Default: false
+		NearLosslessLevel  int  // Default: 100, near-lossless preprocessing level (lower is smaller)
+		Lossy              bool // Default: false (lossless), for photos saved as PNG
+		Quality            int  // Default: 80, lossy quality
+		AlphaQuality       int  // Default: 100, libwebp alpha_q of lossy output (1-100, lower compresses alpha more)
+		TryAll             bool // Default: false, encode lossless, near-lossless and lossy and keep the smallest
+		Auto               bool // Default: false, classify the content and pick lossless, near-lossless or lossy
+		WebPEncoderOptions      // Default: effort 4, default preset
+	}
+	GIFToWebP struct {
+		Lossy              bool // Default: false (lossless)
+		Quality            int  // Default: 80, lossy quality
+		AlphaQuality       int  // Default: 100, libwebp alpha_q of lossy output (1-100, lower compresses alpha more)
+		TryBoth            bool // Default: false, encode lossless and lossy and keep the smaller
+		WebPEncoderOptions      // Default: effort 4, default preset
 	}
 	JPEGToAVIF struct {
-		CQ int // Default: 25
+		CQ                 int               // Default: 25, libvips quality (1-100, higher is better)
+		Subsampling        SubsamplingPolicy // Default: SubsamplingMatchSource
+		AdaptiveQuality    bool              // Default: false, cap the CQ by the estimated source quality
+		AVIFEncoderOptions                   // Default: effort 5, 8-bit
+	}
+	PNGToAVIF struct {
+		Lossy              bool // Default: false (lossless), for photos saved as PNG
+		CQ                 int  // Default: 25, lossy CQ (1-100, higher is better)
+		AlphaLevels        int  // Default: 0 (exact), quantize lossy alpha to this many levels (2-256)
+		TryAll             bool // Default: false, encode lossless and lossy and keep the smaller
+		Auto               bool // Default: false, classify the content and pick lossless or lossy
+		AVIFEncoderOptions      // Default: effort 5, 8-bit
 	}
+	GIFToAVIF struct {
+		CQ                 int // Default: 25 (1-100, higher is better)
+		AVIFEncoderOptions     // Default: effort 5, 8-bit
+	}
+	Metadata        MetadataPolicy  // Default: strip all metadata
+	ColorManagement ColorManagement // Default: ColorManagementNone
+	TargetSize      TargetSize      // Default: disabled
+	TargetSSIM      TargetSSIM      // Default: disabled
+	MinSavingsBytes int64           // Default: 0, output only has to be smaller than the input
+	MinSavingsRatio float64         // Default: 0, minimum savings as a fraction of the input size (0-1)
 }
 
 // Converter handles image format conversions
@@ -47,12 +88,110 @@ func NewConverter(config ConverterConfig) *Converter {
 	if config.JPEGToWebP.Quality == 0 {
 		config.JPEGToWebP.Quality = 80
 	}
+	if config.PNGToWebP.NearLosslessLevel == 0 {
+		config.PNGToWebP.NearLosslessLevel = webpDefaultNearLossless
+	}
+	if config.PNGToWebP.Quality == 0 {
+		config.PNGToWebP.Quality = 80
+	}
+	if config.PNGToWebP.AlphaQuality == 0 {
+		config.PNGToWebP.AlphaQuality = 100
+	}
+	config.JPEGToWebP.WebPEncoderOptions = config.JPEGToWebP.withDefaults()
+	config.PNGToWebP.WebPEncoderOptions = config.PNGToWebP.withDefaults()
+	config.GIFToWebP.WebPEncoderOptions = config.GIFToWebP.withDefaults()
+	if config.GIFToWebP.Quality == 0 {
+		config.GIFToWebP.Quality = 80
+	}
+	if config.GIFToWebP.AlphaQuality == 0 {
+		config.GIFToWebP.AlphaQuality = 100
+	}
 	if config.JPEGToAVIF.CQ == 0 {
 		config.JPEGToAVIF.CQ = 25
 	}
+	if config.PNGToAVIF.CQ == 0 {
+		config.PNGToAVIF.CQ = 25
+	}
+	config.JPEGToAVIF.AVIFEncoderOptions = config.JPEGToAVIF.withDefaults()
+	config.PNGToAVIF.AVIFEncoderOptions = config.PNGToAVIF.withDefaults()
+	config.GIFToAVIF.AVIFEncoderOptions = config.GIFToAVIF.withDefaults()
+	if config.GIFToAVIF.CQ == 0 {
+		config.GIFToAVIF.CQ = 25
+	}
+	if config.TargetSize.MinQuality == 0 {
+		config.TargetSize.MinQuality = 1
+	}
+	if config.TargetSSIM.MinQuality == 0 {
+		config.TargetSSIM.MinQuality = 1
+	}
 	return &Converter{config: config}
 }
 
+// writeOutputFile writes an encoded image to outputPath, creating its directory if needed.
+// The data goes to a temporary file in the same directory first and is renamed into place,
+// so a failed or cancelled conversion never leaves a partial file at outputPath.
+func writeOutputFile(ctx context.Context, outputPath string, outputBuffer []byte) error {
+	// Create output directory if needed
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Write to a temporary file next to the output
+	tempFile, err := os.CreateTemp(outputDir, "."+filepath.Base(outputPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // No-op once renamed
+
+	if _, err := tempFile.Write(outputBuffer); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	// Last chance to honor cancellation before the output becomes visible
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, outputPath); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return nil
+}
+
+// runWithContext runs convert in the background and returns as soon as either it finishes or ctx is done.
+// libvips can't interrupt a call that is already running, so convert must check ctx between its
+// steps (decode, encode, search steps and animation frames) to stop. On cancellation the running
+// call finishes on its own goroutine, convert returns at its next check and the result is discarded.
+func runWithContext(ctx context.Context, convert func() ([]byte, error)) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		buffer []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		buffer, err := convert()
+		done <- result{buffer: buffer, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.buffer, r.err
+	}
+}
+
 func init() {
 	// Initialize vips once
 	vips.Startup(nil)
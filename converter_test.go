@@ -1,10 +1,14 @@
 package nextgenimage
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/davidbyttow/govips/v2/vips"
 )
@@ -77,7 +81,7 @@ func TestToWebPInvalidInput(t *testing.T) {
 	tempDir := t.TempDir()
 
 	// Test non-existent file
-	err := converter.ToWebP("non_existent_file.jpg", filepath.Join(tempDir, "output.webp"))
+	_, err := converter.ToWebP("non_existent_file.jpg", filepath.Join(tempDir, "output.webp"))
 	if err == nil {
 		t.Error("Expected error for non-existent file")
 	}
@@ -89,7 +93,7 @@ func TestToWebPInvalidInput(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	err = converter.ToWebP(txtPath, filepath.Join(tempDir, "output.webp"))
+	_, err = converter.ToWebP(txtPath, filepath.Join(tempDir, "output.webp"))
 	if err == nil {
 		t.Error("Expected error for unsupported format")
 	}
@@ -100,7 +104,7 @@ func TestToAVIFInvalidInput(t *testing.T) {
 	tempDir := t.TempDir()
 
 	// Test non-existent file
-	err := converter.ToAVIF("non_existent_file.jpg", filepath.Join(tempDir, "output.avif"))
+	_, err := converter.ToAVIF("non_existent_file.jpg", filepath.Join(tempDir, "output.avif"))
 	if err == nil {
 		t.Error("Expected error for non-existent file")
 	}
@@ -112,7 +116,7 @@ func TestToAVIFInvalidInput(t *testing.T) {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
 
-	err = converter.ToAVIF(txtPath, filepath.Join(tempDir, "output.avif"))
+	_, err = converter.ToAVIF(txtPath, filepath.Join(tempDir, "output.avif"))
 	if err == nil {
 		t.Error("Expected error for unsupported format")
 	}
@@ -150,7 +154,7 @@ func TestLargeOutputHandling(t *testing.T) {
 	converter2 := NewConverter(config)
 
 	outputPath := filepath.Join(tempDir, "small.webp")
-	err = converter2.ToWebP(smallPath, outputPath)
+	_, err = converter2.ToWebP(smallPath, outputPath)
 
 	// This might still succeed if WebP is more efficient
 	if err != nil {
@@ -180,23 +184,23 @@ func TestConcurrentConversions(t *testing.T) {
 
 	// WebP conversions
 	go func() {
-		err := converter.ToWebP(inputPath, filepath.Join(tempDir, "concurrent1.webp"))
+		_, err := converter.ToWebP(inputPath, filepath.Join(tempDir, "concurrent1.webp"))
 		done <- err
 	}()
 
 	go func() {
-		err := converter.ToWebP(inputPath, filepath.Join(tempDir, "concurrent2.webp"))
+		_, err := converter.ToWebP(inputPath, filepath.Join(tempDir, "concurrent2.webp"))
 		done <- err
 	}()
 
 	// AVIF conversions
 	go func() {
-		err := converter.ToAVIF(inputPath, filepath.Join(tempDir, "concurrent1.avif"))
+		_, err := converter.ToAVIF(inputPath, filepath.Join(tempDir, "concurrent1.avif"))
 		done <- err
 	}()
 
 	go func() {
-		err := converter.ToAVIF(inputPath, filepath.Join(tempDir, "concurrent2.avif"))
+		_, err := converter.ToAVIF(inputPath, filepath.Join(tempDir, "concurrent2.avif"))
 		done <- err
 	}()
 
@@ -207,3 +211,377 @@ func TestConcurrentConversions(t *testing.T) {
 		}
 	}
 }
+
+func TestToWebPBytes(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+
+	inputPath := "testdata/jpeg/quality_95.jpg"
+	inputBuffer, err := os.ReadFile(inputPath)
+	if err != nil {
+		t.Fatalf("Failed to read input file: %v", err)
+	}
+
+	outputBuffer, err := converter.ToWebPBytes(inputBuffer)
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	if DetectImageTypeFromBytes(outputBuffer) != ImageTypeWebP {
+		t.Errorf("Expected WebP output, got %s", DetectImageTypeFromBytes(outputBuffer))
+	}
+	if len(outputBuffer) >= len(inputBuffer) {
+		t.Errorf("Output size (%d) is not smaller than input (%d)", len(outputBuffer), len(inputBuffer))
+	}
+
+	// Unsupported data should be rejected
+	if _, err := converter.ToWebPBytes([]byte("not an image")); err == nil {
+		t.Error("Expected error for unsupported data")
+	}
+}
+
+func TestToAVIFBytes(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+
+	inputPath := "testdata/jpeg/quality_95.jpg"
+	inputBuffer, err := os.ReadFile(inputPath)
+	if err != nil {
+		t.Fatalf("Failed to read input file: %v", err)
+	}
+
+	outputBuffer, err := converter.ToAVIFBytes(inputBuffer)
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	if DetectImageTypeFromBytes(outputBuffer) != ImageTypeAVIF {
+		t.Errorf("Expected AVIF output, got %s", DetectImageTypeFromBytes(outputBuffer))
+	}
+}
+
+func TestToWebPStream(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+
+	input, err := os.Open("testdata/png/colortype_rgb.png")
+	if err != nil {
+		t.Fatalf("Failed to open input file: %v", err)
+	}
+	defer input.Close()
+
+	var output bytes.Buffer
+	err = converter.ToWebPStream(input, &output)
+
+	var formatErr *FormatError
+	if errors.As(err, &formatErr) {
+		t.Logf("Format error: %v", err)
+		if output.Len() != 0 {
+			t.Error("Expected no output to be written on FormatError")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	if DetectImageTypeFromBytes(output.Bytes()) != ImageTypeWebP {
+		t.Errorf("Expected WebP output, got %s", DetectImageTypeFromBytes(output.Bytes()))
+	}
+}
+
+func TestToAVIFStream(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+
+	input, err := os.Open("testdata/jpeg/quality_95.jpg")
+	if err != nil {
+		t.Fatalf("Failed to open input file: %v", err)
+	}
+	defer input.Close()
+
+	var output bytes.Buffer
+	if err := converter.ToAVIFStream(input, &output); err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	if DetectImageTypeFromBytes(output.Bytes()) != ImageTypeAVIF {
+		t.Errorf("Expected AVIF output, got %s", DetectImageTypeFromBytes(output.Bytes()))
+	}
+}
+
+func TestInMemoryConversionResult(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	inputPath := "testdata/jpeg/quality_95.jpg"
+	inputBuffer, err := os.ReadFile(inputPath)
+	if err != nil {
+		t.Fatalf("Failed to read input file: %v", err)
+	}
+
+	formats := []struct {
+		format ImageType
+		bytes  func(context.Context, []byte) ([]byte, *ConversionResult, error)
+		stream func(context.Context, io.Reader, io.Writer) (*ConversionResult, error)
+	}{
+		{ImageTypeWebP, converter.ToWebPBytesContext, converter.ToWebPStreamContext},
+		{ImageTypeAVIF, converter.ToAVIFBytesContext, converter.ToAVIFStreamContext},
+	}
+
+	for _, f := range formats {
+		t.Run(f.format.String(), func(t *testing.T) {
+			outputBuffer, result, err := f.bytes(context.Background(), inputBuffer)
+			if err != nil {
+				t.Fatalf("Bytes conversion failed: %v", err)
+			}
+			if result == nil || result.InputType != ImageTypeJPEG || result.OutputFormat != f.format {
+				t.Fatalf("Unexpected result: %+v", result)
+			}
+			if result.InputSize != int64(len(inputBuffer)) || result.OutputSize != int64(len(outputBuffer)) {
+				t.Errorf("Expected sizes %d -> %d, got %d -> %d", len(inputBuffer), len(outputBuffer), result.InputSize, result.OutputSize)
+			}
+
+			var output bytes.Buffer
+			streamResult, err := f.stream(context.Background(), bytes.NewReader(inputBuffer), &output)
+			if err != nil {
+				t.Fatalf("Stream conversion failed: %v", err)
+			}
+			if streamResult.OutputSize != int64(output.Len()) || streamResult.Quality != result.Quality || streamResult.Mode != result.Mode {
+				t.Errorf("Stream result %+v does not match bytes result %+v", streamResult, result)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, _, err := f.bytes(ctx, inputBuffer); !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected context.Canceled, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConversionContextCanceled(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	tempDir := t.TempDir()
+	inputPath := "testdata/jpeg/quality_95.jpg"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	webpPath := filepath.Join(tempDir, "canceled.webp")
+	_, err := converter.ToWebPContext(ctx, inputPath, webpPath)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from ToWebPContext, got %v", err)
+	}
+
+	avifPath := filepath.Join(tempDir, "canceled.avif")
+	_, err = converter.ToAVIFContext(ctx, inputPath, avifPath)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from ToAVIFContext, got %v", err)
+	}
+
+	// No output or temporary files should be left behind
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	for _, entry := range entries {
+		t.Errorf("Unexpected file left behind: %s", entry.Name())
+	}
+}
+
+func TestConversionContextDeadline(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	tempDir := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	outputPath := filepath.Join(tempDir, "deadline.avif")
+	_, err := converter.ToAVIFContext(ctx, "testdata/png/colortype_rgb.png", outputPath)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("Output file should not exist after deadline")
+	}
+}
+
+func TestRunWithContextCanceledMidConvert(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started, stopped := make(chan struct{}), make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		_, err := runWithContext(ctx, func() ([]byte, error) {
+			defer close(stopped)
+			close(started)
+			// Stands in for an encode followed by the pipeline's next context check
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		errc <- err
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("runWithContext did not return after cancellation")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("Conversion goroutine kept running after cancellation")
+	}
+}
+
+// countdownContext reports cancellation once Err has been called n times, so a
+// pipeline can be cancelled between two of its steps deterministically
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestConversionContextCanceledMidEncode(t *testing.T) {
+	config := ConverterConfig{}
+	config.TargetSize.MaxBytes = 1 << 20
+	converter := NewConverter(config)
+
+	inputBuffer, err := os.ReadFile("testdata/jpeg/quality_95.jpg")
+	if err != nil {
+		t.Fatalf("Failed to read input: %v", err)
+	}
+
+	// The first checks pass, so cancellation lands after decoding, inside the quality search
+	for _, checks := range []int{1, 2, 3} {
+		if _, _, err := converter.toWebP(&countdownContext{context.Background(), checks}, inputBuffer); !errors.Is(err, context.Canceled) {
+			t.Errorf("WebP after %d checks: expected context.Canceled, got %v", checks, err)
+		}
+		if _, _, err := converter.toAVIF(&countdownContext{context.Background(), checks}, inputBuffer); !errors.Is(err, context.Canceled) {
+			t.Errorf("AVIF after %d checks: expected context.Canceled, got %v", checks, err)
+		}
+	}
+}
+
+func TestWriteOutputFileCanceled(t *testing.T) {
+	tempDir := t.TempDir()
+	outputPath := filepath.Join(tempDir, "nested", "output.webp")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := writeOutputFile(ctx, outputPath, []byte("data"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(outputPath))
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected empty output dir, found %d entries", len(entries))
+	}
+
+	// A live context writes the file
+	if err := writeOutputFile(context.Background(), outputPath, []byte("data")); err != nil {
+		t.Fatalf("writeOutputFile failed: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil || string(data) != "data" {
+		t.Errorf("Unexpected output file contents: %q, %v", data, err)
+	}
+}
+
+func TestConversionResult(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name         string
+		inputPath    string
+		convert      func(string, string) (*ConversionResult, error)
+		outputFormat ImageType
+		inputType    ImageType
+		mode         EncoderMode
+		quality      int
+	}{
+		{"JPEG to WebP", "testdata/jpeg/quality_95.jpg", converter.ToWebP, ImageTypeWebP, ImageTypeJPEG, EncoderModeLossy, 80},
+		{"JPEG to AVIF", "testdata/jpeg/quality_95.jpg", converter.ToAVIF, ImageTypeAVIF, ImageTypeJPEG, EncoderModeLossy, 25},
+		{"PNG to WebP", "testdata/png/colortype_rgb.png", converter.ToWebP, ImageTypeWebP, ImageTypePNG, EncoderModeLossless, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(tempDir, filepath.Base(tt.inputPath)+"."+tt.outputFormat.String())
+			result, err := tt.convert(tt.inputPath, outputPath)
+
+			var formatErr *FormatError
+			if errors.As(err, &formatErr) {
+				t.Logf("Format error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+
+			inputInfo, err := os.Stat(tt.inputPath)
+			if err != nil {
+				t.Fatalf("Failed to stat input file: %v", err)
+			}
+			outputInfo, err := os.Stat(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to stat output file: %v", err)
+			}
+
+			if result.InputType != tt.inputType {
+				t.Errorf("Expected input type %s, got %s", tt.inputType, result.InputType)
+			}
+			if result.OutputFormat != tt.outputFormat {
+				t.Errorf("Expected output format %s, got %s", tt.outputFormat, result.OutputFormat)
+			}
+			if result.InputSize != inputInfo.Size() {
+				t.Errorf("Expected input size %d, got %d", inputInfo.Size(), result.InputSize)
+			}
+			if result.OutputSize != outputInfo.Size() {
+				t.Errorf("Expected output size %d, got %d", outputInfo.Size(), result.OutputSize)
+			}
+			if result.Width <= 0 || result.Height <= 0 {
+				t.Errorf("Expected positive dimensions, got %dx%d", result.Width, result.Height)
+			}
+			if result.Frames != 1 {
+				t.Errorf("Expected 1 frame, got %d", result.Frames)
+			}
+			if result.Mode != tt.mode {
+				t.Errorf("Expected mode %s, got %s", tt.mode, result.Mode)
+			}
+			if result.Quality != tt.quality {
+				t.Errorf("Expected quality %d, got %d", tt.quality, result.Quality)
+			}
+			if result.Elapsed <= 0 {
+				t.Error("Expected positive elapsed time")
+			}
+		})
+	}
+}
+
+func TestConversionResultSizeReduction(t *testing.T) {
+	result := &ConversionResult{InputSize: 1000, OutputSize: 250}
+	if got := result.SizeReduction(); got != 75 {
+		t.Errorf("Expected 75%% reduction, got %.2f", got)
+	}
+
+	empty := &ConversionResult{}
+	if got := empty.SizeReduction(); got != 0 {
+		t.Errorf("Expected 0%% reduction for empty input, got %.2f", got)
+	}
+}
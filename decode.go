@@ -0,0 +1,128 @@
+package nextgenimage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// decodedInput is an input image decoded and prepared once, ready for any number of encodes.
+// Encoders only export it or work on copies, so it can be shared between output formats.
+type decodedInput struct {
+	decoder       *Converter     // Converter whose color and metadata settings prepared the input
+	buf           []byte         // Original input, GIF frames are loaded from it
+	imgType       ImageType      // Detected input format
+	image         *vips.ImageRef // Auto-rotated, color managed first frame
+	animation     *vips.ImageRef // All GIF frames, loaded on first use
+	subsampling   string         // Chroma subsampling of a JPEG input, empty otherwise
+	quality       int            // Estimated quality of a JPEG input, 0 when unknown
+	stripMetadata bool           // The metadata policy keeps nothing
+}
+
+// Close releases the decoded images
+func (d *decodedInput) Close() {
+	d.image.Close()
+	if d.animation != nil {
+		d.animation.Close()
+	}
+}
+
+// decodeInput detects, loads and prepares an input image, checking ctx once it is decoded
+func (c *Converter) decodeInput(ctx context.Context, inputBuffer []byte) (*decodedInput, error) {
+	// Detect input format using magic bytes
+	imgType := DetectImageTypeFromBytes(inputBuffer)
+	if !imgType.IsSupported() {
+		return nil, newKindError(ErrUnsupportedFormat, fmt.Errorf("unsupported image format: %s", imgType))
+	}
+	// libvips loads the default image of an APNG, which converts like any PNG
+	if imgType == ImageTypeAPNG {
+		imgType = ImageTypePNG
+	}
+
+	// Load image
+	image, err := vips.NewImageFromBuffer(inputBuffer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", newKindError(ErrDecodeFailed, err))
+	}
+	input := &decodedInput{decoder: c, buf: inputBuffer, imgType: imgType, image: image}
+
+	if err := c.prepareInput(ctx, input); err != nil {
+		input.Close()
+		return nil, err
+	}
+
+	return input, nil
+}
+
+// prepareInput rotates, converts and trims the metadata of a freshly loaded input
+func (c *Converter) prepareInput(ctx context.Context, input *decodedInput) error {
+	image := input.image
+
+	// Auto-rotate based on EXIF orientation
+	if err := image.AutoRotate(); err != nil {
+		return fmt.Errorf("failed to auto-rotate: %w", newKindError(ErrDecodeFailed, err))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// CMYK and YCCK JPEGs are always converted, browsers don't display them reliably
+	if input.imgType == ImageTypeJPEG {
+		if err := convertCMYKToSRGB(image, input.buf); err != nil {
+			return fmt.Errorf("failed to convert CMYK to sRGB: %w", NewFormatError(err))
+		}
+		// libvips decoded the frame, so a header it accepted parses here
+		if info, err := parseJPEGColorInfo(input.buf); err == nil {
+			input.subsampling = info.subsampling()
+		}
+		if quality, err := estimateJPEGQuality(input.buf); err == nil {
+			input.quality = quality
+		}
+	}
+
+	// Convert to sRGB if configured
+	if err := applyColorManagement(image, c.config.ColorManagement); err != nil {
+		return fmt.Errorf("failed to apply color management: %w", NewFormatError(err))
+	}
+
+	// Trim metadata to what the policy keeps
+	stripMetadata, err := applyMetadataPolicy(image, c.metadataPolicy())
+	if err != nil {
+		return fmt.Errorf("failed to apply metadata policy: %w", NewFormatError(err))
+	}
+	input.stripMetadata = stripMetadata
+
+	return nil
+}
+
+// animatedImage loads every frame of a GIF input the first time an encoder needs them
+// and shares them with later encodes
+func (d *decodedInput) animatedImage() (*vips.ImageRef, error) {
+	if d.animation != nil {
+		return d.animation, nil
+	}
+	c := d.decoder
+
+	// Load as animated image
+	animParams := vips.NewImportParams()
+	animParams.NumPages.Set(-1) // Load all pages/frames
+
+	animImage, err := vips.LoadImageFromBuffer(d.buf, animParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load animated gif: %w", newKindError(ErrDecodeFailed, err))
+	}
+
+	if err := applyColorManagement(animImage, c.config.ColorManagement); err != nil {
+		animImage.Close()
+		return nil, fmt.Errorf("failed to apply color management: %w", NewFormatError(err))
+	}
+	if _, err := applyMetadataPolicy(animImage, c.metadataPolicy()); err != nil {
+		animImage.Close()
+		return nil, fmt.Errorf("failed to apply metadata policy: %w", NewFormatError(err))
+	}
+
+	d.animation = animImage
+	return animImage, nil
+}
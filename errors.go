@@ -0,0 +1,66 @@
+package nextgenimage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors for errors.Is. Conversions return them wrapped in a FormatError, so existing
+// errors.As checks for FormatError keep working.
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrNotSmaller        = errors.New("output is not smaller than input")
+	ErrDecodeFailed      = errors.New("failed to decode image")
+	ErrEncodeFailed      = errors.New("failed to encode image")
+	ErrImageTooLarge     = errors.New("image too large")
+)
+
+// Largest dimensions the encoders accept
+const (
+	webpMaxDimension = 16383 // VP8 and VP8L store 14-bit sizes
+	avifMaxDimension = 65536 // AV1 stores 16-bit sizes minus one
+)
+
+// kindError tags an error with a sentinel without changing its message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// newKindError wraps err in a FormatError that matches kind with errors.Is
+func newKindError(kind, err error) *FormatError {
+	return NewFormatError(&kindError{kind: kind, err: err})
+}
+
+// ImageTooLargeError reports an image exceeding the dimensions the output format can store
+type ImageTooLargeError struct {
+	Width     int // Image width in pixels
+	Height    int // Image height in pixels
+	MaxWidth  int // Largest width the output format stores
+	MaxHeight int // Largest height the output format stores
+}
+
+func (e *ImageTooLargeError) Error() string {
+	return fmt.Sprintf("image size %dx%d exceeds the maximum of %dx%d", e.Width, e.Height, e.MaxWidth, e.MaxHeight)
+}
+
+// Is matches ErrImageTooLarge
+func (e *ImageTooLargeError) Is(target error) bool {
+	return target == ErrImageTooLarge
+}
+
+// checkDimensions rejects images larger than maxDimension on either side
+func checkDimensions(width, height, maxDimension int) error {
+	if width > maxDimension || height > maxDimension {
+		return NewFormatError(&ImageTooLargeError{Width: width, Height: height, MaxWidth: maxDimension, MaxHeight: maxDimension})
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package nextgenimage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	// Unsupported data is rejected before decoding
+	_, err := NewConverter(ConverterConfig{}).ToWebPBytes([]byte("This is not an image"))
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+	_, err = NewConverter(ConverterConfig{}).ToAVIFBytes([]byte("This is not an image"))
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) {
+		t.Errorf("Expected FormatError compatibility, got %v", err)
+	}
+
+	// Tagging keeps the message and the underlying error
+	cause := errors.New("vips error")
+	err = newKindError(ErrDecodeFailed, cause)
+	if !errors.Is(err, ErrDecodeFailed) || !errors.Is(err, cause) || errors.Is(err, ErrEncodeFailed) {
+		t.Errorf("Expected only ErrDecodeFailed and the cause to match, got %v", err)
+	}
+	if err.Error() != "format error: vips error" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+
+	// Savings errors match ErrNotSmaller and carry the sizes
+	err = NewConverter(ConverterConfig{}).checkSavings(100, 120)
+	var savingsErr *InsufficientSavingsError
+	if !errors.Is(err, ErrNotSmaller) || !errors.As(err, &savingsErr) || savingsErr.OutputSize != 120 {
+		t.Errorf("Expected ErrNotSmaller with sizes, got %v", err)
+	}
+}
+
+func TestCheckDimensions(t *testing.T) {
+	if err := checkDimensions(webpMaxDimension, webpMaxDimension, webpMaxDimension); err != nil {
+		t.Errorf("Expected the maximum size to pass, got %v", err)
+	}
+
+	err := checkDimensions(webpMaxDimension+1, 100, webpMaxDimension)
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("Expected ErrImageTooLarge, got %v", err)
+	}
+	var tooLarge *ImageTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Width != webpMaxDimension+1 || tooLarge.MaxWidth != webpMaxDimension {
+		t.Errorf("Expected the sizes on ImageTooLargeError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "16384x100") {
+		t.Errorf("Expected the image size in the message, got %q", err.Error())
+	}
+}
@@ -0,0 +1,188 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// GIF block introducers and extension labels
+const (
+	gifExtensionIntroducer  = 0x21
+	gifImageSeparator       = 0x2C
+	gifTrailer              = 0x3B
+	gifGraphicControlLabel  = 0xF9
+	gifApplicationLabel     = 0xFF
+	gifMaxLoopCount         = 0xFFFF
+	gifHeaderSize           = 13 // Signature, logical screen descriptor
+	gifImageDescriptorSize  = 10 // Separator, position, size, flags
+	gifApplicationIDSize    = 11
+	gifNetscapeLoopSubBlock = 0x01
+	gifDefaultDelay         = 100 // Milliseconds browsers use for frames without a delay
+)
+
+// gifAnimation holds the timing information of a GIF
+type gifAnimation struct {
+	width       int   // Logical screen width
+	height      int   // Logical screen height
+	frames      int   // Number of image descriptors
+	delays      []int // Per-frame delay in milliseconds
+	loopCount   int   // NETSCAPE2.0 loop count, 0 means forever
+	hasLoop     bool  // A NETSCAPE2.0 or ANIMEXTS1.0 extension is present
+	transparent bool  // A Graphic Control Extension sets a transparent color
+	interlaced  bool  // An image descriptor uses interlaced row order
+}
+
+// webpLoopCount converts the loop count to WebP semantics the same way gif2webp does.
+// GIF counts repetitions after the first play and plays once without a loop extension,
+// while WebP counts plays, with 0 meaning forever in both.
+func (a *gifAnimation) webpLoopCount() int {
+	if !a.hasLoop {
+		return 1
+	}
+	if a.loopCount == 0 || a.loopCount >= gifMaxLoopCount {
+		return a.loopCount
+	}
+	return a.loopCount + 1
+}
+
+// frameDurations returns the per-frame display time in milliseconds for animated WebP and AVIF.
+// Browsers show GIF frames without a delay for 100ms, and a zero duration AVIF sample would be
+// skipped, so both formats get that delay instead of 0.
+func (a *gifAnimation) frameDurations() []int {
+	durations := make([]int, len(a.delays))
+	for i, delay := range a.delays {
+		durations[i] = delay
+		if delay <= 0 {
+			durations[i] = gifDefaultDelay
+		}
+	}
+	return durations
+}
+
+// parseGIFAnimation reads frame delays from the Graphic Control Extensions and the loop count
+// from the NETSCAPE2.0 application extension without decoding any image data
+func parseGIFAnimation(buf []byte) (*gifAnimation, error) {
+	if len(buf) < gifHeaderSize || (!bytes.Equal(buf[:6], gifMagic1) && !bytes.Equal(buf[:6], gifMagic2)) {
+		return nil, fmt.Errorf("missing GIF signature")
+	}
+
+	anim := &gifAnimation{
+		width:  int(binary.LittleEndian.Uint16(buf[6:8])),
+		height: int(binary.LittleEndian.Uint16(buf[8:10])),
+	}
+
+	pos := gifHeaderSize
+	pos += gifColorTableSize(buf[10])
+
+	pendingDelay := 0
+	for {
+		if pos >= len(buf) {
+			// Plenty of encoders omit the trailer
+			if anim.frames > 0 {
+				return anim, nil
+			}
+			return nil, fmt.Errorf("unexpected end of GIF data")
+		}
+
+		switch buf[pos] {
+		case gifExtensionIntroducer:
+			if pos+2 > len(buf) {
+				return nil, fmt.Errorf("truncated GIF extension")
+			}
+			label := buf[pos+1]
+			blocks, next, err := readGIFSubBlocks(buf, pos+2)
+			if err != nil {
+				return nil, err
+			}
+			pos = next
+			if delay, ok := anim.readExtension(label, blocks); ok {
+				pendingDelay = delay
+			}
+
+		case gifImageSeparator:
+			if pos+gifImageDescriptorSize > len(buf) {
+				return nil, fmt.Errorf("truncated GIF image descriptor")
+			}
+			pos += gifImageDescriptorSize
+			if buf[pos-1]&0x40 != 0 {
+				anim.interlaced = true
+			}
+			pos += gifColorTableSize(buf[pos-1])
+			pos++ // LZW minimum code size
+
+			_, next, err := readGIFSubBlocks(buf, pos)
+			if err != nil {
+				return nil, err
+			}
+			pos = next
+
+			anim.frames++
+			anim.delays = append(anim.delays, pendingDelay)
+			pendingDelay = 0
+
+		case gifTrailer:
+			if anim.frames == 0 {
+				return nil, fmt.Errorf("GIF has no frames")
+			}
+			return anim, nil
+
+		default:
+			return nil, fmt.Errorf("invalid GIF block 0x%02X at offset %d", buf[pos], pos)
+		}
+	}
+}
+
+// readExtension records the transparency and loop count of an extension. For a Graphic
+// Control Extension it returns the delay in milliseconds of the next frame.
+func (a *gifAnimation) readExtension(label byte, blocks [][]byte) (int, bool) {
+	switch label {
+	case gifGraphicControlLabel:
+		// flags(1) delay(2) transparent index(1), delay in hundredths of a second
+		if len(blocks) > 0 && len(blocks[0]) >= 3 {
+			if blocks[0][0]&0x01 != 0 {
+				a.transparent = true
+			}
+			return int(binary.LittleEndian.Uint16(blocks[0][1:3])) * 10, true
+		}
+	case gifApplicationLabel:
+		if len(blocks) < 2 || len(blocks[0]) < gifApplicationIDSize {
+			return 0, false
+		}
+		id := string(blocks[0][:gifApplicationIDSize])
+		if (id == "NETSCAPE2.0" || id == "ANIMEXTS1.0") && len(blocks[1]) >= 3 && blocks[1][0] == gifNetscapeLoopSubBlock {
+			a.loopCount = int(binary.LittleEndian.Uint16(blocks[1][1:3]))
+			a.hasLoop = true
+		}
+	}
+	return 0, false
+}
+
+// gifColorTableSize returns the size in bytes of the color table described by packed flags
+func gifColorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 * (2 << (flags & 0x07))
+}
+
+// readGIFSubBlocks reads a chain of data sub-blocks starting at pos and returns
+// them along with the offset just past the block terminator
+func readGIFSubBlocks(buf []byte, pos int) ([][]byte, int, error) {
+	var blocks [][]byte
+	for {
+		if pos >= len(buf) {
+			return nil, 0, fmt.Errorf("truncated GIF data sub-block")
+		}
+		size := int(buf[pos])
+		pos++
+		if size == 0 {
+			return blocks, pos, nil
+		}
+		if pos+size > len(buf) {
+			return nil, 0, fmt.Errorf("truncated GIF data sub-block")
+		}
+		blocks = append(blocks, buf[pos:pos+size])
+		pos += size
+	}
+}
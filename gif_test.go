@@ -0,0 +1,106 @@
+package nextgenimage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseGIFAnimation(t *testing.T) {
+	tests := []struct {
+		path      string
+		frames    int
+		delay     int
+		loopCount int
+		webpLoop  int
+	}{
+		{"testdata/gif/fps_fast.gif", 10, 40, 0, 0},
+		{"testdata/gif/fps_normal.gif", 10, 100, 0, 0},
+		{"testdata/gif/fps_slow.gif", 10, 200, 0, 0},
+		{"testdata/gif/loop_loop_infinite.gif", 10, 100, 0, 0},
+		{"testdata/gif/loop_loop_once.gif", 10, 100, 1, 2},
+		{"testdata/gif/loop_loop_3times.gif", 10, 100, 3, 4},
+		{"testdata/gif/frames_single.gif", 1, 100, 0, 0},
+		{"testdata/gif/frames_long.gif", 20, 100, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			data, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+
+			anim, err := parseGIFAnimation(data)
+			if err != nil {
+				t.Fatalf("parseGIFAnimation failed: %v", err)
+			}
+
+			if anim.width != 200 || anim.height != 200 {
+				t.Errorf("Expected 200x200, got %dx%d", anim.width, anim.height)
+			}
+			if anim.frames != tt.frames || len(anim.delays) != tt.frames {
+				t.Errorf("Expected %d frames, got %d (%d delays)", tt.frames, anim.frames, len(anim.delays))
+			}
+			for i, delay := range anim.delays {
+				if delay != tt.delay {
+					t.Errorf("Frame %d: expected delay %dms, got %dms", i, tt.delay, delay)
+				}
+			}
+			if !anim.hasLoop {
+				t.Error("Expected a loop extension")
+			}
+			if anim.loopCount != tt.loopCount {
+				t.Errorf("Expected loop count %d, got %d", tt.loopCount, anim.loopCount)
+			}
+			if anim.webpLoopCount() != tt.webpLoop {
+				t.Errorf("Expected WebP loop count %d, got %d", tt.webpLoop, anim.webpLoopCount())
+			}
+		})
+	}
+}
+
+func TestGIFWebPLoopCountWithoutExtension(t *testing.T) {
+	anim := &gifAnimation{frames: 3}
+	if anim.webpLoopCount() != 1 {
+		t.Errorf("GIF without loop extension should play once, got WebP loop count %d", anim.webpLoopCount())
+	}
+}
+
+func TestGIFFrameDurations(t *testing.T) {
+	anim := &gifAnimation{frames: 3, delays: []int{0, 50, -10}}
+	expected := []int{gifDefaultDelay, 50, gifDefaultDelay}
+	durations := anim.frameDurations()
+	for i, duration := range durations {
+		if duration != expected[i] {
+			t.Errorf("Frame %d: expected %dms, got %dms", i, expected[i], duration)
+		}
+	}
+	if anim.delays[0] != 0 {
+		t.Error("frameDurations modified the parsed delays")
+	}
+}
+
+func TestParseGIFAnimationInvalid(t *testing.T) {
+	data, err := os.ReadFile("testdata/gif/frames_short.gif")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	invalid := map[string][]byte{
+		"empty":     nil,
+		"not a gif": []byte("not a gif at all"),
+		"header":    data[:13],
+		"truncated": data[:len(data)/2],
+	}
+
+	for name, buf := range invalid {
+		if _, err := parseGIFAnimation(buf); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// A missing trailer is tolerated
+	if _, err := parseGIFAnimation(data[:len(data)-1]); err != nil {
+		t.Errorf("Expected GIF without trailer to parse, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package nextgenimage
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGIFToAVIF(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	images := loadTestImages(t)
+
+	// Create temp directory for outputs
+	tempDir := t.TempDir()
+
+	for _, img := range images {
+		if img.Format != "gif" {
+			continue
+		}
+
+		t.Run(img.Path, func(t *testing.T) {
+			inputPath := filepath.Join("testdata", img.Path)
+			outputPath := filepath.Join(tempDir, filepath.Base(img.Path)+".avif")
+
+			result, err := converter.ToAVIF(inputPath, outputPath)
+
+			// Check if it's a format error (expected for some test cases)
+			var formatErr *FormatError
+			if errors.As(err, &formatErr) {
+				t.Logf("Format error (expected for some cases): %v", err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+
+			output, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Output file not created: %v", err)
+			}
+			if DetectImageTypeFromBytes(output) != ImageTypeAVIF {
+				t.Errorf("Expected AVIF output, got %s", DetectImageTypeFromBytes(output))
+			}
+			if result.Mode != EncoderModeLossy || result.Quality != 25 {
+				t.Errorf("Expected lossy CQ 25, got %s %d", result.Mode, result.Quality)
+			}
+
+			t.Logf("Size reduction: %.2f%% (%d -> %d bytes, %d frames)", result.SizeReduction(), result.InputSize, result.OutputSize, result.Frames)
+		})
+	}
+}
+
+func TestGIFToAVIFSequence(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	tempDir := t.TempDir()
+
+	// AVIF sequences count plays like WebP, 0 means forever
+	seqTests := []struct {
+		name   string
+		path   string
+		frames int
+		delay  int // Milliseconds
+		plays  int
+	}{
+		{"Fast", "gif/fps_fast.gif", 10, 40, 0},
+		{"Slow", "gif/fps_slow.gif", 10, 200, 0},
+		{"LongAnimation", "gif/frames_long.gif", 20, 100, 0},
+		{"LoopOnce", "gif/loop_loop_once.gif", 10, 100, 2},
+		{"Loop3Times", "gif/loop_loop_3times.gif", 10, 100, 4},
+	}
+
+	for _, test := range seqTests {
+		t.Run(test.name, func(t *testing.T) {
+			inputPath := filepath.Join("testdata", test.path)
+			outputPath := filepath.Join(tempDir, filepath.Base(test.path)+".avif")
+
+			// Check if input file exists
+			if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+				t.Skip("Test file not found:", inputPath)
+				return
+			}
+
+			result, err := converter.ToAVIF(inputPath, outputPath)
+			if err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+			if result.Frames != test.frames {
+				t.Errorf("Expected %d frames in result, got %d", test.frames, result.Frames)
+			}
+
+			output, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+
+			seq, err := readAVIFSequence(output)
+			if err != nil {
+				t.Fatalf("Failed to read AVIF sequence: %v", err)
+			}
+			if seq == nil {
+				t.Fatal("Output is not an AVIF image sequence")
+			}
+			if seq.plays != test.plays {
+				t.Errorf("Expected %d plays, got %d", test.plays, seq.plays)
+			}
+			if seq.frames != test.frames {
+				t.Fatalf("Expected %d samples, got %d", test.frames, seq.frames)
+			}
+			for i, duration := range sequenceDurations(seq) {
+				if duration != test.delay {
+					t.Errorf("Frame %d: expected %dms, got %dms", i, test.delay, duration)
+				}
+			}
+
+			// The primary image keeps the file viewable as a still AVIF
+			if _, err := parseAVIFStill(output); err != nil {
+				t.Errorf("Primary image is unreadable: %v", err)
+			}
+		})
+	}
+}
+
+func TestGIFToAVIFSingleFrame(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	tempDir := t.TempDir()
+
+	inputPath := "testdata/gif/frames_single.gif"
+	outputPath := filepath.Join(tempDir, "frames_single.avif")
+
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+
+	result, err := converter.ToAVIF(inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+	if result.Frames != 1 {
+		t.Errorf("Expected 1 frame, got %d", result.Frames)
+	}
+
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+
+	seq, err := readAVIFSequence(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if seq != nil {
+		t.Error("Single frame GIF should produce a still AVIF")
+	}
+}
+
+// writeZeroDelayGIF writes a two frame GIF whose first frame has no delay
+func writeZeroDelayGIF(t *testing.T, path string) {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{Delay: []int{0, 5}}
+	for i := range 2 {
+		frame := image.NewPaletted(image.Rect(0, 0, 32, 32), palette)
+		for y := range 32 {
+			for x := range 32 {
+				frame.SetColorIndex(x, y, uint8((x/8+y/8+i)%2))
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create GIF: %v", err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, anim); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+}
+
+func TestGIFZeroDelay(t *testing.T) {
+	tempDir := t.TempDir()
+	inputPath := filepath.Join(tempDir, "zero_delay.gif")
+	writeZeroDelayGIF(t, inputPath)
+	converter := NewConverter(ConverterConfig{})
+
+	// WebP and AVIF show a frame without a delay for the same time
+	expected := []int{gifDefaultDelay, 50}
+
+	webpPath := filepath.Join(tempDir, "zero_delay.webp")
+	if _, err := converter.ToWebP(inputPath, webpPath); err != nil {
+		t.Fatalf("WebP conversion failed: %v", err)
+	}
+	webpData, err := os.ReadFile(webpPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	webpAnim, err := readWebPAnimation(webpData)
+	if err != nil || webpAnim == nil {
+		t.Fatalf("Failed to read WebP animation: %v", err)
+	}
+
+	avifPath := filepath.Join(tempDir, "zero_delay.avif")
+	if _, err := converter.ToAVIF(inputPath, avifPath); err != nil {
+		t.Fatalf("AVIF conversion failed: %v", err)
+	}
+	avifData, err := os.ReadFile(avifPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	seq, err := readAVIFSequence(avifData)
+	if err != nil || seq == nil {
+		t.Fatalf("Failed to read AVIF sequence: %v", err)
+	}
+
+	for name, durations := range map[string][]int{"WebP": webpAnim.durations, "AVIF": sequenceDurations(seq)} {
+		if len(durations) != len(expected) {
+			t.Fatalf("%s: expected %d frames, got %d", name, len(expected), len(durations))
+		}
+		for i, duration := range durations {
+			if duration != expected[i] {
+				t.Errorf("%s frame %d: expected %dms, got %dms", name, i, expected[i], duration)
+			}
+		}
+	}
+}
+
+func TestGIFToAVIFOptions(t *testing.T) {
+	inputPath := "testdata/gif/fps_normal.gif"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+	tempDir := t.TempDir()
+
+	base, err := NewConverter(ConverterConfig{}).ToAVIF(inputPath, filepath.Join(tempDir, "base.avif"))
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	// The target size search encodes every frame at each step
+	config := ConverterConfig{}
+	config.TargetSize.MaxBytes = base.OutputSize / 2
+	result, err := NewConverter(config).ToAVIF(inputPath, filepath.Join(tempDir, "target.avif"))
+	if err != nil {
+		t.Fatalf("Target size conversion failed: %v", err)
+	}
+	if result.OutputSize > config.TargetSize.MaxBytes {
+		t.Errorf("Output %d bytes exceeds target %d", result.OutputSize, config.TargetSize.MaxBytes)
+	}
+	if result.Frames != base.Frames || result.Quality >= base.Quality {
+		t.Errorf("Expected %d frames below CQ %d, got %d frames at CQ %d", base.Frames, base.Quality, result.Frames, result.Quality)
+	}
+
+	// Encoder options are validated for GIF input too
+	config = ConverterConfig{}
+	config.GIFToAVIF.Effort = 10
+	if _, err := NewConverter(config).ToAVIF(inputPath, filepath.Join(tempDir, "effort.avif")); err == nil {
+		t.Error("Expected an error for an out of range effort")
+	}
+}
@@ -32,7 +32,7 @@ func TestGIFToWebP(t *testing.T) {
 			}
 
 			// Convert
-			err = converter.ToWebP(inputPath, outputPath)
+			_, err = converter.ToWebP(inputPath, outputPath)
 
 			// Check if it's a format error (expected for some test cases)
 			var formatErr *FormatError
@@ -103,7 +103,7 @@ func TestGIFAnimationProperties(t *testing.T) {
 				return
 			}
 
-			err := converter.ToWebP(inputPath, outputPath)
+			_, err := converter.ToWebP(inputPath, outputPath)
 
 			// Check if it's a format error
 			var formatErr *FormatError
@@ -143,13 +143,15 @@ func TestGIFLoopHandling(t *testing.T) {
 	tempDir := t.TempDir()
 
 	// Test loop settings
+	// WebP counts plays while GIF counts repetitions, 0 means forever in both
 	loopTests := []struct {
-		name string
-		path string
+		name      string
+		path      string
+		loopCount int
 	}{
-		{"InfiniteLoop", "gif/loop_loop_infinite.gif"},
-		{"LoopOnce", "gif/loop_loop_once.gif"},
-		{"Loop3Times", "gif/loop_loop_3times.gif"},
+		{"InfiniteLoop", "gif/loop_loop_infinite.gif", 0},
+		{"LoopOnce", "gif/loop_loop_once.gif", 2},
+		{"Loop3Times", "gif/loop_loop_3times.gif", 4},
 	}
 
 	for _, test := range loopTests {
@@ -163,7 +165,7 @@ func TestGIFLoopHandling(t *testing.T) {
 				return
 			}
 
-			err := converter.ToWebP(inputPath, outputPath)
+			_, err := converter.ToWebP(inputPath, outputPath)
 
 			// Check if it's a format error
 			var formatErr *FormatError
@@ -176,10 +178,154 @@ func TestGIFLoopHandling(t *testing.T) {
 				t.Fatalf("Conversion failed: %v", err)
 			}
 
-			// Just verify the file was created
-			if _, err := os.Stat(outputPath); err != nil {
-				t.Errorf("Output file not created: %v", err)
+			output, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Output file not created: %v", err)
+			}
+
+			anim, err := readWebPAnimation(output)
+			if err != nil {
+				t.Fatalf("Failed to read WebP animation: %v", err)
+			}
+			if anim == nil {
+				t.Fatal("Output is not an animated WebP")
+			}
+			if anim.loopCount != test.loopCount {
+				t.Errorf("Expected loop count %d, got %d", test.loopCount, anim.loopCount)
+			}
+		})
+	}
+}
+
+func TestGIFFrameTiming(t *testing.T) {
+	converter := NewConverter(ConverterConfig{})
+	tempDir := t.TempDir()
+
+	timingTests := []struct {
+		name  string
+		path  string
+		delay int // Milliseconds
+	}{
+		{"Fast", "gif/fps_fast.gif", 40},
+		{"Normal", "gif/fps_normal.gif", 100},
+		{"Slow", "gif/fps_slow.gif", 200},
+	}
+
+	for _, test := range timingTests {
+		t.Run(test.name, func(t *testing.T) {
+			inputPath := filepath.Join("testdata", test.path)
+			outputPath := filepath.Join(tempDir, filepath.Base(test.path)+".webp")
+
+			if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+				t.Skip("Test file not found:", inputPath)
+				return
+			}
+
+			if _, err := converter.ToWebP(inputPath, outputPath); err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+
+			output, err := os.ReadFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+
+			anim, err := readWebPAnimation(output)
+			if err != nil {
+				t.Fatalf("Failed to read WebP animation: %v", err)
+			}
+			if anim == nil {
+				t.Fatal("Output is not an animated WebP")
+			}
+
+			// Identical consecutive frames may be merged, but the total duration must match
+			total := 0
+			for i, duration := range anim.durations {
+				if duration%test.delay != 0 {
+					t.Errorf("Frame %d: duration %dms is not a multiple of %dms", i, duration, test.delay)
+				}
+				total += duration
+			}
+			if expected := 10 * test.delay; total != expected {
+				t.Errorf("Expected total duration %dms, got %dms", expected, total)
 			}
 		})
 	}
 }
+
+func TestGIFToWebPLossy(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Dithered GIFs often fail the size check as lossless WebP
+	inputPath := "testdata/gif/dither_dithered.gif"
+	inputBuffer, err := os.ReadFile(inputPath)
+	if err != nil {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+
+	anim, err := parseGIFAnimation(inputBuffer)
+	if err != nil {
+		t.Fatalf("Failed to parse GIF: %v", err)
+	}
+
+	convert := func(name string, config ConverterConfig) (*ConversionResult, []byte, error) {
+		outputPath := filepath.Join(tempDir, name+".webp")
+		result, err := NewConverter(config).ToWebP(inputPath, outputPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		output, err := os.ReadFile(outputPath)
+		return result, output, err
+	}
+
+	lossyConfig := ConverterConfig{}
+	lossyConfig.GIFToWebP.Lossy = true
+	lossyConfig.GIFToWebP.Quality = 70
+	lossyConfig.GIFToWebP.AlphaQuality = 50
+	lossy, lossyOutput, err := convert("lossy", lossyConfig)
+	if err != nil {
+		t.Fatalf("Lossy conversion failed: %v", err)
+	}
+
+	if lossy.Mode != EncoderModeLossy || lossy.Quality != 70 {
+		t.Errorf("Expected lossy quality 70, got %s %d", lossy.Mode, lossy.Quality)
+	}
+
+	// Timing is preserved in lossy mode too
+	if anim.frames > 1 {
+		webpAnim, err := readWebPAnimation(lossyOutput)
+		if err != nil || webpAnim == nil {
+			t.Fatalf("Output is not an animated WebP: %v", err)
+		}
+		if webpAnim.loopCount != anim.webpLoopCount() {
+			t.Errorf("Expected loop count %d, got %d", anim.webpLoopCount(), webpAnim.loopCount)
+		}
+	}
+
+	tryBothConfig := ConverterConfig{}
+	tryBothConfig.GIFToWebP.TryBoth = true
+	tryBoth, _, err := convert("try_both", tryBothConfig)
+	if err != nil {
+		t.Fatalf("Try both conversion failed: %v", err)
+	}
+
+	// Trying both never does worse than lossless alone
+	lossless, _, err := convert("lossless", ConverterConfig{})
+	var formatErr *FormatError
+	switch {
+	case errors.As(err, &formatErr):
+		t.Logf("Lossless is not smaller than the GIF: %v", err)
+	case err != nil:
+		t.Fatalf("Lossless conversion failed: %v", err)
+	default:
+		if lossless.Mode != EncoderModeLossless {
+			t.Errorf("Expected lossless by default, got %s", lossless.Mode)
+		}
+		if tryBoth.OutputSize > lossless.OutputSize {
+			t.Errorf("Try both (%d bytes) is larger than lossless (%d bytes)", tryBoth.OutputSize, lossless.OutputSize)
+		}
+	}
+
+	t.Logf("Lossy %d bytes, try both %d bytes (%s)", lossy.OutputSize, tryBoth.OutputSize, tryBoth.Mode)
+}
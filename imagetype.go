@@ -2,9 +2,11 @@ package nextgenimage
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 )
 
 // ImageType represents the type of an image file
@@ -13,22 +15,54 @@ type ImageType string
 const (
 	ImageTypeJPEG    ImageType = "jpeg"
 	ImageTypePNG     ImageType = "png"
+	ImageTypeAPNG    ImageType = "apng"
 	ImageTypeGIF     ImageType = "gif"
 	ImageTypeWebP    ImageType = "webp"
 	ImageTypeAVIF    ImageType = "avif"
+	ImageTypeHEIC    ImageType = "heic" // HEIF with HEVC coded images
+	ImageTypeHEIF    ImageType = "heif" // HEIF with other or unknown codecs
+	ImageTypeJXL     ImageType = "jxl"
+	ImageTypeTIFF    ImageType = "tiff"
+	ImageTypeBMP     ImageType = "bmp"
+	ImageTypeICO     ImageType = "ico"
+	ImageTypeSVG     ImageType = "svg"
 	ImageTypeUnknown ImageType = "unknown"
 )
 
+// detectWindowSize is how many leading bytes DetectImageTypeFromReader inspects, the same amount
+// net/http sniffs. It covers the ftyp brands, an acTL chunk near the start of a PNG and the XML
+// prolog before an SVG root element.
+const detectWindowSize = 512
+
 // Magic bytes for image format detection
 var (
-	jpegMagic1 = []byte{0xFF, 0xD8, 0xFF}
-	pngMagic   = []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
-	gifMagic1  = []byte{0x47, 0x49, 0x46, 0x38, 0x37, 0x61} // GIF87a
-	gifMagic2  = []byte{0x47, 0x49, 0x46, 0x38, 0x39, 0x61} // GIF89a
-	webpMagic  = []byte{0x52, 0x49, 0x46, 0x46}              // RIFF
-	webpType   = []byte{0x57, 0x45, 0x42, 0x50}              // WEBP
+	jpegMagic1         = []byte{0xFF, 0xD8, 0xFF}
+	pngMagic           = []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+	gifMagic1          = []byte{0x47, 0x49, 0x46, 0x38, 0x37, 0x61} // GIF87a
+	gifMagic2          = []byte{0x47, 0x49, 0x46, 0x38, 0x39, 0x61} // GIF89a
+	webpMagic          = []byte{0x52, 0x49, 0x46, 0x46}             // RIFF
+	webpType           = []byte{0x57, 0x45, 0x42, 0x50}             // WEBP
+	jxlCodestreamMagic = []byte{0xFF, 0x0A}
+	// "JXL " signature box
+	jxlContainerMagic = []byte{0x00, 0x00, 0x00, 0x0C, 0x4A, 0x58, 0x4C, 0x20, 0x0D, 0x0A, 0x87, 0x0A}
+	// Little and big endian, classic and BigTIFF
+	tiffMagics = [][]byte{[]byte("II*\x00"), []byte("MM\x00*"), []byte("II+\x00"), []byte("MM\x00+")}
+	bmpMagic   = []byte("BM")
+	icoMagic   = []byte{0x00, 0x00, 0x01, 0x00}
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// ISO base media file brands of still image and image sequence formats
+var (
+	avifBrands = []string{"avif", "avis"}
+	heicBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "hevm", "hevs"}
+	heifBrands = []string{"mif1", "msf1"} // Structural brands without a codec
 )
 
+// BMP DIB header sizes: BITMAPCOREHEADER, BITMAPINFOHEADER, the V2 and V3 extensions,
+// OS22XBITMAPHEADER, BITMAPV4HEADER and BITMAPV5HEADER
+var bmpHeaderSizes = []uint32{12, 40, 52, 56, 64, 108, 124}
+
 // DetectImageType detects the image type from a file path
 func DetectImageType(filePath string) (ImageType, error) {
 	file, err := os.Open(filePath)
@@ -42,9 +76,7 @@ func DetectImageType(filePath string) (ImageType, error) {
 
 // DetectImageTypeFromReader detects the image type from an io.Reader
 func DetectImageTypeFromReader(r io.Reader) (ImageType, error) {
-	// Read enough bytes to detect all formats
-	// AVIF needs more bytes due to its complex structure
-	buf := make([]byte, 32)
+	buf := make([]byte, detectWindowSize)
 	n, err := io.ReadFull(r, buf)
 	if err != nil && err != io.ErrUnexpectedEOF {
 		return ImageTypeUnknown, fmt.Errorf("failed to read magic bytes: %w", err)
@@ -65,6 +97,9 @@ func DetectImageTypeFromBytes(data []byte) ImageType {
 func detectFromBytes(buf []byte) (ImageType, error) {
 	// Check PNG
 	if len(buf) >= 8 && bytes.Equal(buf[:8], pngMagic) {
+		if isAPNG(buf) {
+			return ImageTypeAPNG, nil
+		}
 		return ImageTypePNG, nil
 	}
 
@@ -85,52 +120,125 @@ func detectFromBytes(buf []byte) (ImageType, error) {
 		return ImageTypeWebP, nil
 	}
 
-	// Check AVIF
-	// AVIF detection is more complex as it's based on ISO BMFF (MP4-like) structure
-	if len(buf) >= 12 && isAVIF(buf) {
-		return ImageTypeAVIF, nil
+	// Check AVIF and HEIF, both ISO base media files told apart by their brands
+	if fileType, ok := parseFileType(buf); ok {
+		return fileType.imageType(), nil
 	}
 
-	return ImageTypeUnknown, nil
+	return detectOtherFormat(buf), nil
 }
 
-// isAVIF checks if the data represents an AVIF image
-func isAVIF(buf []byte) bool {
-	// AVIF files start with an ftyp box
-	// Check for ftyp box signature at offset 4
-	if len(buf) < 12 {
-		return false
+// detectOtherFormat checks the signatures of JPEG XL, TIFF, BMP, ICO and SVG
+func detectOtherFormat(buf []byte) ImageType {
+	// Check JPEG XL, either a bare codestream or the ISO base media container
+	if bytes.HasPrefix(buf, jxlCodestreamMagic) || bytes.HasPrefix(buf, jxlContainerMagic) {
+		return ImageTypeJXL
 	}
 
-	// Box size (first 4 bytes) - we don't validate this
-	// Box type should be "ftyp" at offset 4-7
-	if !bytes.Equal(buf[4:8], []byte("ftyp")) {
-		return false
+	// Check TIFF
+	for _, magic := range tiffMagics {
+		if bytes.HasPrefix(buf, magic) {
+			return ImageTypeTIFF
+		}
 	}
 
-	// Check major brand at offset 8-11
-	majorBrand := string(buf[8:12])
-	
-	// AVIF major brands
-	avifBrands := []string{"avif", "avis"}
-	for _, brand := range avifBrands {
-		if majorBrand == brand {
-			return true
-		}
+	// Check BMP, whose two-byte signature alone is too weak
+	if len(buf) >= 18 && bytes.Equal(buf[:2], bmpMagic) &&
+		slices.Contains(bmpHeaderSizes, binary.LittleEndian.Uint32(buf[14:18])) {
+		return ImageTypeBMP
+	}
+
+	// Check ICO: reserved(2) type 1(2) image count(2)
+	if len(buf) >= 6 && bytes.Equal(buf[:4], icoMagic) && binary.LittleEndian.Uint16(buf[4:6]) > 0 {
+		return ImageTypeICO
 	}
 
-	// Also check compatible brands if we have enough data
-	if len(buf) >= 16 {
-		// Simple check: look for "avif" or "avis" in the compatible brands
-		// In a real implementation, we would parse the full ftyp box
-		compatibleBrands := string(buf[16:])
-		return bytes.Contains([]byte(compatibleBrands), []byte("avif")) ||
-			bytes.Contains([]byte(compatibleBrands), []byte("avis"))
+	// Check SVG
+	if isSVG(buf) {
+		return ImageTypeSVG
 	}
 
+	return ImageTypeUnknown
+}
+
+// imageType classifies an ISO base media file by its brands. The major brand is checked first,
+// then the compatible brands in order. HEIF structural brands count only without a codec brand.
+func (f fileType) imageType() ImageType {
+	brands := append([]string{f.majorBrand}, f.compatibleBrands...)
+	for _, brand := range brands {
+		switch {
+		case slices.Contains(avifBrands, brand):
+			return ImageTypeAVIF
+		case slices.Contains(heicBrands, brand):
+			return ImageTypeHEIC
+		}
+	}
+	for _, brand := range brands {
+		if slices.Contains(heifBrands, brand) {
+			return ImageTypeHEIF
+		}
+	}
+	return ImageTypeUnknown
+}
+
+// isAPNG returns true when an acTL chunk precedes the image data of a PNG
+func isAPNG(buf []byte) bool {
+	pos := len(pngMagic)
+	for pos+pngChunkHeader <= len(buf) {
+		size := binary.BigEndian.Uint32(buf[pos:])
+		switch string(buf[pos+4 : pos+8]) {
+		case "acTL":
+			return true
+		case "IDAT":
+			return false
+		}
+		// Compare before adding so a huge size cannot wrap pos on 32-bit platforms
+		if uint64(size) > uint64(len(buf)-pos-pngChunkHeader) {
+			return false
+		}
+		pos += pngChunkHeader + int(size) + pngChunkCRC
+	}
 	return false
 }
 
+// isSVG skips a byte order mark, whitespace, processing instructions, comments and a doctype,
+// then checks that the root element is svg
+func isSVG(buf []byte) bool {
+	rest := bytes.TrimPrefix(buf, utf8BOM)
+	for {
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+		end := -1
+		switch {
+		case bytes.HasPrefix(rest, []byte("<?")):
+			if i := bytes.Index(rest, []byte("?>")); i >= 0 {
+				end = i + 2
+			}
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			if i := bytes.Index(rest, []byte("-->")); i >= 0 {
+				end = i + 3
+			}
+		case bytes.HasPrefix(rest, []byte("<!")):
+			end = bytes.IndexByte(rest, '>') + 1
+			// An internal subset holds markup declarations of its own
+			if open := bytes.IndexByte(rest, '['); open >= 0 && open < end {
+				if i := bytes.Index(rest, []byte("]>")); i >= 0 {
+					end = i + 2
+				} else {
+					end = -1
+				}
+			}
+		case bytes.HasPrefix(rest, []byte("<svg")):
+			return len(rest) > 4 && bytes.IndexByte([]byte(" \t\r\n/>"), rest[4]) >= 0
+		default:
+			return false
+		}
+		if end <= 0 {
+			return false
+		}
+		rest = rest[end:]
+	}
+}
+
 // String returns the string representation of the ImageType
 func (t ImageType) String() string {
 	return string(t)
@@ -139,9 +247,9 @@ func (t ImageType) String() string {
 // IsSupported returns true if the image type is supported for conversion
 func (t ImageType) IsSupported() bool {
 	switch t {
-	case ImageTypeJPEG, ImageTypePNG, ImageTypeGIF:
+	case ImageTypeJPEG, ImageTypePNG, ImageTypeAPNG, ImageTypeGIF:
 		return true
 	default:
 		return false
 	}
-}
\ No newline at end of file
+}
@@ -2,6 +2,7 @@ package nextgenimage
 
 import (
 	"bytes"
+	"encoding/binary"
 	"io"
 	"os"
 	"path/filepath"
@@ -71,6 +72,111 @@ func TestDetectImageTypeFromBytes(t *testing.T) {
 			},
 			expected: ImageTypeAVIF,
 		},
+		{
+			name: "AVIF brand past the first 32 bytes",
+			data: append(isoBoxBytes("ftyp", []byte("mif1"), make([]byte, 4),
+				[]byte("miaf"), []byte("MA1B"), []byte("MiHE"), []byte("MiPr"), []byte("mif1"), []byte("avif")),
+				isoBoxBytes("meta")...),
+			expected: ImageTypeAVIF,
+		},
+		{
+			name:     "AVIF brand outside the declared ftyp size",
+			data:     append(isoBoxBytes("ftyp", []byte("mif1"), make([]byte, 4), []byte("mif1")), []byte("avif")...),
+			expected: ImageTypeHEIF,
+		},
+		{
+			name:     "HEIC",
+			data:     isoBoxBytes("ftyp", []byte("heic"), make([]byte, 4), []byte("mif1"), []byte("heic")),
+			expected: ImageTypeHEIC,
+		},
+		{
+			name:     "HEIC with mif1 major brand",
+			data:     isoBoxBytes("ftyp", []byte("mif1"), make([]byte, 4), []byte("mif1"), []byte("heix")),
+			expected: ImageTypeHEIC,
+		},
+		{
+			name:     "HEIF without a codec brand",
+			data:     isoBoxBytes("ftyp", []byte("mif1"), make([]byte, 4), []byte("mif1"), []byte("miaf")),
+			expected: ImageTypeHEIF,
+		},
+		{
+			name:     "MP4 video",
+			data:     isoBoxBytes("ftyp", []byte("isom"), make([]byte, 4), []byte("isom"), []byte("mp41")),
+			expected: ImageTypeUnknown,
+		},
+		{
+			name:     "APNG",
+			data:     buildTestPNG(testPNGChunk("IHDR", make([]byte, 13)), testPNGChunk("acTL", make([]byte, 8)), testPNGChunk("IDAT", nil)),
+			expected: ImageTypeAPNG,
+		},
+		{
+			name:     "PNG with acTL after the image data",
+			data:     buildTestPNG(testPNGChunk("IHDR", make([]byte, 13)), testPNGChunk("IDAT", nil), testPNGChunk("acTL", make([]byte, 8))),
+			expected: ImageTypePNG,
+		},
+		{
+			name:     "PNG with a chunk size past the end",
+			data:     buildTestPNG([]byte{0xFF, 0xFF, 0xFF, 0xF0, 't', 'E', 'X', 't'}, testPNGChunk("acTL", make([]byte, 8))),
+			expected: ImageTypePNG,
+		},
+		{
+			name:     "JPEG XL codestream",
+			data:     []byte{0xFF, 0x0A, 0xFA, 0x7F, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: ImageTypeJXL,
+		},
+		{
+			name:     "JPEG XL container",
+			data:     []byte{0x00, 0x00, 0x00, 0x0C, 0x4A, 0x58, 0x4C, 0x20, 0x0D, 0x0A, 0x87, 0x0A, 0x00, 0x00, 0x00, 0x14},
+			expected: ImageTypeJXL,
+		},
+		{
+			name:     "TIFF little endian",
+			data:     []byte{0x49, 0x49, 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: ImageTypeTIFF,
+		},
+		{
+			name:     "TIFF big endian",
+			data:     []byte{0x4D, 0x4D, 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00},
+			expected: ImageTypeTIFF,
+		},
+		{
+			name: "BMP",
+			data: []byte{
+				0x42, 0x4D, // BM
+				0x46, 0x00, 0x00, 0x00, // file size
+				0x00, 0x00, 0x00, 0x00, // reserved
+				0x36, 0x00, 0x00, 0x00, // pixel data offset
+				0x28, 0x00, 0x00, 0x00, // BITMAPINFOHEADER
+			},
+			expected: ImageTypeBMP,
+		},
+		{
+			name:     "Text starting with BM",
+			data:     []byte("BMW owners club newsletter"),
+			expected: ImageTypeUnknown,
+		},
+		{
+			name:     "ICO",
+			data:     []byte{0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x10, 0x10, 0x00, 0x00, 0x01, 0x00},
+			expected: ImageTypeICO,
+		},
+		{
+			name:     "SVG",
+			data:     []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`),
+			expected: ImageTypeSVG,
+		},
+		{
+			name: "SVG with prolog",
+			data: []byte("\xEF\xBB\xBF<?xml version=\"1.0\"?>\n<!-- Generator: editor -->\n" +
+				"<!DOCTYPE svg PUBLIC \"-//W3C//DTD SVG 1.1//EN\" \"http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd\" [\n" +
+				"  <!ENTITY ns \"http://www.w3.org/2000/svg\">\n]>\n<svg xmlns=\"&ns;\"></svg>"),
+			expected: ImageTypeSVG,
+		},
+		{
+			name:     "Other XML",
+			data:     []byte(`<?xml version="1.0"?><svgish></svgish>`),
+			expected: ImageTypeUnknown,
+		},
 		{
 			name:     "Unknown - too small",
 			data:     []byte{0x00, 0x01},
@@ -93,6 +199,23 @@ func TestDetectImageTypeFromBytes(t *testing.T) {
 	}
 }
 
+// testPNGChunk builds a PNG chunk with a zero CRC, which detection and probing do not check
+func testPNGChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return append(chunk, 0, 0, 0, 0)
+}
+
+// buildTestPNG joins the PNG signature and chunks
+func buildTestPNG(chunks ...[]byte) []byte {
+	buf := append([]byte{}, pngMagic...)
+	for _, chunk := range chunks {
+		buf = append(buf, chunk...)
+	}
+	return buf
+}
+
 func TestDetectImageTypeFromReader(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -189,6 +312,9 @@ func TestImageTypeString(t *testing.T) {
 		{ImageTypeGIF, "gif"},
 		{ImageTypeWebP, "webp"},
 		{ImageTypeAVIF, "avif"},
+		{ImageTypeAPNG, "apng"},
+		{ImageTypeHEIC, "heic"},
+		{ImageTypeJXL, "jxl"},
 		{ImageTypeUnknown, "unknown"},
 	}
 
@@ -211,6 +337,9 @@ func TestImageTypeIsSupported(t *testing.T) {
 		{ImageTypeGIF, true},
 		{ImageTypeWebP, false},
 		{ImageTypeAVIF, false},
+		{ImageTypeAPNG, true},
+		{ImageTypeHEIC, false},
+		{ImageTypeSVG, false},
 		{ImageTypeUnknown, false},
 	}
 
@@ -0,0 +1,222 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Photoshop image resource and IPTC-IIM layout
+const (
+	photoshopIRBSignature = "8BIM"
+	photoshopIPTCResource = 0x0404 // IPTC-NAA record
+	iimTagMarker          = 0x1C
+	iimHeaderSize         = 5 // Marker(1), record(1), dataset(1), size(2)
+)
+
+// photoshopHeader prefixes the APP13 payload that libvips stores as iptc-data
+var photoshopHeader = []byte("Photoshop 3.0\x00")
+
+// IIM datasets of the application record (2:xx) mirrored into XMP
+const (
+	iimObjectName = 5
+	iimKeywords   = 25
+	iimByline     = 80
+	iimCopyright  = 116
+	iimCaption    = 120
+)
+
+// iptcRecords holds the IPTC-IIM fields that map onto the Dublin Core XMP schema
+type iptcRecords struct {
+	title       string
+	keywords    []string
+	creators    []string
+	copyright   string
+	description string
+}
+
+// empty returns true when none of the mapped fields are set
+func (r iptcRecords) empty() bool {
+	return r.title == "" && len(r.keywords) == 0 && len(r.creators) == 0 && r.copyright == "" && r.description == ""
+}
+
+// readIPTCRecords reads the mapped IIM fields from Photoshop image resources, with or
+// without the "Photoshop 3.0" header of the JPEG APP13 segment
+func readIPTCRecords(buf []byte) (iptcRecords, error) {
+	buf = bytes.TrimPrefix(buf, photoshopHeader)
+
+	var records iptcRecords
+	pos := 0
+	for pos+len(photoshopIRBSignature)+2 <= len(buf) {
+		if string(buf[pos:pos+4]) != photoshopIRBSignature {
+			return records, fmt.Errorf("invalid Photoshop resource at offset %d", pos)
+		}
+		id := binary.BigEndian.Uint16(buf[pos+4 : pos+6])
+		pos += 6
+
+		// Pascal string name padded to an even size, then a 4 byte size
+		if pos >= len(buf) {
+			return records, fmt.Errorf("truncated Photoshop resource name")
+		}
+		nameSize := 1 + int(buf[pos])
+		pos += nameSize + nameSize&1
+		if pos+4 > len(buf) {
+			return records, fmt.Errorf("truncated Photoshop resource header")
+		}
+		size := uint64(binary.BigEndian.Uint32(buf[pos : pos+4]))
+		pos += 4
+		if size > uint64(len(buf)-pos) {
+			return records, fmt.Errorf("truncated Photoshop resource 0x%04X", id)
+		}
+		data := buf[pos : pos+int(size)]
+		// Resource data is padded to an even size
+		pos += int(size) + int(size&1)
+
+		if id == photoshopIPTCResource {
+			if err := records.readIIM(data); err != nil {
+				return records, err
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// readIIM reads the mapped datasets of the application record
+func (r *iptcRecords) readIIM(buf []byte) error {
+	pos := 0
+	for pos+iimHeaderSize <= len(buf) {
+		if buf[pos] != iimTagMarker {
+			// Writers pad the resource with zeros
+			break
+		}
+		record, dataset := buf[pos+1], buf[pos+2]
+		size := int(binary.BigEndian.Uint16(buf[pos+3 : pos+5]))
+		pos += iimHeaderSize
+		// Extended datasets set the top bit, none of the mapped fields use them
+		if size&0x8000 != 0 || size > len(buf)-pos {
+			return fmt.Errorf("invalid IIM dataset %d:%d", record, dataset)
+		}
+		value := iimString(buf[pos : pos+size])
+		pos += size
+
+		if record != 2 || value == "" {
+			continue
+		}
+		switch dataset {
+		case iimObjectName:
+			r.title = value
+		case iimKeywords:
+			r.keywords = append(r.keywords, value)
+		case iimByline:
+			r.creators = append(r.creators, value)
+		case iimCopyright:
+			r.copyright = value
+		case iimCaption:
+			r.description = value
+		}
+	}
+	return nil
+}
+
+// iimString decodes an IIM text value. Current writers use UTF-8, older ones Latin-1.
+func iimString(buf []byte) string {
+	if utf8.Valid(buf) {
+		return strings.TrimSpace(string(buf))
+	}
+	runes := make([]rune, len(buf))
+	for i, b := range buf {
+		runes[i] = rune(b)
+	}
+	return strings.TrimSpace(string(runes))
+}
+
+// xmp returns an XMP packet with the fields in the Dublin Core schema, where IPTC Core
+// and the Metadata Working Group guidelines place them
+func (r iptcRecords) xmp() []byte {
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\xEF\xBB\xBF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	r.writeDescription(&b)
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return []byte(b.String())
+}
+
+// mergeXMP adds the fields an existing XMP packet lacks as a second rdf:Description.
+// Fields the packet already has are left alone, the Metadata Working Group guidelines
+// treat XMP as authoritative when both are present. It returns false when nothing was added.
+func (r iptcRecords) mergeXMP(packet []byte) ([]byte, bool) {
+	if hasXMPProperty(packet, "dc:title") {
+		r.title = ""
+	}
+	if hasXMPProperty(packet, "dc:creator") {
+		r.creators = nil
+	}
+	if hasXMPProperty(packet, "dc:description") {
+		r.description = ""
+	}
+	if hasXMPProperty(packet, "dc:rights") {
+		r.copyright = ""
+	}
+	if hasXMPProperty(packet, "dc:subject") {
+		r.keywords = nil
+	}
+	end := bytes.LastIndex(packet, []byte("</rdf:RDF>"))
+	if r.empty() || end < 0 {
+		return packet, false
+	}
+
+	var b strings.Builder
+	b.Write(packet[:end])
+	r.writeDescription(&b)
+	b.Write(packet[end:])
+	return []byte(b.String()), true
+}
+
+// hasXMPProperty reports whether a packet sets a property as an element or an attribute
+func hasXMPProperty(packet []byte, property string) bool {
+	return bytes.Contains(packet, []byte("<"+property)) || bytes.Contains(packet, []byte(property+"="))
+}
+
+// writeDescription writes the fields as an rdf:Description of the Dublin Core schema
+func (r iptcRecords) writeDescription(b *strings.Builder) {
+	b.WriteString("  <rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n")
+	writeXMPList(b, "dc:title", "rdf:Alt", optional(r.title))
+	writeXMPList(b, "dc:creator", "rdf:Seq", r.creators)
+	writeXMPList(b, "dc:description", "rdf:Alt", optional(r.description))
+	writeXMPList(b, "dc:rights", "rdf:Alt", optional(r.copyright))
+	writeXMPList(b, "dc:subject", "rdf:Bag", r.keywords)
+	b.WriteString("  </rdf:Description>\n")
+}
+
+// optional returns a single item list, or nil for an empty value
+func optional(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+// writeXMPList writes an XMP array property. Language alternatives get the x-default language.
+func writeXMPList(b *strings.Builder, property, container string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "   <%s>\n    <%s>\n", property, container)
+	for _, item := range items {
+		if container == "rdf:Alt" {
+			b.WriteString("     <rdf:li xml:lang=\"x-default\">")
+		} else {
+			b.WriteString("     <rdf:li>")
+		}
+		xml.EscapeText(b, []byte(item))
+		b.WriteString("</rdf:li>\n")
+	}
+	fmt.Fprintf(b, "    </%s>\n   </%s>\n", container, property)
+}
@@ -0,0 +1,205 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"strings"
+	"testing"
+)
+
+// iimDataset is an application record dataset for building test resources
+type iimDataset struct {
+	dataset byte
+	value   string
+}
+
+// iptcResource builds the APP13 payload of a Photoshop IPTC-NAA resource
+func iptcResource(datasets []iimDataset) []byte {
+	var iim bytes.Buffer
+	for _, d := range datasets {
+		iim.Write([]byte{iimTagMarker, 2, d.dataset})
+		binary.Write(&iim, binary.BigEndian, uint16(len(d.value)))
+		iim.WriteString(d.value)
+	}
+	if iim.Len()%2 == 1 {
+		iim.WriteByte(0)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(photoshopHeader)
+	buf.WriteString(photoshopIRBSignature)
+	binary.Write(&buf, binary.BigEndian, uint16(photoshopIPTCResource))
+	buf.Write([]byte{0, 0}) // Empty name padded to an even size
+	binary.Write(&buf, binary.BigEndian, uint32(iim.Len()))
+	buf.Write(iim.Bytes())
+	return buf.Bytes()
+}
+
+// testXMPCreator returns an XMP packet that only sets dc:creator
+func testXMPCreator(creator string) string {
+	return `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:creator><rdf:Seq><rdf:li>` + creator + `</rdf:li></rdf:Seq></dc:creator>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+}
+
+// writeIPTCJPEG writes a JPEG with an APP13 IPTC segment and, unless xmpPacket is empty,
+// an APP1 XMP segment
+func writeIPTCJPEG(t *testing.T, path string, datasets []iimDataset, xmpPacket string) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 128, 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2]) // SOI
+	if xmpPacket != "" {
+		payload := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmpPacket...)
+		out.Write([]byte{0xFF, 0xE1})
+		binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+		out.Write(payload)
+	}
+	payload := iptcResource(datasets)
+	out.Write([]byte{0xFF, 0xED})
+	binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(encoded.Bytes()[2:])
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write JPEG: %v", err)
+	}
+}
+
+func TestReadIPTCRecords(t *testing.T) {
+	resource := iptcResource([]iimDataset{
+		{iimObjectName, "Harbor"},
+		{iimKeywords, "sea"},
+		{iimKeywords, "boats"},
+		{iimByline, "Jane Doe"},
+		{iimCopyright, "(c) Example & Co"},
+		{iimCaption, "Boats at dawn"},
+		{55, "20240101"}, // Date created is not mapped
+	})
+
+	for _, buf := range [][]byte{resource, bytes.TrimPrefix(resource, photoshopHeader)} {
+		records, err := readIPTCRecords(buf)
+		if err != nil {
+			t.Fatalf("readIPTCRecords failed: %v", err)
+		}
+		if records.title != "Harbor" || records.copyright != "(c) Example & Co" || records.description != "Boats at dawn" {
+			t.Errorf("Unexpected text fields: %+v", records)
+		}
+		if strings.Join(records.keywords, ",") != "sea,boats" || strings.Join(records.creators, ",") != "Jane Doe" {
+			t.Errorf("Unexpected list fields: %+v", records)
+		}
+	}
+
+	// Latin-1 values from older writers are converted to UTF-8
+	records, err := readIPTCRecords(iptcResource([]iimDataset{{iimByline, "Jos\xe9"}}))
+	if err != nil {
+		t.Fatalf("readIPTCRecords failed: %v", err)
+	}
+	if len(records.creators) != 1 || records.creators[0] != "José" {
+		t.Errorf("Expected Latin-1 creator to decode, got %q", records.creators)
+	}
+}
+
+func TestReadIPTCRecordsInvalid(t *testing.T) {
+	resource := iptcResource([]iimDataset{{iimByline, "Jane Doe"}})
+	invalid := [][]byte{
+		[]byte("Photoshop 3.0\x00XXXX\x04\x04\x00\x00\x00\x00\x00\x00"), // Bad signature
+		resource[:len(resource)-4],                                      // Truncated resource data
+	}
+	for i, buf := range invalid {
+		if _, err := readIPTCRecords(buf); err == nil {
+			t.Errorf("Case %d: expected error", i)
+		}
+	}
+
+	// A dataset size past the end of the resource
+	bad := iptcResource([]iimDataset{{iimByline, "Jane Doe"}})
+	binary.BigEndian.PutUint16(bad[bytes.Index(bad, []byte("Jane Doe"))-2:], 0x7FFF)
+	if _, err := readIPTCRecords(bad); err == nil {
+		t.Error("Expected error for oversized dataset")
+	}
+}
+
+func TestIPTCRecordsXMP(t *testing.T) {
+	records := iptcRecords{
+		title:     "Harbor",
+		creators:  []string{"Jane <Doe>"},
+		copyright: "(c) Example & Co",
+		keywords:  []string{"sea", "boats"},
+	}
+	packet := string(records.xmp())
+
+	for _, want := range []string{
+		`<rdf:li xml:lang="x-default">Harbor</rdf:li>`,
+		`<dc:creator>`,
+		`<rdf:li>Jane &lt;Doe&gt;</rdf:li>`,
+		`(c) Example &amp; Co`,
+		`<rdf:li>sea</rdf:li>`,
+		`<?xpacket end="w"?>`,
+	} {
+		if !strings.Contains(packet, want) {
+			t.Errorf("XMP packet is missing %q:\n%s", want, packet)
+		}
+	}
+	if strings.Contains(packet, "dc:description") {
+		t.Error("Empty description should be left out")
+	}
+	if !(iptcRecords{}).empty() || records.empty() {
+		t.Error("Unexpected empty result")
+	}
+}
+
+func TestIPTCRecordsMergeXMP(t *testing.T) {
+	records := iptcRecords{creators: []string{"IIM Creator"}, copyright: "(c) IIM Rights"}
+
+	merged, ok := records.mergeXMP([]byte(testXMPCreator("XMP Creator")))
+	if !ok {
+		t.Fatal("Expected the copyright to be merged")
+	}
+	packet := string(merged)
+	if strings.Count(packet, "<dc:creator>") != 1 || !strings.Contains(packet, "XMP Creator") || strings.Contains(packet, "IIM Creator") {
+		t.Errorf("Existing creator should win:\n%s", packet)
+	}
+	if !strings.Contains(packet, `<rdf:li xml:lang="x-default">(c) IIM Rights</rdf:li>`) {
+		t.Errorf("Copyright was not merged:\n%s", packet)
+	}
+	if strings.Index(packet, "(c) IIM Rights") > strings.Index(packet, "</rdf:RDF>") {
+		t.Errorf("Merged description is outside rdf:RDF:\n%s", packet)
+	}
+
+	// Nothing to add, or no rdf:RDF to add it to
+	creatorOnly := iptcRecords{creators: []string{"IIM Creator"}}
+	if _, ok := creatorOnly.mergeXMP([]byte(testXMPCreator("XMP Creator"))); ok {
+		t.Error("Expected no change when the packet has every field")
+	}
+	if _, ok := records.mergeXMP([]byte("<x:xmpmeta/>")); ok {
+		t.Error("Expected no change for a packet without rdf:RDF")
+	}
+
+	// Attribute form properties count as present
+	attribute := []byte(`<rdf:RDF><rdf:Description dc:rights="(c) XMP"/></rdf:RDF>`)
+	merged, _ = records.mergeXMP(attribute)
+	if strings.Contains(string(merged), "IIM Rights") {
+		t.Error("Copyright set as an attribute should win")
+	}
+}
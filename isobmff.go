@@ -0,0 +1,230 @@
+package nextgenimage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ISO base media file format box layout
+const (
+	isoBoxHeaderSize     = 8  // Size, type
+	isoFullBoxHeaderSize = 4  // Version, flags
+	isoLargeSizeHeader   = 16 // Size 1, type, 64-bit size
+)
+
+// isoBox is a box from an ISO base media file (HEIF, AVIF, MP4)
+type isoBox struct {
+	boxType string
+	raw     []byte // Whole box including the header
+	data    []byte // Payload without the header
+}
+
+// readISOBoxes lists the boxes stored back to back in buf
+func readISOBoxes(buf []byte) ([]isoBox, error) {
+	var boxes []isoBox
+	pos := 0
+	for pos < len(buf) {
+		if pos+isoBoxHeaderSize > len(buf) {
+			return nil, fmt.Errorf("truncated box header at offset %d", pos)
+		}
+
+		size := uint64(binary.BigEndian.Uint32(buf[pos:]))
+		boxType := string(buf[pos+4 : pos+8])
+		header := isoBoxHeaderSize
+		switch size {
+		case 0:
+			// The box extends to the end of the data
+			size = uint64(len(buf) - pos)
+		case 1:
+			if pos+isoLargeSizeHeader > len(buf) {
+				return nil, fmt.Errorf("truncated box header at offset %d", pos)
+			}
+			size = binary.BigEndian.Uint64(buf[pos+8:])
+			header = isoLargeSizeHeader
+		}
+		if size < uint64(header) || size > uint64(len(buf)-pos) {
+			return nil, fmt.Errorf("invalid size for box %q at offset %d", boxType, pos)
+		}
+
+		end := pos + int(size)
+		boxes = append(boxes, isoBox{
+			boxType: boxType,
+			raw:     buf[pos:end],
+			data:    buf[pos+header : end],
+		})
+		pos = end
+	}
+
+	return boxes, nil
+}
+
+// fileType holds the brands of the ftyp box opening an ISO base media file
+type fileType struct {
+	majorBrand       string
+	compatibleBrands []string
+}
+
+// parseFileType reads the ftyp box at the start of buf using its declared size. When the box
+// extends past buf, as with a detection window, the brands that fit are kept.
+func parseFileType(buf []byte) (fileType, bool) {
+	if len(buf) < isoBoxHeaderSize || string(buf[4:8]) != "ftyp" {
+		return fileType{}, false
+	}
+
+	size := uint64(binary.BigEndian.Uint32(buf))
+	header := isoBoxHeaderSize
+	switch size {
+	case 0:
+		size = uint64(len(buf))
+	case 1:
+		if len(buf) < isoLargeSizeHeader {
+			return fileType{}, false
+		}
+		size = binary.BigEndian.Uint64(buf[8:])
+		header = isoLargeSizeHeader
+	}
+	// Major brand(4) minor version(4), then compatible brands(4 each)
+	if size < uint64(header+8) || len(buf) < header+8 {
+		return fileType{}, false
+	}
+
+	ft := fileType{majorBrand: string(buf[header : header+4])}
+	end := min(size, uint64(len(buf)))
+	for pos := uint64(header + 8); pos+4 <= end; pos += 4 {
+		ft.compatibleBrands = append(ft.compatibleBrands, string(buf[pos:pos+4]))
+	}
+	return ft, true
+}
+
+// findISOBox returns the first box of the given type
+func findISOBox(boxes []isoBox, boxType string) (isoBox, bool) {
+	for _, box := range boxes {
+		if box.boxType == boxType {
+			return box, true
+		}
+	}
+	return isoBox{}, false
+}
+
+// findISOPath returns the payload of the box found by descending through the given box types
+func findISOPath(data []byte, types ...string) ([]byte, error) {
+	for _, boxType := range types {
+		boxes, err := readISOBoxes(data)
+		if err != nil {
+			return nil, err
+		}
+		box, ok := findISOBox(boxes, boxType)
+		if !ok {
+			return nil, fmt.Errorf("missing %s box", boxType)
+		}
+		data = box.data
+	}
+	return data, nil
+}
+
+// readISOFullBox splits a full box payload into version, flags and the rest
+func readISOFullBox(data []byte) (byte, uint32, []byte, error) {
+	if len(data) < isoFullBoxHeaderSize {
+		return 0, 0, nil, fmt.Errorf("truncated full box header")
+	}
+	return data[0], binary.BigEndian.Uint32(data[:4]) & 0xFFFFFF, data[isoFullBoxHeaderSize:], nil
+}
+
+// isoReader reads big-endian fields from a box payload
+type isoReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+// uint reads an unsigned integer of 0, 1, 2, 4 or 8 bytes
+func (r *isoReader) uint(size int) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+size > len(r.buf) {
+		r.err = fmt.Errorf("unexpected end of box data")
+		return 0
+	}
+
+	b := r.buf[r.pos : r.pos+size]
+	r.pos += size
+	switch size {
+	case 0:
+		return 0
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b))
+	case 8:
+		return binary.BigEndian.Uint64(b)
+	default:
+		r.err = fmt.Errorf("unsupported field size %d", size)
+		return 0
+	}
+}
+
+// skip advances past n bytes of fields that are not needed
+func (r *isoReader) skip(n int) {
+	if r.err == nil && r.pos+n > len(r.buf) {
+		r.err = fmt.Errorf("unexpected end of box data")
+		return
+	}
+	r.pos += n
+}
+
+// fourCC reads a four character code
+func (r *isoReader) fourCC() string {
+	if r.err != nil {
+		return ""
+	}
+	if r.pos+4 > len(r.buf) {
+		r.err = fmt.Errorf("unexpected end of box data")
+		return ""
+	}
+	s := string(r.buf[r.pos : r.pos+4])
+	r.pos += 4
+	return s
+}
+
+// isoWriter builds box payloads with big-endian fields
+type isoWriter struct {
+	buf []byte
+}
+
+func (w *isoWriter) u8(v uint8)   { w.buf = append(w.buf, v) }
+func (w *isoWriter) u16(v uint16) { w.buf = binary.BigEndian.AppendUint16(w.buf, v) }
+func (w *isoWriter) u32(v uint32) { w.buf = binary.BigEndian.AppendUint32(w.buf, v) }
+func (w *isoWriter) u64(v uint64) { w.buf = binary.BigEndian.AppendUint64(w.buf, v) }
+
+// bytes appends raw data such as four character codes, strings or child boxes
+func (w *isoWriter) bytes(b ...[]byte) {
+	for _, p := range b {
+		w.buf = append(w.buf, p...)
+	}
+}
+
+// zeros appends n zero bytes for reserved and pre-defined fields
+func (w *isoWriter) zeros(n int) { w.buf = append(w.buf, make([]byte, n)...) }
+
+// isoBoxBytes wraps a payload into a box
+func isoBoxBytes(boxType string, payload ...[]byte) []byte {
+	size := isoBoxHeaderSize
+	for _, p := range payload {
+		size += len(p)
+	}
+
+	w := isoWriter{buf: make([]byte, 0, size)}
+	w.u32(uint32(size))
+	w.bytes([]byte(boxType))
+	w.bytes(payload...)
+	return w.buf
+}
+
+// isoFullBoxBytes wraps a payload into a full box with version and flags
+func isoFullBoxBytes(boxType string, version byte, flags uint32, payload ...[]byte) []byte {
+	header := binary.BigEndian.AppendUint32(nil, uint32(version)<<24|flags&0xFFFFFF)
+	return isoBoxBytes(boxType, append([][]byte{header}, payload...)...)
+}
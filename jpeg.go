@@ -0,0 +1,142 @@
+package nextgenimage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// JPEG markers used when reading headers
+const (
+	jpegMarkerSOI  = 0xD8
+	jpegMarkerEOI  = 0xD9
+	jpegMarkerSOS  = 0xDA
+	jpegMarkerAPP1 = 0xE1
+)
+
+// jpegSegment is a marker segment from a JPEG header
+type jpegSegment struct {
+	marker byte
+	data   []byte // Payload without the marker and length bytes
+}
+
+// readJPEGSegments returns the marker segments of a JPEG stream up to the start of scan
+func readJPEGSegments(buf []byte) ([]jpegSegment, error) {
+	if len(buf) < 4 || buf[0] != 0xFF || buf[1] != jpegMarkerSOI {
+		return nil, fmt.Errorf("missing JPEG SOI marker")
+	}
+
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(buf) {
+		if buf[pos] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", pos)
+		}
+		marker := buf[pos+1]
+		// Fill bytes before a marker are allowed
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		// Standalone markers carry no length
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+		if marker == jpegMarkerEOI {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(buf[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(buf) {
+			return nil, fmt.Errorf("truncated JPEG segment 0x%02X at offset %d", marker, pos)
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: buf[pos+4 : pos+2+length]})
+		pos += 2 + length
+
+		if marker == jpegMarkerSOS {
+			break
+		}
+	}
+
+	return segments, nil
+}
+
+// isJPEGSOF returns true for the start-of-frame markers (SOF0-SOF15 except DHT, JPG and DAC)
+func isJPEGSOF(marker byte) bool {
+	return marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+}
+
+// jpegColorInfo describes how the color components of a JPEG are stored
+type jpegColorInfo struct {
+	components int      // Number of components in the frame
+	sampling   [][2]int // Horizontal and vertical sampling factors per component
+}
+
+// isCMYK returns true for CMYK and YCCK encoded JPEGs
+func (i jpegColorInfo) isCMYK() bool {
+	return i.components == 4
+}
+
+// parseJPEGColorInfo reads the component count and sampling factors from a JPEG header
+func parseJPEGColorInfo(buf []byte) (jpegColorInfo, error) {
+	segments, err := readJPEGSegments(buf)
+	if err != nil {
+		return jpegColorInfo{}, err
+	}
+
+	var info jpegColorInfo
+	for _, seg := range segments {
+		if isJPEGSOF(seg.marker) {
+			// precision(1) height(2) width(2) components(1), then id(1) sampling(1) table(1) each
+			if len(seg.data) < 6 {
+				return info, fmt.Errorf("truncated JPEG frame header")
+			}
+			info.components = int(seg.data[5])
+			if len(seg.data) < 6+3*info.components {
+				return info, fmt.Errorf("truncated JPEG frame header")
+			}
+			info.sampling = make([][2]int, info.components)
+			for i := range info.sampling {
+				factors := seg.data[6+3*i+1]
+				info.sampling[i] = [2]int{int(factors >> 4), int(factors & 0x0F)}
+			}
+		}
+	}
+
+	if info.components == 0 {
+		return info, fmt.Errorf("no JPEG frame header found")
+	}
+
+	return info, nil
+}
+
+// subsampling returns the chroma subsampling of a YCbCr JPEG in J:a:b notation,
+// "4:0:0" for grayscale and an empty string for CMYK or unusual sampling factors
+func (i jpegColorInfo) subsampling() string {
+	switch len(i.sampling) {
+	case 1:
+		return "4:0:0"
+	case 3:
+	default:
+		return ""
+	}
+
+	luma, cb, cr := i.sampling[0], i.sampling[1], i.sampling[2]
+	if cb != cr || cb[0] == 0 || cb[1] == 0 || luma[0]%cb[0] != 0 || luma[1]%cb[1] != 0 {
+		return ""
+	}
+	switch [2]int{luma[0] / cb[0], luma[1] / cb[1]} {
+	case [2]int{1, 1}:
+		return "4:4:4"
+	case [2]int{2, 1}:
+		return "4:2:2"
+	case [2]int{2, 2}:
+		return "4:2:0"
+	case [2]int{1, 2}:
+		return "4:4:0"
+	case [2]int{4, 1}:
+		return "4:1:1"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,91 @@
+package nextgenimage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseJPEGColorInfo(t *testing.T) {
+	tests := []struct {
+		path       string
+		components int
+	}{
+		{"testdata/jpeg/colorspace_rgb.jpg", 3},
+		{"testdata/jpeg/colorspace_grayscale.jpg", 1},
+		{"testdata/jpeg/colorspace_cmyk.jpg", 4},
+		{"testdata/jpeg/critical_cmyk_lowquality.jpg", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			data, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+
+			info, err := parseJPEGColorInfo(data)
+			if err != nil {
+				t.Fatalf("parseJPEGColorInfo failed: %v", err)
+			}
+
+			if info.components != tt.components {
+				t.Errorf("Expected %d components, got %d", tt.components, info.components)
+			}
+			if info.isCMYK() != (tt.components == 4) {
+				t.Errorf("Unexpected isCMYK result: %v", info.isCMYK())
+			}
+		})
+	}
+}
+
+func TestReadJPEGSegmentsInvalid(t *testing.T) {
+	invalid := [][]byte{
+		nil,
+		[]byte("not a jpeg"),
+		{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A}, // Truncated APP0
+		{0xFF, 0xD8, 0x00, 0x00, 0x00, 0x00},       // Missing marker prefix
+	}
+
+	for i, data := range invalid {
+		if _, err := readJPEGSegments(data); err == nil {
+			t.Errorf("Case %d: expected error", i)
+		}
+	}
+
+	if _, err := parseJPEGColorInfo([]byte{0xFF, 0xD8, 0xFF, 0xD9}); err == nil {
+		t.Error("Expected error for JPEG without frame header")
+	}
+}
+
+func TestJPEGSubsampling(t *testing.T) {
+	tests := []struct {
+		path        string
+		subsampling string
+	}{
+		{"testdata/jpeg/subsampling_444.jpg", "4:4:4"},
+		{"testdata/jpeg/subsampling_422.jpg", "4:2:2"},
+		{"testdata/jpeg/subsampling_420.jpg", "4:2:0"},
+		{"testdata/jpeg/colorspace_grayscale.jpg", "4:0:0"},
+		{"testdata/jpeg/colorspace_cmyk.jpg", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			data, err := os.ReadFile(tt.path)
+			if os.IsNotExist(err) {
+				t.Skip("Test file not found:", tt.path)
+			}
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+
+			info, err := parseJPEGColorInfo(data)
+			if err != nil {
+				t.Fatalf("parseJPEGColorInfo failed: %v", err)
+			}
+			if got := info.subsampling(); got != tt.subsampling {
+				t.Errorf("Expected %q, got %q (sampling %v)", tt.subsampling, got, info.sampling)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package nextgenimage
+
+import (
+	"fmt"
+	"os"
+)
+
+// jpegMarkerDQT defines quantization tables
+const jpegMarkerDQT = 0xDB
+
+// jpegNaturalOrder maps the zigzag position of a DCT coefficient to its row-major index
+var jpegNaturalOrder = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegStandardLuminance is the luminance table from Annex K of the JPEG standard in row-major
+// order, which libjpeg scales by the quality setting
+var jpegStandardLuminance = [64]int{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+// EstimateJPEGQuality estimates the libjpeg quality setting (1-100) a JPEG file was saved with
+func EstimateJPEGQuality(path string) (int, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return estimateJPEGQuality(buf)
+}
+
+// estimateJPEGQuality finds the quality whose scaled standard luminance table is closest to
+// the stored one. Encoders with their own tables get the nearest libjpeg equivalent.
+func estimateJPEGQuality(buf []byte) (int, error) {
+	if DetectImageTypeFromBytes(buf) != ImageTypeJPEG {
+		return 0, newKindError(ErrUnsupportedFormat, fmt.Errorf("not a JPEG image"))
+	}
+	table, err := readJPEGLuminanceTable(buf)
+	if err != nil {
+		return 0, newKindError(ErrDecodeFailed, err)
+	}
+
+	best, bestDiff := 0, -1
+	for quality := 1; quality <= 100; quality++ {
+		scale := 200 - 2*quality
+		if quality < 50 {
+			scale = 5000 / quality
+		}
+
+		diff := 0
+		for k, value := range table {
+			expected := (jpegStandardLuminance[jpegNaturalOrder[k]]*scale + 50) / 100
+			expected = min(max(expected, 1), 255)
+			if d := value - expected; d < 0 {
+				diff -= d
+			} else {
+				diff += d
+			}
+		}
+		// Later qualities win ties, the tables of the highest settings clamp to the same values
+		if bestDiff < 0 || diff <= bestDiff {
+			best, bestDiff = quality, diff
+		}
+	}
+
+	return best, nil
+}
+
+// readJPEGLuminanceTable returns quantization table 0 in zigzag order
+func readJPEGLuminanceTable(buf []byte) ([64]int, error) {
+	var table [64]int
+	segments, err := readJPEGSegments(buf)
+	if err != nil {
+		return table, err
+	}
+
+	for _, seg := range segments {
+		if seg.marker != jpegMarkerDQT {
+			continue
+		}
+		// A segment holds one or more tables: precision and id(1), then 64 values of 8 or 16 bits
+		data := seg.data
+		for len(data) > 0 {
+			precision, id := data[0]>>4, data[0]&0x0F
+			size := 64
+			if precision != 0 {
+				size = 128
+			}
+			if len(data) < 1+size {
+				return table, fmt.Errorf("truncated JPEG quantization table")
+			}
+			if id == 0 {
+				for k := range table {
+					if precision != 0 {
+						table[k] = int(data[1+2*k])<<8 | int(data[2+2*k])
+					} else {
+						table[k] = int(data[1+k])
+					}
+				}
+				return table, nil
+			}
+			data = data[1+size:]
+		}
+	}
+
+	return table, fmt.Errorf("no JPEG luminance quantization table found")
+}
+
+// adaptiveQualityCap maps an estimated JPEG quality onto an encoder scale ending at maxQuality,
+// so re-encoding never asks for more detail than the source still holds
+func adaptiveQualityCap(sourceQuality, maxQuality int) int {
+	return max((sourceQuality*maxQuality+50)/100, 1)
+}
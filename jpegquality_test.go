@@ -0,0 +1,163 @@
+package nextgenimage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateJPEGQuality(t *testing.T) {
+	tests := []struct {
+		path    string
+		quality int
+	}{
+		{"testdata/jpeg/quality_20.jpg", 20},
+		{"testdata/jpeg/quality_50.jpg", 50},
+		{"testdata/jpeg/quality_80.jpg", 80},
+		{"testdata/jpeg/quality_95.jpg", 95},
+		{"testdata/jpeg/colorspace_grayscale.jpg", 95},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			if _, err := os.Stat(tt.path); os.IsNotExist(err) {
+				t.Skip("Test file not found:", tt.path)
+			}
+			quality, err := EstimateJPEGQuality(tt.path)
+			if err != nil {
+				t.Fatalf("EstimateJPEGQuality failed: %v", err)
+			}
+			if quality != tt.quality {
+				t.Errorf("Expected quality %d, got %d", tt.quality, quality)
+			}
+		})
+	}
+}
+
+func TestEstimateJPEGQualityInvalid(t *testing.T) {
+	if _, err := estimateJPEGQuality(pngMagic); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat for PNG data, got %v", err)
+	}
+
+	// SOI followed by a start of scan, without quantization tables
+	noTables := []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}
+	if _, err := estimateJPEGQuality(noTables); !errors.Is(err, ErrDecodeFailed) {
+		t.Errorf("Expected ErrDecodeFailed without tables, got %v", err)
+	}
+}
+
+func TestAdaptiveQualityCap(t *testing.T) {
+	tests := []struct {
+		source, max, expected int
+	}{
+		{20, webpMaxQuality, 20},
+		{95, webpMaxQuality, 95},
+		{20, avifMaxQuality, 20},
+		{95, 50, 48},
+		{1, 50, 1},
+	}
+	for _, tt := range tests {
+		if got := adaptiveQualityCap(tt.source, tt.max); got != tt.expected {
+			t.Errorf("adaptiveQualityCap(%d, %d) = %d, expected %d", tt.source, tt.max, got, tt.expected)
+		}
+	}
+}
+
+func TestJPEGAVIFMaxCQ(t *testing.T) {
+	tests := []struct {
+		name     string
+		adaptive bool
+		quality  int
+		maxCQ    int
+	}{
+		{"Not adaptive", false, 80, 100},
+		{"Capped", true, 80, 80},
+		{"Unknown source quality", true, 0, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ConverterConfig{}
+			config.JPEGToAVIF.AdaptiveQuality = tt.adaptive
+			input := &decodedInput{quality: tt.quality}
+
+			if maxCQ := NewConverter(config).jpegAVIFMaxCQ(input); maxCQ != tt.maxCQ {
+				t.Errorf("Expected max CQ %d, got %d", tt.maxCQ, maxCQ)
+			}
+		})
+	}
+}
+
+func TestAdaptiveQualityFullChroma(t *testing.T) {
+	inputPath := "testdata/jpeg/quality_80.jpg"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+	}
+
+	// Capping the CQ of a Q 80 source leaves its chroma matched
+	config := ConverterConfig{}
+	config.JPEGToAVIF.CQ = 100
+	config.JPEGToAVIF.AdaptiveQuality = true
+	result, err := NewConverter(config).ToAVIF(inputPath, filepath.Join(t.TempDir(), "out.avif"))
+	if errors.As(err, new(*FormatError)) {
+		t.Skipf("AVIF not applicable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("AVIF conversion failed: %v", err)
+	}
+	if result.Quality > result.SourceQuality {
+		t.Errorf("Expected CQ %d or below, got %d", result.SourceQuality, result.Quality)
+	}
+	if result.SourceSubsampling == subsampling444 && result.Subsampling != subsampling444 {
+		t.Errorf("Expected a 4:4:4 source to stay 4:4:4 at CQ %d, got %s", result.Quality, result.Subsampling)
+	}
+}
+
+func TestAdaptiveQuality(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := ConverterConfig{}
+	config.JPEGToWebP.AdaptiveQuality = true
+	config.JPEGToAVIF.AdaptiveQuality = true
+	converter := NewConverter(config)
+
+	tests := []struct {
+		path        string
+		webpQuality int
+		avifCQ      int
+	}{
+		{"testdata/jpeg/quality_20.jpg", 20, 20}, // Capped by the source
+		{"testdata/jpeg/quality_95.jpg", 80, 25}, // The configured quality is below the cap
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			if _, err := os.Stat(tt.path); os.IsNotExist(err) {
+				t.Skip("Test file not found:", tt.path)
+			}
+
+			webp, err := converter.ToWebP(tt.path, filepath.Join(tempDir, "out.webp"))
+			if errors.As(err, new(*FormatError)) {
+				t.Skipf("WebP not applicable: %v", err)
+			}
+			if err != nil {
+				t.Fatalf("WebP conversion failed: %v", err)
+			}
+			if webp.Quality != tt.webpQuality {
+				t.Errorf("Expected WebP quality %d, got %d (source %d)", tt.webpQuality, webp.Quality, webp.SourceQuality)
+			}
+
+			avif, err := converter.ToAVIF(tt.path, filepath.Join(tempDir, "out.avif"))
+			if errors.As(err, new(*FormatError)) {
+				t.Skipf("AVIF not applicable: %v", err)
+			}
+			if err != nil {
+				t.Fatalf("AVIF conversion failed: %v", err)
+			}
+			if avif.Quality != tt.avifCQ {
+				t.Errorf("Expected AVIF CQ %d, got %d (source %d)", tt.avifCQ, avif.Quality, avif.SourceQuality)
+			}
+		})
+	}
+}
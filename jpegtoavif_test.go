@@ -33,7 +33,7 @@ func TestJPEGToAVIF(t *testing.T) {
 			}
 
 			// Convert
-			err = converter.ToAVIF(inputPath, outputPath)
+			_, err = converter.ToAVIF(inputPath, outputPath)
 
 			// Check if it's a format error (expected for some test cases)
 			var formatErr *FormatError
@@ -136,7 +136,7 @@ func TestJPEGToAVIFCQSettings(t *testing.T) {
 			inputPath := "testdata/test_original.jpg"
 			outputPath := filepath.Join(tempDir, fmt.Sprintf("cq_%d.avif", cq))
 
-			err := converter.ToAVIF(inputPath, outputPath)
+			_, err := converter.ToAVIF(inputPath, outputPath)
 			if err != nil {
 				t.Fatalf("Conversion failed: %v", err)
 			}
@@ -173,7 +173,7 @@ func TestAVIFColorProfiles(t *testing.T) {
 				return
 			}
 
-			err := converter.ToAVIF(inputPath, outputPath)
+			_, err := converter.ToAVIF(inputPath, outputPath)
 
 			// Check if it's a format error
 			var formatErr *FormatError
@@ -93,7 +93,7 @@ func TestJPEGToWebP(t *testing.T) {
 			}
 
 			// Convert
-			err = converter.ToWebP(inputPath, outputPath)
+			_, err = converter.ToWebP(inputPath, outputPath)
 
 			// Check if it's a format error (expected for some test cases)
 			var formatErr *FormatError
@@ -196,7 +196,7 @@ func TestJPEGToWebPQualitySettings(t *testing.T) {
 			inputPath := "testdata/test_original.jpg"
 			outputPath := filepath.Join(tempDir, fmt.Sprintf("quality_%d.webp", quality))
 
-			err := converter.ToWebP(inputPath, outputPath)
+			_, err := converter.ToWebP(inputPath, outputPath)
 			if err != nil {
 				t.Fatalf("Conversion failed: %v", err)
 			}
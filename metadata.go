@@ -0,0 +1,169 @@
+package nextgenimage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// EXIFPolicy selects which EXIF fields survive conversion
+type EXIFPolicy int
+
+const (
+	EXIFStrip       EXIFPolicy = iota // Drop all EXIF data (default)
+	EXIFKeep                          // Keep all EXIF data
+	EXIFKeepPrivate                   // Keep EXIF data except GPS location and serial numbers
+)
+
+// MetadataPolicy selects which metadata is embedded in the output.
+// The zero value strips everything, which was the only behavior before the policy existed.
+type MetadataPolicy struct {
+	ICC  bool       // Keep the embedded ICC profile (WebP ICCP chunk, AVIF colr box)
+	EXIF EXIFPolicy // EXIF fields to keep (WebP EXIF chunk, AVIF Exif item)
+	XMP  bool       // Keep the XMP packet (WebP XMP chunk, AVIF XMP item)
+	// IPTC keeps IPTC metadata. WebP and AVIF have no place for IPTC-IIM records, so
+	// the title, creator, description, copyright and keywords of the IIM records are
+	// written to the XMP packet where it lacks them, creating one if needed. Values the
+	// packet already has win, and other IIM fields are dropped.
+	IPTC bool
+}
+
+// stripAll returns true when the policy keeps no metadata at all
+func (p MetadataPolicy) stripAll() bool {
+	return !p.ICC && p.EXIF == EXIFStrip && !p.XMP && !p.IPTC
+}
+
+// Metadata field names used by libvips
+const (
+	vipsFieldEXIF = "exif-data"
+	vipsFieldXMP  = "xmp-data"
+	vipsFieldIPTC = "iptc-data"
+)
+
+// animationFields are kept regardless of the policy so animated output keeps its timing
+var animationFields = []string{"delay", "loop", "gif-delay", "gif-loop"}
+
+// keepEXIFField reports whether an exif-* field survives the given policy
+func keepEXIFField(field string, policy EXIFPolicy) bool {
+	switch policy {
+	case EXIFKeep:
+		return true
+	case EXIFKeepPrivate:
+		// libvips names fields exif-ifd<N>-<Tag>, and IFD 3 is the GPS IFD
+		if strings.HasPrefix(field, "exif-ifd3-") {
+			return false
+		}
+		return !strings.Contains(field, "SerialNumber")
+	default:
+		return false
+	}
+}
+
+// applyMetadataPolicy trims the image metadata down to what the policy keeps.
+// It returns true when nothing is kept, in which case exporters should strip all metadata.
+func applyMetadataPolicy(image *vips.ImageRef, policy MetadataPolicy) (bool, error) {
+	if policy.stripAll() {
+		return true, nil
+	}
+
+	fields := image.GetFields()
+	if policy.IPTC {
+		fields = iptcToXMP(image, fields)
+	}
+
+	keep := append([]string{}, animationFields...)
+	for _, field := range fields {
+		switch {
+		case field == vipsFieldXMP:
+			if policy.XMP || policy.IPTC {
+				keep = append(keep, field)
+			}
+		case field == vipsFieldIPTC:
+			if policy.IPTC {
+				keep = append(keep, field)
+			}
+		case field == vipsFieldEXIF:
+			if policy.EXIF != EXIFStrip {
+				keep = append(keep, field)
+			}
+		case strings.HasPrefix(field, "exif-"):
+			if keepEXIFField(field, policy.EXIF) {
+				keep = append(keep, field)
+			}
+		}
+	}
+
+	if err := image.RemoveMetadata(keep...); err != nil {
+		return false, err
+	}
+
+	// RemoveMetadata never touches the ICC profile
+	if !policy.ICC && image.HasICCProfile() {
+		if err := image.RemoveICCProfile(); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// iptcToXMP writes the mapped IPTC-IIM records of an image into its XMP packet, creating
+// one when there is none, and returns the updated field list. Unreadable records are left
+// out like any other metadata the outputs cannot carry.
+func iptcToXMP(image *vips.ImageRef, fields []string) []string {
+	hasIPTC, hasXMP := false, false
+	for _, field := range fields {
+		switch field {
+		case vipsFieldXMP:
+			hasXMP = true
+		case vipsFieldIPTC:
+			hasIPTC = true
+		}
+	}
+	if !hasIPTC {
+		return fields
+	}
+
+	records, err := readIPTCRecords(image.GetBlob(vipsFieldIPTC))
+	if err != nil || records.empty() {
+		return fields
+	}
+	if hasXMP {
+		if packet, ok := records.mergeXMP(image.GetBlob(vipsFieldXMP)); ok {
+			image.SetBlob(vipsFieldXMP, packet)
+		}
+		return fields
+	}
+	image.SetBlob(vipsFieldXMP, records.xmp())
+	return append(fields, vipsFieldXMP)
+}
+
+// writeICCProfile writes the image's ICC profile to a temporary file for exporters that
+// only accept a profile path. It returns an empty path when there is no profile to embed.
+// The returned cleanup function removes the file and is always safe to call.
+func writeICCProfile(image *vips.ImageRef) (string, func(), error) {
+	noop := func() {}
+	if !image.HasICCProfile() {
+		return "", noop, nil
+	}
+
+	file, err := os.CreateTemp("", "nextgenimage-*.icc")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create ICC profile file: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+
+	if _, err := file.Write(image.GetICCProfile()); err != nil {
+		file.Close()
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write ICC profile file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write ICC profile file: %w", err)
+	}
+
+	return file.Name(), cleanup, nil
+}
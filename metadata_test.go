@@ -3,6 +3,7 @@ package nextgenimage
 import (
 	"errors"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/davidbyttow/govips/v2/vips"
@@ -113,7 +114,7 @@ func TestMetadataRemoval(t *testing.T) {
 			if !tc.skipWebP {
 				t.Run("WebP", func(t *testing.T) {
 					outputPath := filepath.Join(tempDir, filepath.Base(tc.inputPath)+".webp")
-					err := converter.ToWebP(tc.inputPath, outputPath)
+					_, err := converter.ToWebP(tc.inputPath, outputPath)
 					if err != nil {
 						t.Fatalf("WebP conversion failed: %v", err)
 					}
@@ -140,7 +141,7 @@ func TestMetadataRemoval(t *testing.T) {
 			if !tc.skipAVIF {
 				t.Run("AVIF", func(t *testing.T) {
 					outputPath := filepath.Join(tempDir, filepath.Base(tc.inputPath)+".avif")
-					err := converter.ToAVIF(tc.inputPath, outputPath)
+					_, err := converter.ToAVIF(tc.inputPath, outputPath)
 					if err != nil {
 						var formatErr *FormatError
 						if errors.As(err, &formatErr) {
@@ -199,7 +200,7 @@ func TestICCProfileRemoval(t *testing.T) {
 			// Test WebP conversion
 			t.Run("WebP", func(t *testing.T) {
 				outputPath := filepath.Join(tempDir, filepath.Base(inputPath)+".webp")
-				err := converter.ToWebP(inputPath, outputPath)
+				_, err := converter.ToWebP(inputPath, outputPath)
 				if err != nil {
 					t.Fatalf("WebP conversion failed: %v", err)
 				}
@@ -223,7 +224,7 @@ func TestICCProfileRemoval(t *testing.T) {
 			// Test AVIF conversion
 			t.Run("AVIF", func(t *testing.T) {
 				outputPath := filepath.Join(tempDir, filepath.Base(inputPath)+".avif")
-				err := converter.ToAVIF(inputPath, outputPath)
+				_, err := converter.ToAVIF(inputPath, outputPath)
 				if err != nil {
 					t.Fatalf("AVIF conversion failed: %v", err)
 				}
@@ -245,4 +246,217 @@ func TestICCProfileRemoval(t *testing.T) {
 			})
 		})
 	}
-}
\ No newline at end of file
+}
+
+// hasField checks if the image has the given libvips metadata field
+func hasField(t *testing.T, imagePath, field string) bool {
+	t.Helper()
+
+	image, err := vips.NewImageFromFile(imagePath)
+	if err != nil {
+		t.Fatalf("Failed to load image for field check: %v", err)
+	}
+	defer image.Close()
+
+	for _, f := range image.GetFields() {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGPS checks if the image has any EXIF GPS fields
+func hasGPS(t *testing.T, imagePath string) bool {
+	t.Helper()
+
+	image, err := vips.NewImageFromFile(imagePath)
+	if err != nil {
+		t.Fatalf("Failed to load image for GPS check: %v", err)
+	}
+	defer image.Close()
+
+	for field := range image.GetExif() {
+		if strings.HasPrefix(field, "exif-ifd3-") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestKeepEXIFField(t *testing.T) {
+	tests := []struct {
+		field  string
+		policy EXIFPolicy
+		want   bool
+	}{
+		{"exif-ifd0-Artist", EXIFStrip, false},
+		{"exif-ifd0-Artist", EXIFKeep, true},
+		{"exif-ifd0-Copyright", EXIFKeepPrivate, true},
+		{"exif-ifd3-GPSLatitude", EXIFKeep, true},
+		{"exif-ifd3-GPSLatitude", EXIFKeepPrivate, false},
+		{"exif-ifd2-BodySerialNumber", EXIFKeepPrivate, false},
+		{"exif-ifd2-LensSerialNumber", EXIFKeepPrivate, false},
+		{"exif-ifd2-ExposureTime", EXIFKeepPrivate, true},
+	}
+
+	for _, tt := range tests {
+		if got := keepEXIFField(tt.field, tt.policy); got != tt.want {
+			t.Errorf("keepEXIFField(%q, %d) = %v, want %v", tt.field, tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestMetadataPolicy(t *testing.T) {
+	tempDir := t.TempDir()
+
+	type converterFunc func(*Converter, string, string) (*ConversionResult, error)
+	formats := []struct {
+		ext     string
+		convert converterFunc
+	}{
+		{"webp", (*Converter).ToWebP},
+		{"avif", (*Converter).ToAVIF},
+	}
+
+	t.Run("EXIF without GPS", func(t *testing.T) {
+		inputPath := "testdata/jpeg/metadata_gps.jpg"
+		config := ConverterConfig{}
+		config.Metadata.EXIF = EXIFKeepPrivate
+		converter := NewConverter(config)
+
+		for _, f := range formats {
+			outputPath := filepath.Join(tempDir, "gps_private."+f.ext)
+			if _, err := f.convert(converter, inputPath, outputPath); err != nil {
+				t.Fatalf("%s conversion failed: %v", f.ext, err)
+			}
+
+			hasEXIF, _, _ := checkMetadata(t, outputPath)
+			if !hasEXIF {
+				t.Errorf("%s: EXIF was not preserved", f.ext)
+			}
+			if hasGPS(t, outputPath) {
+				t.Errorf("%s: GPS fields were not removed", f.ext)
+			}
+		}
+	})
+
+	t.Run("EXIF with GPS", func(t *testing.T) {
+		inputPath := "testdata/jpeg/metadata_gps.jpg"
+		if !hasGPS(t, inputPath) {
+			t.Skip("Original image has no GPS fields")
+		}
+
+		config := ConverterConfig{}
+		config.Metadata.EXIF = EXIFKeep
+		converter := NewConverter(config)
+
+		outputPath := filepath.Join(tempDir, "gps_keep.webp")
+		if _, err := converter.ToWebP(inputPath, outputPath); err != nil {
+			t.Fatalf("WebP conversion failed: %v", err)
+		}
+		if !hasGPS(t, outputPath) {
+			t.Error("GPS fields were not preserved")
+		}
+	})
+
+	t.Run("ICC", func(t *testing.T) {
+		inputPath := "testdata/jpeg/icc_adobergb.jpg"
+		config := ConverterConfig{}
+		config.Metadata.ICC = true
+		converter := NewConverter(config)
+
+		for _, f := range formats {
+			outputPath := filepath.Join(tempDir, "icc_keep."+f.ext)
+			if _, err := f.convert(converter, inputPath, outputPath); err != nil {
+				t.Fatalf("%s conversion failed: %v", f.ext, err)
+			}
+
+			hasEXIF, _, hasICC := checkMetadata(t, outputPath)
+			if !hasICC {
+				t.Errorf("%s: ICC profile was not preserved", f.ext)
+			}
+			if hasEXIF {
+				t.Errorf("%s: EXIF should have been removed", f.ext)
+			}
+		}
+	})
+
+	t.Run("IPTC to XMP", func(t *testing.T) {
+		// The fixtures carry no APP13 segment, so the IIM records are synthesized
+		inputPath := filepath.Join(tempDir, "iptc_only.jpg")
+		writeIPTCJPEG(t, inputPath, []iimDataset{
+			{iimByline, "Jane Doe"},
+			{iimCopyright, "(c) Example"},
+			{iimKeywords, "harbor"},
+		}, "")
+		if hasField(t, inputPath, "xmp-data") || !hasField(t, inputPath, "iptc-data") {
+			t.Fatal("Synthesized input should carry IPTC without XMP")
+		}
+
+		for _, policy := range []MetadataPolicy{{IPTC: true}, {XMP: true}} {
+			config := ConverterConfig{}
+			config.Metadata = policy
+			converter := NewConverter(config)
+
+			for _, f := range formats {
+				outputPath := filepath.Join(tempDir, "iptc_only."+f.ext)
+				if _, err := f.convert(converter, inputPath, outputPath); err != nil {
+					t.Fatalf("%s conversion failed: %v", f.ext, err)
+				}
+				if !policy.IPTC {
+					if hasField(t, outputPath, "xmp-data") {
+						t.Errorf("%s: XMP packet was written without the IPTC policy", f.ext)
+					}
+					continue
+				}
+
+				output, err := vips.NewImageFromFile(outputPath)
+				if err != nil {
+					t.Fatalf("Failed to load output: %v", err)
+				}
+				packet := string(output.GetBlob("xmp-data"))
+				output.Close()
+				for _, want := range []string{"Jane Doe", "(c) Example", "harbor"} {
+					if !strings.Contains(packet, want) {
+						t.Errorf("%s: XMP packet is missing %q", f.ext, want)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("IPTC merged into XMP", func(t *testing.T) {
+		// metadata_iptc.jpg and critical_xmp_iptc_conflict.jpg carry neither segment, so the
+		// conflict is synthesized: XMP has a creator, IIM a different creator and a copyright
+		inputPath := filepath.Join(tempDir, "iptc_conflict.jpg")
+		writeIPTCJPEG(t, inputPath, []iimDataset{
+			{iimByline, "IIM Creator"},
+			{iimCopyright, "(c) IIM Rights"},
+		}, testXMPCreator("XMP Creator"))
+
+		config := ConverterConfig{}
+		config.Metadata.IPTC = true
+		converter := NewConverter(config)
+
+		for _, f := range formats {
+			outputPath := filepath.Join(tempDir, "iptc_conflict."+f.ext)
+			if _, err := f.convert(converter, inputPath, outputPath); err != nil {
+				t.Fatalf("%s conversion failed: %v", f.ext, err)
+			}
+
+			output, err := vips.NewImageFromFile(outputPath)
+			if err != nil {
+				t.Fatalf("Failed to load output: %v", err)
+			}
+			packet := string(output.GetBlob("xmp-data"))
+			output.Close()
+			if !strings.Contains(packet, "XMP Creator") || strings.Contains(packet, "IIM Creator") {
+				t.Errorf("%s: expected the XMP creator to win, got:\n%s", f.ext, packet)
+			}
+			if !strings.Contains(packet, "(c) IIM Rights") {
+				t.Errorf("%s: IIM copyright was not merged, got:\n%s", f.ext, packet)
+			}
+		}
+	})
+}
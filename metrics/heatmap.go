@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+// heatmapSaturation is the per-channel difference rendered at full heat
+const heatmapSaturation = 64
+
+// Heatmap renders the per-pixel difference of distorted against reference. Each pixel takes the
+// largest difference of its RGB channels and maps it onto a black, red, yellow, white ramp that
+// saturates at a difference of 64, so faint artifacts stay visible.
+func Heatmap(reference, distorted *Image) (*image.RGBA, error) {
+	if err := checkSize(reference, distorted); err != nil {
+		return nil, err
+	}
+
+	heatmap := image.NewRGBA(image.Rect(0, 0, reference.Width, reference.Height))
+	for p := 0; p < reference.Width*reference.Height; p++ {
+		diff := 0
+		for c := 0; c < 3; c++ {
+			d := int(reference.Pix[p*3+c]) - int(distorted.Pix[p*3+c])
+			diff = max(diff, d, -d)
+		}
+		heatmap.SetRGBA(p%reference.Width, p/reference.Width, heatColor(diff))
+	}
+
+	return heatmap, nil
+}
+
+// heatColor maps a channel difference onto the heat ramp
+func heatColor(diff int) color.RGBA {
+	// Three equal segments: black to red, red to yellow, yellow to white
+	t := min(diff, heatmapSaturation) * 3 * 255 / heatmapSaturation
+	switch {
+	case t <= 255:
+		return color.RGBA{R: uint8(t), A: 255}
+	case t <= 2*255:
+		return color.RGBA{R: 255, G: uint8(t - 255), A: 255}
+	default:
+		return color.RGBA{R: 255, G: 255, B: uint8(t - 2*255), A: 255}
+	}
+}
+
+// HeatmapFiles decodes two image files and writes their difference heatmap as a PNG
+func HeatmapFiles(referencePath, distortedPath, outputPath string) error {
+	referenceImage, err := DecodeFile(referencePath)
+	if err != nil {
+		return fmt.Errorf("reference: %w", err)
+	}
+	distortedImage, err := DecodeFile(distortedPath)
+	if err != nil {
+		return fmt.Errorf("distorted: %w", err)
+	}
+
+	heatmap, err := Heatmap(referenceImage, distortedImage)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create heatmap file: %w", err)
+	}
+	if err := png.Encode(file, heatmap); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to encode heatmap: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,170 @@
+// Package metrics measures how close a converted image is to its source with PSNR, SSIM and
+// MS-SSIM, and renders per-pixel difference heatmaps. Images are decoded with libvips the same
+// way the converter loads them, so WebP and AVIF outputs compare directly to JPEG and PNG sources.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Image holds the decoded 8-bit sRGB pixels of an image
+type Image struct {
+	Width  int
+	Height int
+	Pix    []byte // RGB samples, 3 bytes per pixel, row by row
+}
+
+// Result holds the scores of a distorted image against its reference
+type Result struct {
+	PSNR   float64 // Peak signal-to-noise ratio in dB over RGB, +Inf for identical images
+	SSIM   float64 // Structural similarity of the luma planes (0-1)
+	MSSSIM float64 // Multi-scale structural similarity of the luma planes (0-1)
+}
+
+// Decode decodes an image buffer in any format libvips reads
+func Decode(buf []byte) (*Image, error) {
+	image, err := vips.NewImageFromBuffer(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+	defer image.Close()
+
+	// Auto-rotate based on EXIF orientation, like the converter does before encoding
+	if err := image.AutoRotate(); err != nil {
+		return nil, fmt.Errorf("failed to auto-rotate: %w", err)
+	}
+
+	return FromVips(image)
+}
+
+// DecodeFile decodes an image file in any format libvips reads
+func DecodeFile(path string) (*Image, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return Decode(buf)
+}
+
+// FromVips reads the pixels of a loaded image, converting to 8-bit sRGB and flattening any
+// alpha onto black. The image itself is left untouched.
+func FromVips(image *vips.ImageRef) (*Image, error) {
+	rgb, err := image.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy image: %w", err)
+	}
+	defer rgb.Close()
+
+	if rgb.HasAlpha() {
+		if err := rgb.Flatten(&vips.Color{}); err != nil {
+			return nil, fmt.Errorf("failed to flatten alpha: %w", err)
+		}
+	}
+	if err := rgb.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return nil, fmt.Errorf("failed to convert to sRGB: %w", err)
+	}
+	if rgb.BandFormat() != vips.BandFormatUchar {
+		if err := rgb.Cast(vips.BandFormatUchar); err != nil {
+			return nil, fmt.Errorf("failed to cast to 8-bit: %w", err)
+		}
+	}
+
+	pix, err := rgb.ToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pixels: %w", err)
+	}
+	if len(pix) != rgb.Width()*rgb.Height()*3 {
+		return nil, fmt.Errorf("unexpected pixel layout with %d bands", rgb.Bands())
+	}
+
+	return &Image{Width: rgb.Width(), Height: rgb.Height(), Pix: pix}, nil
+}
+
+// Luma returns the BT.601 luma plane of the image
+func (i *Image) Luma() []byte {
+	luma := make([]byte, i.Width*i.Height)
+	for p := range luma {
+		r, g, b := int(i.Pix[p*3]), int(i.Pix[p*3+1]), int(i.Pix[p*3+2])
+		luma[p] = byte((299*r + 587*g + 114*b + 500) / 1000)
+	}
+	return luma
+}
+
+// checkSize rejects images of different dimensions
+func checkSize(reference, distorted *Image) error {
+	if reference.Width != distorted.Width || reference.Height != distorted.Height {
+		return fmt.Errorf("image size %dx%d does not match the reference %dx%d",
+			distorted.Width, distorted.Height, reference.Width, reference.Height)
+	}
+	return nil
+}
+
+// PSNR returns the peak signal-to-noise ratio in dB of distorted against reference over
+// all RGB samples, or +Inf when the images are identical
+func PSNR(reference, distorted *Image) (float64, error) {
+	if err := checkSize(reference, distorted); err != nil {
+		return 0, err
+	}
+
+	var sum float64
+	for p := range reference.Pix {
+		d := float64(reference.Pix[p]) - float64(distorted.Pix[p])
+		sum += d * d
+	}
+	if sum == 0 {
+		return math.Inf(1), nil
+	}
+
+	mse := sum / float64(len(reference.Pix))
+	return 10 * math.Log10(255*255/mse), nil
+}
+
+// Compare computes all scores of distorted against reference
+func Compare(reference, distorted *Image) (*Result, error) {
+	psnr, err := PSNR(reference, distorted)
+	if err != nil {
+		return nil, err
+	}
+
+	referenceLuma, distortedLuma := reference.Luma(), distorted.Luma()
+	return &Result{
+		PSNR:   psnr,
+		SSIM:   SSIMPlane(referenceLuma, distortedLuma, reference.Width, reference.Height),
+		MSSSIM: MSSSIMPlane(referenceLuma, distortedLuma, reference.Width, reference.Height),
+	}, nil
+}
+
+// CompareBytes decodes two image buffers and computes all scores of distorted against reference
+func CompareBytes(reference, distorted []byte) (*Result, error) {
+	referenceImage, err := Decode(reference)
+	if err != nil {
+		return nil, fmt.Errorf("reference: %w", err)
+	}
+	distortedImage, err := Decode(distorted)
+	if err != nil {
+		return nil, fmt.Errorf("distorted: %w", err)
+	}
+	return Compare(referenceImage, distortedImage)
+}
+
+// CompareFiles decodes two image files and computes all scores of distorted against reference
+func CompareFiles(referencePath, distortedPath string) (*Result, error) {
+	referenceImage, err := DecodeFile(referencePath)
+	if err != nil {
+		return nil, fmt.Errorf("reference: %w", err)
+	}
+	distortedImage, err := DecodeFile(distortedPath)
+	if err != nil {
+		return nil, fmt.Errorf("distorted: %w", err)
+	}
+	return Compare(referenceImage, distortedImage)
+}
+
+func init() {
+	// Initialize vips once, this is a no-op when the converter already did
+	vips.Startup(nil)
+}
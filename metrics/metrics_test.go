@@ -0,0 +1,166 @@
+package metrics_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nextgenimage "github.com/ideamans/go-next-gen-image"
+	"github.com/ideamans/go-next-gen-image/metrics"
+)
+
+// gradientImage builds an RGB image with a horizontal gradient and some texture
+func gradientImage(width, height int) *metrics.Image {
+	pix := make([]byte, width*height*3)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := byte((x*255/width + (x*y)%17) % 256)
+			p := (y*width + x) * 3
+			pix[p], pix[p+1], pix[p+2] = v, 255-v, v/2
+		}
+	}
+	return &metrics.Image{Width: width, Height: height, Pix: pix}
+}
+
+// noisyImage adds deterministic noise of the given amplitude to every sample
+func noisyImage(image *metrics.Image, amplitude int) *metrics.Image {
+	pix := make([]byte, len(image.Pix))
+	for i, v := range image.Pix {
+		noise := ((i*7919)%9 - 4) * amplitude
+		pix[i] = byte(min(255, max(0, int(v)+noise)))
+	}
+	return &metrics.Image{Width: image.Width, Height: image.Height, Pix: pix}
+}
+
+func TestCompareSynthetic(t *testing.T) {
+	reference := gradientImage(128, 96)
+
+	identical, err := metrics.Compare(reference, reference)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !math.IsInf(identical.PSNR, 1) {
+		t.Errorf("Identical images should have infinite PSNR, got %f", identical.PSNR)
+	}
+	if math.Abs(identical.SSIM-1) > 1e-9 || math.Abs(identical.MSSSIM-1) > 1e-9 {
+		t.Errorf("Identical images should score 1, got SSIM %f MS-SSIM %f", identical.SSIM, identical.MSSSIM)
+	}
+
+	// More distortion scores lower on every metric
+	light, err := metrics.Compare(reference, noisyImage(reference, 1))
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	heavy, err := metrics.Compare(reference, noisyImage(reference, 8))
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !(heavy.PSNR < light.PSNR && heavy.SSIM < light.SSIM && heavy.MSSSIM < light.MSSSIM) {
+		t.Errorf("Expected light %+v to beat heavy %+v", light, heavy)
+	}
+	if light.SSIM >= 1 || light.MSSSIM >= 1 {
+		t.Errorf("Distorted image should score below 1, got %+v", light)
+	}
+
+	// Different sizes cannot be compared
+	if _, err := metrics.Compare(reference, gradientImage(64, 96)); err == nil {
+		t.Error("Expected an error for mismatched sizes")
+	}
+}
+
+func TestPSNR(t *testing.T) {
+	// Every sample off by 1 gives an MSE of 1
+	reference := &metrics.Image{Width: 2, Height: 1, Pix: []byte{10, 20, 30, 40, 50, 60}}
+	distorted := &metrics.Image{Width: 2, Height: 1, Pix: []byte{11, 19, 31, 39, 51, 59}}
+
+	psnr, err := metrics.PSNR(reference, distorted)
+	if err != nil {
+		t.Fatalf("PSNR failed: %v", err)
+	}
+	if want := 10 * math.Log10(255*255); math.Abs(psnr-want) > 1e-9 {
+		t.Errorf("Expected PSNR %f, got %f", want, psnr)
+	}
+}
+
+func TestSSIMPlane(t *testing.T) {
+	// Planes smaller than a window are compared as a whole
+	if s := metrics.SSIMPlane([]byte{10, 20, 30, 40}, []byte{10, 20, 30, 40}, 2, 2); math.Abs(s-1) > 1e-9 {
+		t.Errorf("Identical tiny planes should score 1, got %f", s)
+	}
+	if s := metrics.MSSSIMPlane([]byte{10, 20, 30, 40}, []byte{10, 20, 30, 40}, 2, 2); math.Abs(s-1) > 1e-9 {
+		t.Errorf("Identical tiny planes should score 1 on MS-SSIM, got %f", s)
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	reference := &metrics.Image{Width: 3, Height: 1, Pix: []byte{0, 0, 0, 100, 100, 100, 200, 200, 200}}
+	distorted := &metrics.Image{Width: 3, Height: 1, Pix: []byte{0, 0, 0, 132, 100, 100, 100, 200, 200}}
+
+	heatmap, err := metrics.Heatmap(reference, distorted)
+	if err != nil {
+		t.Fatalf("Heatmap failed: %v", err)
+	}
+
+	tests := []struct {
+		x    int
+		want [3]uint8
+	}{
+		{0, [3]uint8{0, 0, 0}},       // No difference
+		{1, [3]uint8{255, 127, 0}},   // Half of saturation
+		{2, [3]uint8{255, 255, 255}}, // Saturated
+	}
+	for _, tt := range tests {
+		c := heatmap.RGBAAt(tt.x, 0)
+		if [3]uint8{c.R, c.G, c.B} != tt.want {
+			t.Errorf("Pixel %d: expected %v, got %v", tt.x, tt.want, c)
+		}
+	}
+}
+
+func TestCompareConversion(t *testing.T) {
+	inputPath := "../testdata/test_original.jpg"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+	tempDir := t.TempDir()
+
+	formats := []struct {
+		name    string
+		convert func(c *nextgenimage.Converter, in, out string) (*nextgenimage.ConversionResult, error)
+	}{
+		{"webp", (*nextgenimage.Converter).ToWebP},
+		{"avif", (*nextgenimage.Converter).ToAVIF},
+	}
+
+	for _, format := range formats {
+		t.Run(format.name, func(t *testing.T) {
+			outputPath := filepath.Join(tempDir, "test."+format.name)
+			if _, err := format.convert(nextgenimage.NewConverter(nextgenimage.ConverterConfig{}), inputPath, outputPath); err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+
+			result, err := metrics.CompareFiles(inputPath, outputPath)
+			if err != nil {
+				t.Fatalf("CompareFiles failed: %v", err)
+			}
+			t.Logf("PSNR %.2f dB, SSIM %.4f, MS-SSIM %.4f", result.PSNR, result.SSIM, result.MSSSIM)
+
+			if result.PSNR < 25 || math.IsInf(result.PSNR, 1) {
+				t.Errorf("Unexpected PSNR %f for a lossy conversion", result.PSNR)
+			}
+			if result.SSIM < 0.8 || result.SSIM >= 1 || result.MSSSIM < 0.8 || result.MSSSIM >= 1 {
+				t.Errorf("Unexpected similarity for a default quality conversion: %+v", result)
+			}
+
+			heatmapPath := filepath.Join(tempDir, format.name+"_heatmap.png")
+			if err := metrics.HeatmapFiles(inputPath, outputPath, heatmapPath); err != nil {
+				t.Fatalf("HeatmapFiles failed: %v", err)
+			}
+			if info, err := os.Stat(heatmapPath); err != nil || info.Size() == 0 {
+				t.Errorf("Heatmap was not written: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import "math"
+
+// SSIM window layout and stabilizing constants from Wang et al. for 8-bit samples
+const (
+	ssimWindow = 8
+	ssimStride = 4
+	ssimC1     = (0.01 * 255) * (0.01 * 255)
+	ssimC2     = (0.03 * 255) * (0.03 * 255)
+)
+
+// msssimWeights are the per-scale exponents from Wang et al., finest scale first
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// SSIM returns the mean structural similarity of the luma planes of distorted against reference.
+// Identical images score 1.
+func SSIM(reference, distorted *Image) (float64, error) {
+	if err := checkSize(reference, distorted); err != nil {
+		return 0, err
+	}
+	return SSIMPlane(reference.Luma(), distorted.Luma(), reference.Width, reference.Height), nil
+}
+
+// MSSSIM returns the multi-scale structural similarity of the luma planes of distorted against
+// reference. Identical images score 1.
+func MSSSIM(reference, distorted *Image) (float64, error) {
+	if err := checkSize(reference, distorted); err != nil {
+		return 0, err
+	}
+	return MSSSIMPlane(reference.Luma(), distorted.Luma(), reference.Width, reference.Height), nil
+}
+
+// SSIMPlane returns the mean structural similarity of two 8-bit planes of the same size,
+// computed over 8x8 windows placed every 4 pixels. Identical planes score 1.
+func SSIMPlane(a, b []byte, width, height int) float64 {
+	s, _ := ssimComponents(toFloat(a), toFloat(b), width, height)
+	return s
+}
+
+// MSSSIMPlane returns the multi-scale structural similarity of two 8-bit planes of the same size.
+// Planes are halved up to four times, stopping early once a scale would be smaller than a window,
+// and the weights of the scales used are renormalized.
+func MSSSIMPlane(a, b []byte, width, height int) float64 {
+	pa, pb := toFloat(a), toFloat(b)
+
+	scales := 1
+	for w, h := width, height; scales < len(msssimWeights) && w/2 >= ssimWindow && h/2 >= ssimWindow; scales++ {
+		w, h = w/2, h/2
+	}
+
+	var weightSum float64
+	for _, weight := range msssimWeights[:scales] {
+		weightSum += weight
+	}
+
+	result := 1.0
+	for scale := 0; scale < scales; scale++ {
+		s, cs := ssimComponents(pa, pb, width, height)
+		value := cs
+		if scale == scales-1 {
+			// Luminance only counts at the coarsest scale
+			value = s
+		}
+		// Negative similarities have no meaningful fractional power
+		result *= math.Pow(max(value, 0), msssimWeights[scale]/weightSum)
+
+		if scale < scales-1 {
+			pa, pb = downsample(pa, width, height), downsample(pb, width, height)
+			width, height = width/2, height/2
+		}
+	}
+
+	return result
+}
+
+// ssimComponents returns the mean SSIM and the mean contrast-structure term of two planes
+func ssimComponents(a, b []float64, width, height int) (float64, float64) {
+	windowWidth, windowHeight := min(ssimWindow, width), min(ssimWindow, height)
+
+	var total, totalCS float64
+	windows := 0
+	for y := 0; y+windowHeight <= height; y += ssimStride {
+		for x := 0; x+windowWidth <= width; x += ssimStride {
+			var sumA, sumB, sumAA, sumBB, sumAB float64
+			for wy := y; wy < y+windowHeight; wy++ {
+				row := wy * width
+				for wx := x; wx < x+windowWidth; wx++ {
+					pa, pb := a[row+wx], b[row+wx]
+					sumA += pa
+					sumB += pb
+					sumAA += pa * pa
+					sumBB += pb * pb
+					sumAB += pa * pb
+				}
+			}
+
+			n := float64(windowWidth * windowHeight)
+			meanA, meanB := sumA/n, sumB/n
+			varA := sumAA/n - meanA*meanA
+			varB := sumBB/n - meanB*meanB
+			covariance := sumAB/n - meanA*meanB
+
+			cs := (2*covariance + ssimC2) / (varA + varB + ssimC2)
+			total += (2*meanA*meanB + ssimC1) / (meanA*meanA + meanB*meanB + ssimC1) * cs
+			totalCS += cs
+			windows++
+		}
+	}
+
+	if windows == 0 {
+		return 1, 1
+	}
+	return total / float64(windows), totalCS / float64(windows)
+}
+
+// downsample halves a plane by averaging 2x2 blocks, dropping an odd last row or column
+func downsample(p []float64, width, height int) []float64 {
+	w, h := width/2, height/2
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := 2*y*width + 2*x
+			out[y*w+x] = (p[i] + p[i+1] + p[i+width] + p[i+width+1]) / 4
+		}
+	}
+	return out
+}
+
+// toFloat widens 8-bit samples for averaging across scales
+func toFloat(p []byte) []float64 {
+	out := make([]float64, len(p))
+	for i, v := range p {
+		out[i] = float64(v)
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package nextgenimage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// imageTypeInfo holds the media types and file extensions of an image type
+type imageTypeInfo struct {
+	imgType    ImageType
+	mimeTypes  []string // Canonical type first, then aliases seen in the wild
+	extensions []string // Canonical extension first, lowercase with the dot
+}
+
+// imageTypeInfos lists the known image types. Lookups by extension or media type take the
+// first match, so PNG comes before APNG, which shares the .png extension.
+var imageTypeInfos = []imageTypeInfo{
+	{ImageTypeJPEG, []string{"image/jpeg", "image/jpg", "image/pjpeg"}, []string{".jpg", ".jpeg", ".jpe", ".jfif"}},
+	{ImageTypePNG, []string{"image/png", "image/x-png"}, []string{".png"}},
+	{ImageTypeAPNG, []string{"image/apng", "image/vnd.mozilla.apng"}, []string{".apng", ".png"}},
+	{ImageTypeGIF, []string{"image/gif"}, []string{".gif"}},
+	{ImageTypeWebP, []string{"image/webp"}, []string{".webp"}},
+	{ImageTypeAVIF, []string{"image/avif", "image/avif-sequence"}, []string{".avif", ".avifs"}},
+	{ImageTypeHEIC, []string{"image/heic", "image/heic-sequence"}, []string{".heic", ".heics"}},
+	{ImageTypeHEIF, []string{"image/heif", "image/heif-sequence"}, []string{".heif", ".heifs", ".hif"}},
+	{ImageTypeJXL, []string{"image/jxl"}, []string{".jxl"}},
+	{ImageTypeTIFF, []string{"image/tiff", "image/tiff-fx"}, []string{".tiff", ".tif"}},
+	{ImageTypeBMP, []string{"image/bmp", "image/x-bmp", "image/x-ms-bmp"}, []string{".bmp", ".dib"}},
+	{ImageTypeICO, []string{"image/x-icon", "image/vnd.microsoft.icon"}, []string{".ico"}},
+	{ImageTypeSVG, []string{"image/svg+xml"}, []string{".svg"}},
+}
+
+// mimeTypeUnknown is served for data of an unknown type
+const mimeTypeUnknown = "application/octet-stream"
+
+// lookup returns the media types and extensions of the image type
+func (t ImageType) lookup() (imageTypeInfo, bool) {
+	for _, info := range imageTypeInfos {
+		if info.imgType == t {
+			return info, true
+		}
+	}
+	return imageTypeInfo{}, false
+}
+
+// MIMEType returns the media type of the image type, or application/octet-stream when unknown
+func (t ImageType) MIMEType() string {
+	if info, ok := t.lookup(); ok {
+		return info.mimeTypes[0]
+	}
+	return mimeTypeUnknown
+}
+
+// Extensions returns the file extensions of the image type with the leading dot, the
+// canonical one first. It returns nil for an unknown type.
+func (t ImageType) Extensions() []string {
+	if info, ok := t.lookup(); ok {
+		return append([]string(nil), info.extensions...)
+	}
+	return nil
+}
+
+// ImageTypeFromExtension returns the image type of a file extension such as ".jpg" or "JPG",
+// or ImageTypeUnknown. A ".png" file is reported as PNG, detection tells APNGs apart.
+func ImageTypeFromExtension(ext string) ImageType {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	for _, info := range imageTypeInfos {
+		for _, e := range info.extensions {
+			if e == ext {
+				return info.imgType
+			}
+		}
+	}
+	return ImageTypeUnknown
+}
+
+// ImageTypeFromMIME returns the image type of a media type such as a Content-Type header value,
+// or ImageTypeUnknown. Parameters and case are ignored.
+func ImageTypeFromMIME(mimeType string) ImageType {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	for _, info := range imageTypeInfos {
+		for _, m := range info.mimeTypes {
+			if m == mimeType {
+				return info.imgType
+			}
+		}
+	}
+	return ImageTypeUnknown
+}
+
+// NegotiateImageType picks the image type to serve for an HTTP Accept header among offers,
+// which default to AVIF then WebP. The offer with the highest quality value wins, and the
+// order of offers breaks ties. Wildcards are ignored because browsers send them whether or
+// not they decode next-gen formats. It returns ImageTypeUnknown when no offer is accepted,
+// in which case the original image should be served.
+func NegotiateImageType(accept string, offers ...ImageType) ImageType {
+	if len(offers) == 0 {
+		offers = []ImageType{ImageTypeAVIF, ImageTypeWebP}
+	}
+
+	// Quality value of each explicitly accepted media type
+	qualities := map[ImageType]float64{}
+	for _, entry := range strings.Split(accept, ",") {
+		params := strings.Split(entry, ";")
+		imgType := ImageTypeFromMIME(params[0])
+		if imgType == ImageTypeUnknown {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || parsed < 0 || parsed > 1 {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+		// A repeated media type keeps its first quality value
+		if _, seen := qualities[imgType]; !seen {
+			qualities[imgType] = q
+		}
+	}
+
+	best, bestQ := ImageTypeUnknown, 0.0
+	for _, offer := range offers {
+		if q := qualities[offer]; q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,131 @@
+package nextgenimage
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestImageTypeMIMEType(t *testing.T) {
+	tests := []struct {
+		imageType ImageType
+		expected  string
+	}{
+		{ImageTypeJPEG, "image/jpeg"},
+		{ImageTypePNG, "image/png"},
+		{ImageTypeAPNG, "image/apng"},
+		{ImageTypeWebP, "image/webp"},
+		{ImageTypeAVIF, "image/avif"},
+		{ImageTypeJXL, "image/jxl"},
+		{ImageTypeICO, "image/x-icon"},
+		{ImageTypeSVG, "image/svg+xml"},
+		{ImageTypeUnknown, "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.imageType.MIMEType(); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.imageType, tt.expected, got)
+		}
+	}
+}
+
+func TestImageTypeExtensions(t *testing.T) {
+	if got := ImageTypeJPEG.Extensions(); !slices.Equal(got, []string{".jpg", ".jpeg", ".jpe", ".jfif"}) {
+		t.Errorf("Unexpected JPEG extensions: %v", got)
+	}
+	if got := ImageTypeUnknown.Extensions(); got != nil {
+		t.Errorf("Expected no extensions for an unknown type, got %v", got)
+	}
+
+	// Every type maps back from its extensions and media types, except APNG sharing .png
+	for _, info := range imageTypeInfos {
+		ext := info.imgType.Extensions()
+		if len(ext) == 0 {
+			t.Errorf("%s has no extensions", info.imgType)
+			continue
+		}
+		if got := ImageTypeFromExtension(ext[0]); got != info.imgType {
+			t.Errorf("ImageTypeFromExtension(%q) = %s, expected %s", ext[0], got, info.imgType)
+		}
+		if got := ImageTypeFromMIME(info.imgType.MIMEType()); got != info.imgType {
+			t.Errorf("ImageTypeFromMIME(%q) = %s, expected %s", info.imgType.MIMEType(), got, info.imgType)
+		}
+	}
+
+	// The returned slice is a copy
+	ImageTypePNG.Extensions()[0] = ".bad"
+	if ImageTypePNG.Extensions()[0] != ".png" {
+		t.Error("Extensions exposed the internal table")
+	}
+}
+
+func TestImageTypeFromExtension(t *testing.T) {
+	tests := []struct {
+		ext      string
+		expected ImageType
+	}{
+		{".jpg", ImageTypeJPEG},
+		{"JPEG", ImageTypeJPEG},
+		{".PNG", ImageTypePNG},
+		{".apng", ImageTypeAPNG},
+		{"tif", ImageTypeTIFF},
+		{".heic", ImageTypeHEIC},
+		{".txt", ImageTypeUnknown},
+		{"", ImageTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ImageTypeFromExtension(tt.ext); got != tt.expected {
+			t.Errorf("ImageTypeFromExtension(%q) = %s, expected %s", tt.ext, got, tt.expected)
+		}
+	}
+}
+
+func TestImageTypeFromMIME(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		expected ImageType
+	}{
+		{"image/jpeg", ImageTypeJPEG},
+		{"image/jpg", ImageTypeJPEG},
+		{"IMAGE/WEBP", ImageTypeWebP},
+		{"image/svg+xml; charset=utf-8", ImageTypeSVG},
+		{"image/vnd.microsoft.icon", ImageTypeICO},
+		{"image/*", ImageTypeUnknown},
+		{"text/html", ImageTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ImageTypeFromMIME(tt.mimeType); got != tt.expected {
+			t.Errorf("ImageTypeFromMIME(%q) = %s, expected %s", tt.mimeType, got, tt.expected)
+		}
+	}
+}
+
+func TestNegotiateImageType(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		offers   []ImageType
+		expected ImageType
+	}{
+		{"Chrome", "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", nil, ImageTypeAVIF},
+		{"Safari 14", "image/webp,image/png,image/svg+xml,image/*;q=0.8,video/*;q=0.8,*/*;q=0.5", nil, ImageTypeWebP},
+		{"Wildcards only", "image/*,*/*;q=0.8", nil, ImageTypeUnknown},
+		{"Empty", "", nil, ImageTypeUnknown},
+		{"Higher quality wins", "image/avif;q=0.5, image/webp;q=0.9", nil, ImageTypeWebP},
+		{"Offer order breaks ties", "image/webp, image/avif", nil, ImageTypeAVIF},
+		{"Rejected", "image/avif;q=0, image/webp;q=0", nil, ImageTypeUnknown},
+		{"Invalid quality", "image/avif;q=abc, image/webp", nil, ImageTypeWebP},
+		{"Custom offers", "image/avif,image/webp", []ImageType{ImageTypeWebP}, ImageTypeWebP},
+		{"Custom offer preference", "image/avif,image/webp", []ImageType{ImageTypeWebP, ImageTypeAVIF}, ImageTypeWebP},
+		{"Case and spacing", " Image/AVIF ; Q=0.7 ", nil, ImageTypeAVIF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateImageType(tt.accept, tt.offers...); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package nextgenimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// pngEncoding is one candidate encoding of a PNG
+type pngEncoding struct {
+	buf     []byte
+	mode    EncoderMode
+	quality int          // Lossy quality, AVIF CQ or near-lossless level, 0 for lossless
+	ssim    float64      // SSIM reached in SSIM target mode, 0 otherwise
+	class   ContentClass // Content class in automatic mode, empty otherwise
+}
+
+// smallestPNGEncoding returns the smallest candidate, the earliest one on ties
+func smallestPNGEncoding(candidates []*pngEncoding) *pngEncoding {
+	var best *pngEncoding
+	for _, candidate := range candidates {
+		if best == nil || len(candidate.buf) < len(best.buf) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// encodePNGLossy encodes a copy of image lossily with its alpha quantized to alphaLevels,
+// searching the quality in SSIM or target size mode like JPEG input
+func (c *Converter) encodePNGLossy(ctx context.Context, image *vips.ImageRef, quality, alphaLevels, maxQuality int, export func(lossy *vips.ImageRef, quality int) ([]byte, error)) (*pngEncoding, error) {
+	lossy, err := image.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy image: %w", NewFormatError(err))
+	}
+	defer lossy.Close()
+
+	if err := quantizeAlpha(lossy, alphaLevels); err != nil {
+		return nil, fmt.Errorf("failed to quantize alpha: %w", NewFormatError(err))
+	}
+
+	buf, quality, score, err := c.encodeLossy(ctx, lossy, quality, 0, maxQuality, func(quality int) ([]byte, error) {
+		return export(lossy, quality)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pngEncoding{buf: buf, mode: EncoderModeLossy, quality: quality, ssim: score}, nil
+}
+
+// addLossyCandidate appends a lossy encoding to the candidates. In try-all mode a lossy encode
+// that cannot meet the SSIM or size target is dropped, since the other candidates still can.
+func addLossyCandidate(candidates []*pngEncoding, lossy *pngEncoding, err error, tryAll bool) ([]*pngEncoding, error) {
+	var formatErr *FormatError
+	switch {
+	case err == nil:
+		return append(candidates, lossy), nil
+	case tryAll && errors.As(err, &formatErr) && !errors.Is(err, ErrEncodeFailed):
+		return candidates, nil
+	default:
+		return nil, err
+	}
+}
+
+// pngToWebP encodes a PNG losslessly, near-losslessly and lossily as configured and returns
+// the smallest encoding that meets the SSIM target
+func (c *Converter) pngToWebP(ctx context.Context, image *vips.ImageRef, params *webpExportParams) (*pngEncoding, error) {
+	config := c.config.PNGToWebP
+	var class ContentClass
+	if config.Auto {
+		// Photos go lossy, graphics try near-lossless for their gradients, screenshots stay exact
+		var err error
+		if class, err = c.classifyPNG(image); err != nil {
+			return nil, err
+		}
+		config.Lossy = class == ContentClassPhoto
+		config.TryNearLossless = class == ContentClassGraphic
+		config.TryAll = false
+	}
+	var candidates []*pngEncoding
+
+	if !config.Lossy || config.TryAll {
+		lossless := *params
+		lossless.Lossless = true
+		buf, err := lossless.export(image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export webp: %w", newKindError(ErrEncodeFailed, err))
+		}
+		candidates = append(candidates, &pngEncoding{buf: buf, mode: EncoderModeLossless})
+	}
+
+	if (config.TryNearLossless && !config.Lossy) || config.TryAll {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if config.NearLosslessLevel < 1 || config.NearLosslessLevel > 100 {
+			return nil, fmt.Errorf("webp near-lossless level %d is out of range 1-100", config.NearLosslessLevel)
+		}
+
+		// libvips takes the near-lossless level from the quality
+		nearLossless := *params
+		nearLossless.Lossless = false
+		nearLossless.NearLossless = true
+		nearLossless.Quality = config.NearLosslessLevel
+
+		// Near-lossless is only ever an improvement, so a failed attempt keeps the lossless result
+		buf, err := nearLossless.export(image)
+		if err == nil {
+			candidate := &pngEncoding{buf: buf, mode: EncoderModeNearLossless, quality: nearLossless.Quality}
+			reached, err := c.reachesTargetSSIM(image, candidate)
+			if err != nil {
+				return nil, err
+			}
+			if reached {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	if config.Lossy || config.TryAll {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// libwebp applies the alpha quality itself
+		lossy, err := c.encodePNGLossy(ctx, image, config.Quality, 0, webpMaxQuality, func(lossy *vips.ImageRef, quality int) ([]byte, error) {
+			lossyParams := *params
+			lossyParams.Lossless = false
+			lossyParams.Quality = quality
+			lossyParams.alphaQuality = config.AlphaQuality
+			buf, err := lossyParams.export(lossy)
+			if err != nil {
+				return nil, fmt.Errorf("failed to export webp: %w", newKindError(ErrEncodeFailed, err))
+			}
+			return buf, nil
+		})
+		candidates, err = addLossyCandidate(candidates, lossy, err, config.TryAll)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	best := smallestPNGEncoding(candidates)
+	best.class = class
+	return best, nil
+}
+
+// pngToAVIF encodes a PNG losslessly and lossily as configured and returns the smallest encoding
+func (c *Converter) pngToAVIF(ctx context.Context, image *vips.ImageRef, params *avifExportParams) (*pngEncoding, error) {
+	config := c.config.PNGToAVIF
+	var class ContentClass
+	if config.Auto {
+		// Photos go lossy, graphics and screenshots stay lossless
+		var err error
+		if class, err = c.classifyPNG(image); err != nil {
+			return nil, err
+		}
+		config.Lossy = class == ContentClassPhoto
+		config.TryAll = false
+	}
+	var candidates []*pngEncoding
+
+	if !config.Lossy || config.TryAll {
+		lossless := *params
+		lossless.Lossless = true
+		buf, err := exportAVIF(image, &lossless)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, &pngEncoding{buf: buf, mode: EncoderModeLossless})
+	}
+
+	if config.Lossy || config.TryAll {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := checkAlphaLevels(config.AlphaLevels); err != nil {
+			return nil, err
+		}
+		lossy, err := c.encodePNGLossy(ctx, image, config.CQ, config.AlphaLevels, avifMaxQuality, func(lossy *vips.ImageRef, cq int) ([]byte, error) {
+			lossyParams := *params
+			lossyParams.Lossless = false
+			lossyParams.Quality = cq
+			return exportAVIF(lossy, &lossyParams)
+		})
+		candidates, err = addLossyCandidate(candidates, lossy, err, config.TryAll)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	best := smallestPNGEncoding(candidates)
+	best.class = class
+	return best, nil
+}
+
+// classifyPNG labels the content of a PNG for the automatic strategy
+func (c *Converter) classifyPNG(image *vips.ImageRef) (ContentClass, error) {
+	class, err := classifyContent(image)
+	if err != nil {
+		return "", fmt.Errorf("failed to classify content: %w", NewFormatError(err))
+	}
+	return class, nil
+}
+
+// reachesTargetSSIM scores a candidate against the source in SSIM target mode, always
+// accepting it otherwise
+func (c *Converter) reachesTargetSSIM(image *vips.ImageRef, candidate *pngEncoding) (bool, error) {
+	if c.config.TargetSSIM.Score <= 0 {
+		return true, nil
+	}
+
+	reference, err := newSSIMReference(image)
+	if err != nil {
+		return false, fmt.Errorf("failed to read source pixels: %w", NewFormatError(err))
+	}
+	score, err := reference.score(candidate.buf)
+	if err != nil {
+		return false, fmt.Errorf("failed to compute ssim: %w", NewFormatError(err))
+	}
+	candidate.ssim = score
+	return score >= c.config.TargetSSIM.Score, nil
+}
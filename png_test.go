@@ -0,0 +1,126 @@
+package nextgenimage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSmallestPNGEncoding(t *testing.T) {
+	lossless := &pngEncoding{buf: make([]byte, 100), mode: EncoderModeLossless}
+	nearLossless := &pngEncoding{buf: make([]byte, 100), mode: EncoderModeNearLossless}
+	lossy := &pngEncoding{buf: make([]byte, 60), mode: EncoderModeLossy}
+
+	if best := smallestPNGEncoding([]*pngEncoding{lossless, nearLossless, lossy}); best != lossy {
+		t.Errorf("Expected the lossy encoding, got %s", best.mode)
+	}
+	// Ties keep the earlier, more faithful encoding
+	if best := smallestPNGEncoding([]*pngEncoding{lossless, nearLossless}); best != lossless {
+		t.Errorf("Expected the lossless encoding on a tie, got %s", best.mode)
+	}
+}
+
+func TestAddLossyCandidate(t *testing.T) {
+	lossless := []*pngEncoding{{buf: make([]byte, 100), mode: EncoderModeLossless}}
+	unreachable := NewFormatError(fmt.Errorf("output does not reach SSIM 0.9900"))
+
+	// Try-all drops a lossy encode that misses the targets
+	candidates, err := addLossyCandidate(lossless, nil, unreachable, true)
+	if err != nil || len(candidates) != 1 {
+		t.Errorf("Expected the lossy candidate to be dropped, got %d candidates and %v", len(candidates), err)
+	}
+
+	// Lossy only mode reports it
+	if _, err := addLossyCandidate(nil, nil, unreachable, false); !errors.As(err, new(*FormatError)) {
+		t.Errorf("Expected the FormatError in lossy mode, got %v", err)
+	}
+
+	// Encoder failures are never hidden
+	failed := newKindError(ErrEncodeFailed, errors.New("vips error"))
+	if _, err := addLossyCandidate(lossless, nil, failed, true); !errors.Is(err, ErrEncodeFailed) {
+		t.Errorf("Expected ErrEncodeFailed, got %v", err)
+	}
+}
+
+func TestPNGLossy(t *testing.T) {
+	inputPaths := []string{"testdata/test_original.png", "testdata/png/alpha_semitransparent.png"}
+	for _, path := range inputPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Skip("Test file not found:", path)
+			return
+		}
+	}
+	tempDir := t.TempDir()
+
+	formats := []struct {
+		name      string
+		configure func(config *ConverterConfig, lossy, tryAll bool)
+		convert   func(c *Converter, in, out string) (*ConversionResult, error)
+	}{
+		{
+			name: "WebP",
+			configure: func(config *ConverterConfig, lossy, tryAll bool) {
+				config.PNGToWebP.Lossy = lossy
+				config.PNGToWebP.TryAll = tryAll
+				config.PNGToWebP.AlphaQuality = 50
+			},
+			convert: (*Converter).ToWebP,
+		},
+		{
+			name: "AVIF",
+			configure: func(config *ConverterConfig, lossy, tryAll bool) {
+				config.PNGToAVIF.Lossy = lossy
+				config.PNGToAVIF.TryAll = tryAll
+				config.PNGToAVIF.AlphaLevels = 16
+			},
+			convert: (*Converter).ToAVIF,
+		},
+	}
+
+	for _, format := range formats {
+		for _, inputPath := range inputPaths {
+			t.Run(format.name+"/"+filepath.Base(inputPath), func(t *testing.T) {
+				outputPath := filepath.Join(tempDir, "test."+format.name)
+				convert := func(lossy, tryAll bool) *ConversionResult {
+					t.Helper()
+					config := ConverterConfig{}
+					format.configure(&config, lossy, tryAll)
+					result, err := format.convert(NewConverter(config), inputPath, outputPath)
+					var formatErr *FormatError
+					if errors.As(err, &formatErr) {
+						t.Logf("Skipping a mode that is not smaller than the PNG: %v", err)
+						return nil
+					}
+					if err != nil {
+						t.Fatalf("Conversion failed: %v", err)
+					}
+					return result
+				}
+
+				lossy := convert(true, false)
+				if lossy != nil && (lossy.Mode != EncoderModeLossy || lossy.Quality == 0) {
+					t.Errorf("Expected a lossy encode with a quality, got %s at %d", lossy.Mode, lossy.Quality)
+				}
+
+				lossless := convert(false, false)
+				if lossless != nil && lossless.Mode != EncoderModeLossless {
+					t.Errorf("Expected lossless by default, got %s", lossless.Mode)
+				}
+
+				// Trying all modes is never larger than either single mode
+				tryAll := convert(false, true)
+				if tryAll == nil {
+					return
+				}
+				for _, single := range []*ConversionResult{lossy, lossless} {
+					if single != nil && tryAll.OutputSize > single.OutputSize {
+						t.Errorf("Try all (%d bytes, %s) is larger than %s (%d bytes)", tryAll.OutputSize, tryAll.Mode, single.Mode, single.OutputSize)
+					}
+				}
+				t.Logf("Try all picked %s at %d bytes", tryAll.Mode, tryAll.OutputSize)
+			})
+		}
+	}
+}
@@ -32,7 +32,7 @@ func TestPNGToAVIF(t *testing.T) {
 			}
 
 			// Convert
-			err = converter.ToAVIF(inputPath, outputPath)
+			_, err = converter.ToAVIF(inputPath, outputPath)
 
 			// Check if it's a format error (expected for some test cases)
 			var formatErr *FormatError
@@ -127,7 +127,7 @@ func TestPNGToAVIFAlphaHandling(t *testing.T) {
 				return
 			}
 
-			err := converter.ToAVIF(inputPath, outputPath)
+			_, err := converter.ToAVIF(inputPath, outputPath)
 
 			// Check if it's a format error
 			var formatErr *FormatError
@@ -156,21 +156,3 @@ func TestPNGToAVIFAlphaHandling(t *testing.T) {
 		})
 	}
 }
-
-func TestGIFToAVIFNotSupported(t *testing.T) {
-	converter := NewConverter(ConverterConfig{})
-	tempDir := t.TempDir()
-
-	inputPath := "testdata/test_original.gif"
-	outputPath := filepath.Join(tempDir, "test.avif")
-
-	err := converter.ToAVIF(inputPath, outputPath)
-
-	// Should return a format error
-	var formatErr *FormatError
-	if !errors.As(err, &formatErr) {
-		t.Errorf("Expected FormatError for GIF to AVIF conversion, got: %v", err)
-	}
-
-	t.Logf("Expected format error: %v", err)
-}
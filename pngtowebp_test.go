@@ -32,7 +32,7 @@ func TestPNGToWebP(t *testing.T) {
 			}
 
 			// Convert
-			err = converter.ToWebP(inputPath, outputPath)
+			_, err = converter.ToWebP(inputPath, outputPath)
 
 			// Check if it's a format error (expected for some test cases)
 			var formatErr *FormatError
@@ -123,7 +123,7 @@ func TestPNGToWebPNearLossless(t *testing.T) {
 	inputPath := "testdata/test_original.png"
 	outputPath := filepath.Join(tempDir, "near_lossless.webp")
 
-	err := converter.ToWebP(inputPath, outputPath)
+	_, err := converter.ToWebP(inputPath, outputPath)
 	if err != nil {
 		t.Fatalf("Conversion failed: %v", err)
 	}
@@ -142,7 +142,7 @@ func TestPNGToWebPNearLossless(t *testing.T) {
 	converter2 := NewConverter(config2)
 
 	outputPath2 := filepath.Join(tempDir, "lossless.webp")
-	err = converter2.ToWebP(inputPath, outputPath2)
+	_, err = converter2.ToWebP(inputPath, outputPath2)
 	if err != nil {
 		t.Fatalf("Lossless conversion failed: %v", err)
 	}
@@ -178,7 +178,7 @@ func TestPNGAlphaHandling(t *testing.T) {
 				return
 			}
 
-			err := converter.ToWebP(inputPath, outputPath)
+			_, err := converter.ToWebP(inputPath, outputPath)
 
 			// Check if it's a format error
 			var formatErr *FormatError
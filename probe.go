@@ -0,0 +1,479 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ColorType describes how an image stores color
+type ColorType string
+
+const (
+	ColorTypeGray    ColorType = "gray"
+	ColorTypeRGB     ColorType = "rgb"
+	ColorTypePalette ColorType = "palette"
+	ColorTypeCMYK    ColorType = "cmyk"
+)
+
+// ImageInfo describes an image as read from its headers
+type ImageInfo struct {
+	Type        ImageType // Detected format
+	Width       int       // Width in pixels as stored, before orientation is applied
+	Height      int       // Height in pixels as stored, before orientation is applied
+	BitDepth    int       // Bits per sample, or per palette index for palette images
+	ColorType   ColorType // Color model of the stored samples
+	HasAlpha    bool      // An alpha channel or transparent color is present
+	Interlaced  bool      // Adam7 PNG, interlaced GIF or progressive JPEG
+	Frames      int       // Number of frames, 1 for still images
+	LoopCount   int       // Times an animation plays, 0 meaning forever; 1 for still images
+	Orientation int       // EXIF orientation (1-8), 1 when absent
+}
+
+// PNG chunk layout
+const (
+	pngChunkHeader = 8 // Length, type
+	pngChunkCRC    = 4
+	pngIHDRSize    = 13
+	pngACTLSize    = 8
+)
+
+// PNG color types from the IHDR chunk
+const (
+	pngColorGray      = 0
+	pngColorRGB       = 2
+	pngColorPalette   = 3
+	pngColorGrayAlpha = 4
+	pngColorRGBA      = 6
+)
+
+// WebP VP8X feature flags
+const (
+	webpFlagAnimation = 0x02
+	webpFlagAlpha     = 0x10
+)
+
+// WebP bitstream headers
+const (
+	webpVP8XSize       = 10
+	webpVP8HeaderSize  = 10 // Frame tag(3), start code(3), width(2), height(2)
+	webpVP8LHeaderSize = 5  // Signature(1), packed sizes and flags(4)
+	webpVP8LSignature  = 0x2F
+)
+
+// webpVP8StartCode follows the frame tag of a lossy keyframe
+var webpVP8StartCode = []byte{0x9D, 0x01, 0x2A}
+
+// EXIF layout
+const (
+	exifTagOrientation = 0x0112
+	exifTypeShort      = 3
+	exifEntrySize      = 12
+)
+
+// exifHeader prefixes EXIF data in JPEG APP1 segments and some WebP EXIF chunks
+var exifHeader = []byte("Exif\x00\x00")
+
+// ProbeImage reads the dimensions, color layout and animation details of an image file
+// from its headers, without decoding any pixels
+func ProbeImage(path string) (*ImageInfo, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return probeImage(buf)
+}
+
+// ProbeImageFromReader reads the dimensions, color layout and animation details of an
+// image from an io.Reader, without decoding any pixels
+func ProbeImageFromReader(r io.Reader) (*ImageInfo, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	return probeImage(buf)
+}
+
+func probeImage(buf []byte) (*ImageInfo, error) {
+	info := &ImageInfo{
+		Type:        DetectImageTypeFromBytes(buf),
+		Frames:      1,
+		LoopCount:   1,
+		Orientation: 1,
+	}
+
+	var err error
+	switch info.Type {
+	case ImageTypeJPEG:
+		err = probeJPEG(buf, info)
+	case ImageTypePNG, ImageTypeAPNG:
+		err = probePNG(buf, info)
+	case ImageTypeGIF:
+		err = probeGIF(buf, info)
+	case ImageTypeWebP:
+		err = probeWebP(buf, info)
+	case ImageTypeAVIF:
+		err = probeAVIF(buf, info)
+	default:
+		return nil, newKindError(ErrUnsupportedFormat, fmt.Errorf("unsupported image format: %s", info.Type))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %s: %w", info.Type, newKindError(ErrDecodeFailed, err))
+	}
+
+	return info, nil
+}
+
+// probeJPEG reads the frame header and the EXIF orientation of a JPEG
+func probeJPEG(buf []byte, info *ImageInfo) error {
+	segments, err := readJPEGSegments(buf)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, seg := range segments {
+		switch {
+		case isJPEGSOF(seg.marker) && !found:
+			// precision(1) height(2) width(2) components(1)
+			if len(seg.data) < 6 {
+				return fmt.Errorf("truncated JPEG frame header")
+			}
+			info.BitDepth = int(seg.data[0])
+			info.Height = int(binary.BigEndian.Uint16(seg.data[1:3]))
+			info.Width = int(binary.BigEndian.Uint16(seg.data[3:5]))
+			switch seg.data[5] {
+			case 1:
+				info.ColorType = ColorTypeGray
+			case 4:
+				info.ColorType = ColorTypeCMYK
+			default:
+				info.ColorType = ColorTypeRGB
+			}
+			// SOF2, SOF6, SOF10 and SOF14 are progressive
+			info.Interlaced = seg.marker&0x03 == 0x02
+			found = true
+		case seg.marker == jpegMarkerAPP1 && bytes.HasPrefix(seg.data, exifHeader):
+			if orientation := readEXIFOrientation(seg.data); orientation != 0 {
+				info.Orientation = orientation
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no JPEG frame header found")
+	}
+	return nil
+}
+
+// probePNG reads the IHDR chunk and the chunks adding transparency, animation and EXIF data
+func probePNG(buf []byte, info *ImageInfo) error {
+	pos := len(pngMagic)
+	sawIHDR := false
+	for pos+pngChunkHeader <= len(buf) {
+		chunkType := string(buf[pos+4 : pos+8])
+		start := pos + pngChunkHeader
+		if uint64(binary.BigEndian.Uint32(buf[pos:])) > uint64(len(buf)-start) {
+			return fmt.Errorf("truncated PNG chunk %q at offset %d", chunkType, pos)
+		}
+		size := int(binary.BigEndian.Uint32(buf[pos:]))
+		data := buf[start : start+size]
+		pos = start + size + pngChunkCRC
+
+		if !sawIHDR && chunkType != "IHDR" {
+			return fmt.Errorf("PNG does not start with an IHDR chunk")
+		}
+
+		switch chunkType {
+		case "IHDR":
+			// width(4) height(4) bit depth(1) color type(1) compression(1) filter(1) interlace(1)
+			if size < pngIHDRSize {
+				return fmt.Errorf("truncated PNG IHDR chunk")
+			}
+			info.Width = int(binary.BigEndian.Uint32(data[0:4]))
+			info.Height = int(binary.BigEndian.Uint32(data[4:8]))
+			info.BitDepth = int(data[8])
+			switch data[9] {
+			case pngColorGray:
+				info.ColorType = ColorTypeGray
+			case pngColorGrayAlpha:
+				info.ColorType = ColorTypeGray
+				info.HasAlpha = true
+			case pngColorRGB:
+				info.ColorType = ColorTypeRGB
+			case pngColorRGBA:
+				info.ColorType = ColorTypeRGB
+				info.HasAlpha = true
+			case pngColorPalette:
+				info.ColorType = ColorTypePalette
+			default:
+				return fmt.Errorf("invalid PNG color type %d", data[9])
+			}
+			info.Interlaced = data[12] == 1
+			sawIHDR = true
+		case "tRNS":
+			info.HasAlpha = true
+		case "acTL":
+			// num_frames(4) num_plays(4), 0 plays meaning forever
+			if size < pngACTLSize {
+				return fmt.Errorf("truncated PNG acTL chunk")
+			}
+			info.Frames = int(binary.BigEndian.Uint32(data[0:4]))
+			info.LoopCount = int(binary.BigEndian.Uint32(data[4:8]))
+		case "eXIf":
+			if orientation := readEXIFOrientation(data); orientation != 0 {
+				info.Orientation = orientation
+			}
+		case "IEND":
+			return nil
+		}
+	}
+
+	if !sawIHDR {
+		return fmt.Errorf("missing PNG IHDR chunk")
+	}
+	return nil
+}
+
+// probeGIF reads the logical screen and the frame structure of a GIF
+func probeGIF(buf []byte, info *ImageInfo) error {
+	anim, err := parseGIFAnimation(buf)
+	if err != nil {
+		return err
+	}
+
+	info.Width = anim.width
+	info.Height = anim.height
+	info.ColorType = ColorTypePalette
+	info.BitDepth = 8
+	if flags := buf[10]; flags&0x80 != 0 {
+		// Size of the global color table
+		info.BitDepth = int(flags&0x07) + 1
+	}
+	info.HasAlpha = anim.transparent
+	info.Interlaced = anim.interlaced
+	info.Frames = anim.frames
+	info.LoopCount = anim.webpLoopCount()
+	return nil
+}
+
+// probeWebP reads the canvas of an extended WebP or the bitstream header of a simple one
+func probeWebP(buf []byte, info *ImageInfo) error {
+	chunks, err := readWebPChunks(buf)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("WebP has no chunks")
+	}
+
+	info.BitDepth = 8
+	info.ColorType = ColorTypeRGB
+
+	for _, chunk := range chunks {
+		data := buf[chunk.offset : chunk.offset+chunk.size]
+		switch chunk.fourCC {
+		case "EXIF":
+			if orientation := readEXIFOrientation(data); orientation != 0 {
+				info.Orientation = orientation
+			}
+		case "ALPH":
+			info.HasAlpha = true
+		}
+	}
+
+	first := chunks[0]
+	data := buf[first.offset : first.offset+first.size]
+	switch first.fourCC {
+	case "VP8X":
+		// flags(1) reserved(3) canvas width-1(3) canvas height-1(3)
+		if first.size < webpVP8XSize {
+			return fmt.Errorf("truncated WebP VP8X chunk")
+		}
+		info.HasAlpha = data[0]&webpFlagAlpha != 0
+		info.Width = readUint24(data[4:]) + 1
+		info.Height = readUint24(data[7:]) + 1
+		if data[0]&webpFlagAnimation != 0 {
+			anim, err := readWebPAnimation(buf)
+			if err != nil {
+				return err
+			}
+			if anim == nil {
+				return fmt.Errorf("missing WebP ANIM chunk")
+			}
+			info.Frames = len(anim.durations)
+			info.LoopCount = anim.loopCount
+		}
+	case "VP8 ":
+		if first.size < webpVP8HeaderSize || !bytes.Equal(data[3:6], webpVP8StartCode) {
+			return fmt.Errorf("invalid WebP VP8 frame header")
+		}
+		// 14-bit sizes, the top two bits hold the upscaling mode
+		info.Width = int(binary.LittleEndian.Uint16(data[6:8]) & 0x3FFF)
+		info.Height = int(binary.LittleEndian.Uint16(data[8:10]) & 0x3FFF)
+	case "VP8L":
+		if first.size < webpVP8LHeaderSize || data[0] != webpVP8LSignature {
+			return fmt.Errorf("invalid WebP VP8L header")
+		}
+		// width-1(14) height-1(14) alpha is used(1) version(3)
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		info.Width = int(bits&0x3FFF) + 1
+		info.Height = int(bits>>14&0x3FFF) + 1
+		info.HasAlpha = bits>>28&1 != 0
+	default:
+		return fmt.Errorf("unexpected WebP chunk %q", first.fourCC)
+	}
+
+	return nil
+}
+
+// probeAVIF reads the properties of the primary item and the tracks of an image sequence
+func probeAVIF(buf []byte, info *ImageInfo) error {
+	boxes, err := readISOBoxes(buf)
+	if err != nil {
+		return err
+	}
+	meta, ok := findISOBox(boxes, "meta")
+	if !ok {
+		return fmt.Errorf("missing AVIF meta box")
+	}
+	_, _, metaData, err := readISOFullBox(meta.data)
+	if err != nil {
+		return err
+	}
+	children, err := readISOBoxes(metaData)
+	if err != nil {
+		return err
+	}
+
+	primary, err := readAVIFPrimaryItem(children)
+	if err != nil {
+		return err
+	}
+	properties, err := readAVIFItemProperties(children)
+	if err != nil {
+		return err
+	}
+	references, err := readAVIFItemReferences(children, "auxl")
+	if err != nil {
+		return err
+	}
+
+	item := avifItem{props: properties[primary]}
+	ispe, ok := item.property("ispe")
+	if !ok || len(ispe.raw) < isoBoxHeaderSize+isoFullBoxHeaderSize+8 {
+		return fmt.Errorf("missing AVIF image size")
+	}
+	r := isoReader{buf: ispe.raw[isoBoxHeaderSize+isoFullBoxHeaderSize:]}
+	info.Width = int(r.uint(4))
+	info.Height = int(r.uint(4))
+
+	info.BitDepth, info.ColorType = avifPixelFormat(item)
+	_, info.HasAlpha = findAVIFAlphaItem(primary, properties, references)
+	info.Orientation = avifOrientation(item)
+
+	seq, err := readAVIFSequence(buf)
+	if err != nil {
+		return err
+	}
+	if seq != nil && seq.frames > 0 {
+		info.Frames = seq.frames
+		info.LoopCount = seq.plays
+	}
+
+	return nil
+}
+
+// avifPixelFormat reads the bit depth and color type of an item from its av1C property,
+// or the pixi property of derived items
+func avifPixelFormat(item avifItem) (int, ColorType) {
+	bitDepth, colorType := 8, ColorTypeRGB
+	if av1C, ok := item.property("av1C"); ok && len(av1C.raw) >= isoBoxHeaderSize+3 {
+		// high_bitdepth, twelve_bit, monochrome, then the chroma layout
+		flags := av1C.raw[isoBoxHeaderSize+2]
+		if flags&0x40 != 0 {
+			bitDepth = 10
+			if flags&0x20 != 0 {
+				bitDepth = 12
+			}
+		}
+		if flags&0x10 != 0 {
+			colorType = ColorTypeGray
+		}
+	} else if pixi, ok := item.property("pixi"); ok && len(pixi.raw) >= isoBoxHeaderSize+isoFullBoxHeaderSize+2 {
+		// Derived items such as grids carry the depth in pixi: channel count, then bits per channel
+		data := pixi.raw[isoBoxHeaderSize+isoFullBoxHeaderSize:]
+		bitDepth = int(data[1])
+		if data[0] == 1 {
+			colorType = ColorTypeGray
+		}
+	}
+	return bitDepth, colorType
+}
+
+// avifOrientation converts the irot and imir transforms of an item to the EXIF orientation
+// a viewer applies to get the same result. The rotation comes first, as libavif writes them.
+func avifOrientation(item avifItem) int {
+	angle := 0
+	if irot, ok := item.property("irot"); ok && len(irot.raw) > isoBoxHeaderSize {
+		// Anti-clockwise quarter turns
+		angle = int(irot.raw[isoBoxHeaderSize] & 0x03)
+	}
+
+	imir, mirrored := item.property("imir")
+	if !mirrored || len(imir.raw) <= isoBoxHeaderSize {
+		return [4]int{1, 8, 3, 6}[angle]
+	}
+	// A top-to-bottom mirror equals a half turn followed by a left-to-right mirror
+	if imir.raw[isoBoxHeaderSize]&0x01 == 0 {
+		angle = (angle + 2) % 4
+	}
+	return [4]int{2, 7, 4, 5}[angle]
+}
+
+// readEXIFOrientation reads the orientation tag from the first IFD of EXIF data,
+// with or without the "Exif" header. It returns 0 when the tag is missing or invalid.
+func readEXIFOrientation(data []byte) int {
+	data = bytes.TrimPrefix(data, exifHeader)
+	if len(data) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int64(order.Uint32(data[4:8]))
+	if ifd+2 > int64(len(data)) {
+		return 0
+	}
+	count := int(order.Uint16(data[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + int64(i*exifEntrySize)
+		if entry+exifEntrySize > int64(len(data)) {
+			return 0
+		}
+		// tag(2) type(2) count(4) value(4), a short value sits in the first two bytes
+		if order.Uint16(data[entry:]) != exifTagOrientation {
+			continue
+		}
+		if order.Uint16(data[entry+2:]) != exifTypeShort {
+			return 0
+		}
+		orientation := int(order.Uint16(data[entry+8:]))
+		if orientation < 1 || orientation > 8 {
+			return 0
+		}
+		return orientation
+	}
+
+	return 0
+}
@@ -0,0 +1,240 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProbeImage(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected ImageInfo
+	}{
+		{"testdata/jpeg/colorspace_rgb.jpg", ImageInfo{Type: ImageTypeJPEG, Width: 640, Height: 480, BitDepth: 8, ColorType: ColorTypeRGB, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/jpeg/colorspace_grayscale.jpg", ImageInfo{Type: ImageTypeJPEG, Width: 640, Height: 480, BitDepth: 8, ColorType: ColorTypeGray, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/jpeg/colorspace_cmyk.jpg", ImageInfo{Type: ImageTypeJPEG, Width: 640, Height: 480, BitDepth: 8, ColorType: ColorTypeCMYK, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/jpeg/encoding_progressive.jpg", ImageInfo{Type: ImageTypeJPEG, Width: 640, Height: 480, BitDepth: 8, ColorType: ColorTypeRGB, Interlaced: true, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/jpeg/orientation_6.jpg", ImageInfo{Type: ImageTypeJPEG, Width: 640, Height: 480, BitDepth: 8, ColorType: ColorTypeRGB, Frames: 1, LoopCount: 1, Orientation: 6}},
+		{"testdata/jpeg/orientation_8.jpg", ImageInfo{Type: ImageTypeJPEG, Width: 640, Height: 480, BitDepth: 8, ColorType: ColorTypeRGB, Frames: 1, LoopCount: 1, Orientation: 8}},
+		{"testdata/png/colortype_palette.png", ImageInfo{Type: ImageTypePNG, Width: 480, Height: 480, BitDepth: 8, ColorType: ColorTypePalette, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/png/colortype_grayscale_alpha.png", ImageInfo{Type: ImageTypePNG, Width: 480, Height: 480, BitDepth: 8, ColorType: ColorTypeGray, HasAlpha: true, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/png/colortype_rgb.png", ImageInfo{Type: ImageTypePNG, Width: 480, Height: 480, BitDepth: 8, ColorType: ColorTypeRGB, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/png/depth_16bit.png", ImageInfo{Type: ImageTypePNG, Width: 480, Height: 480, BitDepth: 16, ColorType: ColorTypeRGB, HasAlpha: true, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/png/depth_1bit.png", ImageInfo{Type: ImageTypePNG, Width: 480, Height: 480, BitDepth: 1, ColorType: ColorTypeGray, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/png/interlace_adam7.png", ImageInfo{Type: ImageTypePNG, Width: 480, Height: 480, BitDepth: 8, ColorType: ColorTypeRGB, HasAlpha: true, Interlaced: true, Frames: 1, LoopCount: 1, Orientation: 1}},
+		{"testdata/gif/palette_16colors.gif", ImageInfo{Type: ImageTypeGIF, Width: 200, Height: 200, BitDepth: 4, ColorType: ColorTypePalette, Frames: 10, LoopCount: 0, Orientation: 1}},
+		{"testdata/gif/loop_loop_3times.gif", ImageInfo{Type: ImageTypeGIF, Width: 200, Height: 200, BitDepth: 8, ColorType: ColorTypePalette, HasAlpha: true, Frames: 10, LoopCount: 4, Orientation: 1}},
+		{"testdata/gif/frames_single.gif", ImageInfo{Type: ImageTypeGIF, Width: 200, Height: 200, BitDepth: 8, ColorType: ColorTypePalette, HasAlpha: true, Interlaced: true, Frames: 1, LoopCount: 0, Orientation: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			if _, err := os.Stat(tt.path); os.IsNotExist(err) {
+				t.Skip("Test file not found:", tt.path)
+			}
+			info, err := ProbeImage(tt.path)
+			if err != nil {
+				t.Fatalf("ProbeImage failed: %v", err)
+			}
+			if *info != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *info)
+			}
+		})
+	}
+}
+
+func TestProbeImageFromReader(t *testing.T) {
+	buf := buildAnimatedWebP(3, []int{40, 100, 200})
+	// Canvas of 320x240 with alpha and animation
+	vp8x := buf[riffHeaderSize+riffChunkHeader:]
+	vp8x[0] = webpFlagAlpha | webpFlagAnimation
+	putUint24(vp8x[4:], 319)
+	putUint24(vp8x[7:], 239)
+
+	info, err := ProbeImageFromReader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("ProbeImageFromReader failed: %v", err)
+	}
+	expected := ImageInfo{Type: ImageTypeWebP, Width: 320, Height: 240, BitDepth: 8, ColorType: ColorTypeRGB, HasAlpha: true, Frames: 3, LoopCount: 3, Orientation: 1}
+	if *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *info)
+	}
+}
+
+func TestProbeWebPSimple(t *testing.T) {
+	riff := func(fourCC string, payload []byte) []byte {
+		var b bytes.Buffer
+		b.WriteString("RIFF")
+		_ = binary.Write(&b, binary.LittleEndian, uint32(4+riffChunkHeader+len(payload)))
+		b.WriteString("WEBP")
+		b.WriteString(fourCC)
+		_ = binary.Write(&b, binary.LittleEndian, uint32(len(payload)))
+		b.Write(payload)
+		return b.Bytes()
+	}
+
+	vp8 := []byte{0x30, 0x01, 0x00, 0x9D, 0x01, 0x2A, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(vp8[6:], 640)
+	binary.LittleEndian.PutUint16(vp8[8:], 480)
+
+	vp8l := []byte{webpVP8LSignature, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(vp8l[1:], 99|49<<14|1<<28)
+
+	tests := []struct {
+		name          string
+		buf           []byte
+		width, height int
+		alpha         bool
+	}{
+		{"Lossy", riff("VP8 ", vp8), 640, 480, false},
+		{"Lossless", riff("VP8L", vp8l), 100, 50, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := probeImage(tt.buf)
+			if err != nil {
+				t.Fatalf("probeImage failed: %v", err)
+			}
+			if info.Width != tt.width || info.Height != tt.height || info.HasAlpha != tt.alpha || info.Frames != 1 {
+				t.Errorf("Unexpected info: %+v", *info)
+			}
+		})
+	}
+}
+
+func TestProbeAVIF(t *testing.T) {
+	still := buildTestAVIF([]byte("color"), []byte("alpha"), 200, 100)
+	info, err := probeImage(still)
+	if err != nil {
+		t.Fatalf("probeImage failed: %v", err)
+	}
+	expected := ImageInfo{Type: ImageTypeAVIF, Width: 200, Height: 100, BitDepth: 8, ColorType: ColorTypeRGB, HasAlpha: true, Frames: 1, LoopCount: 1, Orientation: 1}
+	if *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *info)
+	}
+
+	var frames []*avifStill
+	for _, name := range []string{"first", "second"} {
+		frame, err := parseAVIFStill(buildTestAVIF([]byte(name), []byte(name+"-alpha"), 32, 16))
+		if err != nil {
+			t.Fatalf("parseAVIFStill failed: %v", err)
+		}
+		frames = append(frames, frame)
+	}
+	sequence, err := muxAVIFSequence(frames, []int{100, 100}, 3)
+	if err != nil {
+		t.Fatalf("muxAVIFSequence failed: %v", err)
+	}
+	info, err = probeImage(sequence)
+	if err != nil {
+		t.Fatalf("probeImage failed: %v", err)
+	}
+	if info.Width != 32 || info.Height != 16 || info.Frames != 2 || info.LoopCount != 3 || !info.HasAlpha {
+		t.Errorf("Unexpected sequence info: %+v", *info)
+	}
+}
+
+func TestAVIFOrientation(t *testing.T) {
+	irot := func(angle byte) avifProperty {
+		return avifProperty{boxType: "irot", raw: isoBoxBytes("irot", []byte{angle})}
+	}
+	imir := func(axis byte) avifProperty {
+		return avifProperty{boxType: "imir", raw: isoBoxBytes("imir", []byte{axis})}
+	}
+
+	// The transforms libavif writes for each EXIF orientation
+	tests := []struct {
+		props       []avifProperty
+		orientation int
+	}{
+		{nil, 1},
+		{[]avifProperty{imir(1)}, 2},
+		{[]avifProperty{irot(2)}, 3},
+		{[]avifProperty{imir(0)}, 4},
+		{[]avifProperty{irot(1), imir(0)}, 5},
+		{[]avifProperty{irot(3)}, 6},
+		{[]avifProperty{irot(3), imir(0)}, 7},
+		{[]avifProperty{irot(1)}, 8},
+	}
+
+	for _, tt := range tests {
+		if got := avifOrientation(avifItem{props: tt.props}); got != tt.orientation {
+			t.Errorf("Expected orientation %d, got %d", tt.orientation, got)
+		}
+	}
+}
+
+func TestReadEXIFOrientation(t *testing.T) {
+	tiff := func(order binary.AppendByteOrder, header string, tagType uint16, value uint16) []byte {
+		buf := []byte(header)
+		buf = order.AppendUint32(buf, 8)
+		buf = order.AppendUint16(buf, 1)
+		buf = order.AppendUint16(buf, exifTagOrientation)
+		buf = order.AppendUint16(buf, tagType)
+		buf = order.AppendUint32(buf, 1)
+		buf = order.AppendUint16(buf, value)
+		buf = order.AppendUint16(buf, 0)
+		return order.AppendUint32(buf, 0)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		orientation int
+	}{
+		{"LittleEndian", tiff(binary.LittleEndian, "II*\x00", exifTypeShort, 6), 6},
+		{"BigEndian", tiff(binary.BigEndian, "MM\x00*", exifTypeShort, 8), 8},
+		{"ExifHeader", append([]byte("Exif\x00\x00"), tiff(binary.BigEndian, "MM\x00*", exifTypeShort, 3)...), 3},
+		{"OutOfRange", tiff(binary.LittleEndian, "II*\x00", exifTypeShort, 9), 0},
+		{"WrongType", tiff(binary.LittleEndian, "II*\x00", 4, 6), 0},
+		{"Truncated", tiff(binary.LittleEndian, "II*\x00", exifTypeShort, 6)[:16], 0},
+		{"NotTIFF", []byte("not exif data"), 0},
+	}
+
+	for _, tt := range tests {
+		if got := readEXIFOrientation(tt.data); got != tt.orientation {
+			t.Errorf("%s: expected orientation %d, got %d", tt.name, tt.orientation, got)
+		}
+	}
+}
+
+func TestProbeImageInvalid(t *testing.T) {
+	if _, err := probeImage([]byte("plain text, not an image")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+
+	truncated := append([]byte{}, pngMagic...)
+	truncated = append(truncated, 0, 0, 0, pngIHDRSize, 'I', 'H', 'D', 'R')
+	if _, err := probeImage(truncated); !errors.Is(err, ErrDecodeFailed) {
+		t.Errorf("Expected ErrDecodeFailed for a truncated PNG, got %v", err)
+	}
+
+	if _, err := ProbeImage("testdata/missing.png"); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestProbeAPNG(t *testing.T) {
+	ihdr := make([]byte, pngIHDRSize)
+	binary.BigEndian.PutUint32(ihdr[0:], 64)
+	binary.BigEndian.PutUint32(ihdr[4:], 32)
+	ihdr[8], ihdr[9] = 8, pngColorRGBA
+	actl := make([]byte, pngACTLSize)
+	binary.BigEndian.PutUint32(actl[0:], 12)
+	binary.BigEndian.PutUint32(actl[4:], 0)
+
+	buf := buildTestPNG(testPNGChunk("IHDR", ihdr), testPNGChunk("acTL", actl), testPNGChunk("IDAT", nil), testPNGChunk("IEND", nil))
+	info, err := probeImage(buf)
+	if err != nil {
+		t.Fatalf("probeImage failed: %v", err)
+	}
+	expected := ImageInfo{Type: ImageTypeAPNG, Width: 64, Height: 32, BitDepth: 8, ColorType: ColorTypeRGB, HasAlpha: true, Frames: 12, LoopCount: 0, Orientation: 1}
+	if *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *info)
+	}
+}
@@ -0,0 +1,39 @@
+package nextgenimage
+
+import "time"
+
+// EncoderMode describes how the output image was encoded
+type EncoderMode string
+
+const (
+	EncoderModeLossy        EncoderMode = "lossy"
+	EncoderModeLossless     EncoderMode = "lossless"
+	EncoderModeNearLossless EncoderMode = "near-lossless"
+)
+
+// ConversionResult describes a successful conversion
+type ConversionResult struct {
+	InputType         ImageType     // Detected input format
+	OutputFormat      ImageType     // ImageTypeWebP or ImageTypeAVIF
+	InputSize         int64         // Input size in bytes
+	OutputSize        int64         // Output size in bytes
+	Width             int           // Output width in pixels (after auto-rotation)
+	Height            int           // Output height in pixels, per frame for animations
+	Frames            int           // Number of frames, 1 for still images
+	Mode              EncoderMode   // Encoder mode actually chosen
+	Quality           int           // Effective WebP quality or AVIF CQ, 0 for lossless
+	SSIM              float64       // SSIM reached in SSIM target mode, 0 otherwise
+	ContentClass      ContentClass  // Content class picked by the automatic PNG strategy, empty otherwise
+	SourceQuality     int           // Estimated quality of a JPEG input, 0 otherwise
+	SourceSubsampling string        // Chroma subsampling of a JPEG input such as "4:2:0", empty otherwise
+	Subsampling       string        // Chroma subsampling of the output, "4:4:4" or "4:2:0"
+	Elapsed           time.Duration // Time spent decoding and encoding
+}
+
+// SizeReduction returns the saved size as a percentage of the input size
+func (r *ConversionResult) SizeReduction() float64 {
+	if r.InputSize == 0 {
+		return 0
+	}
+	return float64(r.InputSize-r.OutputSize) / float64(r.InputSize) * 100
+}
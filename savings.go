@@ -0,0 +1,33 @@
+package nextgenimage
+
+import "fmt"
+
+// InsufficientSavingsError reports an output that does not save enough over its input.
+// Converters return it wrapped in a FormatError, and it matches ErrNotSmaller.
+type InsufficientSavingsError struct {
+	InputSize  int64 // Input size in bytes
+	OutputSize int64 // Output size in bytes
+}
+
+func (e *InsufficientSavingsError) Error() string {
+	if e.OutputSize >= e.InputSize {
+		return fmt.Sprintf("output file size (%d) is not smaller than input (%d)", e.OutputSize, e.InputSize)
+	}
+	return fmt.Sprintf("output file size (%d) saves too little over input (%d)", e.OutputSize, e.InputSize)
+}
+
+// Is matches ErrNotSmaller
+func (e *InsufficientSavingsError) Is(target error) bool {
+	return target == ErrNotSmaller
+}
+
+// checkSavings rejects outputs that are not smaller than the input by at least the configured
+// minimum savings, in bytes and as a fraction of the input size
+func (c *Converter) checkSavings(inputSize, outputSize int) error {
+	saved := int64(inputSize - outputSize)
+	if saved <= 0 || saved < c.config.MinSavingsBytes ||
+		float64(saved) < c.config.MinSavingsRatio*float64(inputSize) {
+		return NewFormatError(&InsufficientSavingsError{InputSize: int64(inputSize), OutputSize: int64(outputSize)})
+	}
+	return nil
+}
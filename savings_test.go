@@ -0,0 +1,89 @@
+package nextgenimage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSavings(t *testing.T) {
+	tests := []struct {
+		name       string
+		minBytes   int64
+		minRatio   float64
+		inputSize  int
+		outputSize int
+		wantErr    bool
+	}{
+		{"Smaller by default", 0, 0, 1000, 999, false},
+		{"Same size by default", 0, 0, 1000, 1000, true},
+		{"Larger by default", 0, 0, 1000, 1200, true},
+		{"Enough bytes", 100, 0, 1000, 900, false},
+		{"Too few bytes", 100, 0, 1000, 901, true},
+		{"Enough ratio", 0, 0.1, 1000, 900, false},
+		{"Too low ratio", 0, 0.1, 1000, 901, true},
+		{"Both required", 50, 0.2, 1000, 850, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ConverterConfig{MinSavingsBytes: tt.minBytes, MinSavingsRatio: tt.minRatio}
+			err := NewConverter(config).checkSavings(tt.inputSize, tt.outputSize)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) {
+				t.Errorf("Expected FormatError, got %v", err)
+			}
+			var savingsErr *InsufficientSavingsError
+			if !errors.As(err, &savingsErr) {
+				t.Fatalf("Expected InsufficientSavingsError, got %v", err)
+			}
+			if savingsErr.InputSize != int64(tt.inputSize) || savingsErr.OutputSize != int64(tt.outputSize) {
+				t.Errorf("Expected sizes %d and %d, got %d and %d", tt.inputSize, tt.outputSize, savingsErr.InputSize, savingsErr.OutputSize)
+			}
+		})
+	}
+}
+
+func TestMinSavings(t *testing.T) {
+	inputPath := "testdata/test_original.jpg"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+	tempDir := t.TempDir()
+	outputPath := filepath.Join(tempDir, "test.webp")
+
+	// The default conversion saves something but not 99%
+	result, err := NewConverter(ConverterConfig{}).ToWebP(inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+	os.Remove(outputPath)
+
+	config := ConverterConfig{MinSavingsRatio: 0.99}
+	_, err = NewConverter(config).ToWebP(inputPath, outputPath)
+	var savingsErr *InsufficientSavingsError
+	if !errors.As(err, &savingsErr) {
+		t.Fatalf("Expected InsufficientSavingsError, got %v", err)
+	}
+	if savingsErr.InputSize != result.InputSize || savingsErr.OutputSize != result.OutputSize {
+		t.Errorf("Expected sizes %d and %d, got %d and %d", result.InputSize, result.OutputSize, savingsErr.InputSize, savingsErr.OutputSize)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("Output file should not be written when savings are insufficient")
+	}
+
+	// A threshold the conversion meets still succeeds
+	config = ConverterConfig{MinSavingsBytes: (result.InputSize - result.OutputSize) / 2}
+	if _, err := NewConverter(config).ToWebP(inputPath, outputPath); err != nil {
+		t.Errorf("Conversion with a reachable threshold failed: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package nextgenimage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+	"github.com/ideamans/go-next-gen-image/metrics"
+)
+
+// TargetSSIM selects the lowest WebP quality or AVIF CQ whose output reaches a structural
+// similarity score against the decoded source, so each image gets only the bytes it needs
+type TargetSSIM struct {
+	Score      float64 // Minimum SSIM (0-1) of the output, 0 disables SSIM target mode
+	MinQuality int     // Lowest WebP quality or AVIF CQ the search may pick (default: 1)
+}
+
+// ssimReference holds the decoded source pixels for scoring candidates
+type ssimReference struct {
+	image *metrics.Image
+}
+
+// newSSIMReference captures the pixels of the decoded source
+func newSSIMReference(image *vips.ImageRef) (*ssimReference, error) {
+	reference, err := metrics.FromVips(image)
+	if err != nil {
+		return nil, err
+	}
+	return &ssimReference{image: reference}, nil
+}
+
+// score decodes an encoded candidate and returns its SSIM against the reference
+func (r *ssimReference) score(candidate []byte) (float64, error) {
+	image, err := vips.NewImageFromBuffer(candidate)
+	if err != nil {
+		return 0, err
+	}
+	defer image.Close()
+
+	decoded, err := metrics.FromVips(image)
+	if err != nil {
+		return 0, err
+	}
+	return metrics.SSIM(r.image, decoded)
+}
+
+// searchSSIM bisects [target.MinQuality, maxQuality] for the lowest quality whose output reaches
+// target.Score, assuming similarity grows with quality. It returns the output, the chosen quality
+// and the score it reached.
+func searchSSIM(ctx context.Context, target TargetSSIM, maxQuality int, encode func(quality int) ([]byte, error), score func([]byte) (float64, error)) ([]byte, int, float64, error) {
+	var best []byte
+	bestQuality := 0
+	bestScore := 0.0
+
+	low, high := target.MinQuality, maxQuality
+	for low <= high {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, 0, err
+		}
+
+		quality := low + (high-low)/2
+		outputBuffer, err := encode(quality)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		s, err := score(outputBuffer)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		if s >= target.Score {
+			best, bestQuality, bestScore = outputBuffer, quality, s
+			high = quality - 1
+		} else {
+			low = quality + 1
+		}
+	}
+
+	if best == nil {
+		return nil, 0, 0, NewFormatError(fmt.Errorf("output does not reach SSIM %.4f even at quality %d", target.Score, maxQuality))
+	}
+
+	return best, bestQuality, bestScore, nil
+}
@@ -0,0 +1,88 @@
+package nextgenimage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchSSIM(t *testing.T) {
+	// Similarity grows with quality, reaching 0.5 + quality/200
+	encode := func(quality int) ([]byte, error) {
+		return make([]byte, quality), nil
+	}
+	score := func(buf []byte) (float64, error) {
+		return 0.5 + float64(len(buf))/200, nil
+	}
+
+	buf, quality, s, err := searchSSIM(context.Background(), TargetSSIM{Score: 0.9, MinQuality: 1}, 100, encode, score)
+	if err != nil {
+		t.Fatalf("searchSSIM failed: %v", err)
+	}
+	if quality != 80 || len(buf) != 80 {
+		t.Errorf("Expected quality 80, got %d", quality)
+	}
+	if s < 0.9 {
+		t.Errorf("Reported score %f is below the target", s)
+	}
+
+	_, _, _, err = searchSSIM(context.Background(), TargetSSIM{Score: 0.99, MinQuality: 1}, 63, encode, score)
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) {
+		t.Errorf("Expected FormatError for an unreachable score, got %v", err)
+	}
+}
+
+func TestTargetSSIM(t *testing.T) {
+	inputPath := "testdata/test_original.jpg"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+	tempDir := t.TempDir()
+
+	formats := []struct {
+		name    string
+		convert func(c *Converter, in, out string) (*ConversionResult, error)
+	}{
+		{"WebP", (*Converter).ToWebP},
+		{"AVIF", (*Converter).ToAVIF},
+	}
+
+	for _, format := range formats {
+		t.Run(format.name, func(t *testing.T) {
+			outputPath := filepath.Join(tempDir, "test."+format.name)
+
+			config := ConverterConfig{}
+			config.TargetSSIM.Score = 0.95
+			result, err := format.convert(NewConverter(config), inputPath, outputPath)
+			if err != nil {
+				t.Fatalf("SSIM target conversion failed: %v", err)
+			}
+			if result.SSIM < 0.95 || result.SSIM > 1 {
+				t.Errorf("Expected SSIM of at least 0.95, got %f", result.SSIM)
+			}
+			t.Logf("SSIM %.4f at quality %d, %d bytes", result.SSIM, result.Quality, result.OutputSize)
+
+			// A stricter target needs at least as high a quality
+			config.TargetSSIM.Score = 0.98
+			strict, err := format.convert(NewConverter(config), inputPath, outputPath)
+			if err != nil {
+				t.Fatalf("SSIM target conversion failed: %v", err)
+			}
+			if strict.Quality < result.Quality {
+				t.Errorf("Stricter target chose a lower quality (%d < %d)", strict.Quality, result.Quality)
+			}
+
+			// No encoding scores above 1
+			config.TargetSSIM.Score = 1.1
+			_, err = format.convert(NewConverter(config), inputPath, outputPath)
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) {
+				t.Errorf("Expected FormatError for an unreachable score, got %v", err)
+			}
+		})
+	}
+}
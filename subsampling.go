@@ -0,0 +1,53 @@
+package nextgenimage
+
+// SubsamplingPolicy decides whether chroma subsampling follows the source JPEG
+type SubsamplingPolicy int
+
+const (
+	// SubsamplingMatchSource encodes AVIF from a 4:4:4 source with full chroma and from a
+	// subsampled source with 4:2:0 at any CQ, and WebP from a 4:4:4 source with sharp YUV
+	// conversion, since lossy WebP is always 4:2:0 (default)
+	SubsamplingMatchSource SubsamplingPolicy = iota
+	// SubsamplingEncoderDefault leaves subsampling to libvips, which subsamples lossy AVIF
+	// below CQ 90, and converts WebP without sharp YUV
+	SubsamplingEncoderDefault
+)
+
+// Chroma subsampling in J:a:b notation
+const (
+	subsampling444 = "4:4:4"
+	subsampling420 = "4:2:0"
+)
+
+// matchingAVIFChroma returns the AVIF chroma subsampling closest to that of a JPEG source.
+// Grayscale and unknown sources are left to libvips.
+func matchingAVIFChroma(source string) AVIFChromaSubsampling {
+	switch source {
+	case subsampling444:
+		return AVIFSubsampling444
+	case "", "4:0:0":
+		return AVIFSubsamplingAuto
+	default:
+		return AVIFSubsampling420
+	}
+}
+
+// outputSubsampling returns the chroma subsampling libvips writes for an encode. The AVIF
+// chroma option only applies to lossy AVIF.
+func outputSubsampling(format ImageType, mode EncoderMode, quality int, chroma AVIFChromaSubsampling) string {
+	switch {
+	case mode != EncoderModeLossy:
+		// Lossless and near-lossless encodes store RGB
+		return subsampling444
+	case format == ImageTypeAVIF && chroma != AVIFSubsamplingAuto:
+		if chroma == AVIFSubsampling444 {
+			return subsampling444
+		}
+		return subsampling420
+	case format == ImageTypeAVIF && quality >= avifFullChromaQ:
+		return subsampling444
+	default:
+		// Lossy WebP is always 4:2:0
+		return subsampling420
+	}
+}
@@ -0,0 +1,158 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputSubsampling(t *testing.T) {
+	tests := []struct {
+		format   ImageType
+		mode     EncoderMode
+		quality  int
+		chroma   AVIFChromaSubsampling
+		expected string
+	}{
+		{ImageTypeWebP, EncoderModeLossy, 100, AVIFSubsamplingAuto, "4:2:0"},
+		{ImageTypeWebP, EncoderModeNearLossless, 60, AVIFSubsamplingAuto, "4:4:4"},
+		{ImageTypeWebP, EncoderModeLossless, 0, AVIFSubsamplingAuto, "4:4:4"},
+		{ImageTypeAVIF, EncoderModeLossy, 25, AVIFSubsamplingAuto, "4:2:0"},
+		{ImageTypeAVIF, EncoderModeLossy, 90, AVIFSubsamplingAuto, "4:4:4"},
+		{ImageTypeAVIF, EncoderModeLossless, 0, AVIFSubsamplingAuto, "4:4:4"},
+		{ImageTypeAVIF, EncoderModeLossy, 25, AVIFSubsampling444, "4:4:4"},
+		{ImageTypeAVIF, EncoderModeLossy, 95, AVIFSubsampling420, "4:2:0"},
+		{ImageTypeAVIF, EncoderModeLossless, 0, AVIFSubsampling420, "4:4:4"},
+	}
+
+	for _, tt := range tests {
+		if got := outputSubsampling(tt.format, tt.mode, tt.quality, tt.chroma); got != tt.expected {
+			t.Errorf("%s %s at %d: expected %s, got %s", tt.format, tt.mode, tt.quality, tt.expected, got)
+		}
+	}
+}
+
+func TestMatchingAVIFChroma(t *testing.T) {
+	tests := map[string]AVIFChromaSubsampling{
+		"4:4:4": AVIFSubsampling444,
+		"4:2:0": AVIFSubsampling420,
+		"4:2:2": AVIFSubsampling420,
+		"4:0:0": AVIFSubsamplingAuto,
+		"":      AVIFSubsamplingAuto,
+	}
+	for source, expected := range tests {
+		if got := matchingAVIFChroma(source); got != expected {
+			t.Errorf("%q: expected %d, got %d", source, expected, got)
+		}
+	}
+
+	// The policy applies by default, an explicit chroma subsampling wins over it
+	input := &decodedInput{subsampling: subsampling444}
+	config := ConverterConfig{}
+	if got := NewConverter(config).jpegAVIFChroma(input); got != AVIFSubsampling444 {
+		t.Errorf("Expected 4:4:4 by default, got %d", got)
+	}
+	config.JPEGToAVIF.Subsampling = SubsamplingEncoderDefault
+	if got := NewConverter(config).jpegAVIFChroma(input); got != AVIFSubsamplingAuto {
+		t.Errorf("Expected libvips to pick with the encoder default, got %d", got)
+	}
+	config = ConverterConfig{}
+	config.JPEGToAVIF.ChromaSubsampling = AVIFSubsampling420
+	if got := NewConverter(config).jpegAVIFChroma(input); got != AVIFSubsampling420 {
+		t.Errorf("Expected the explicit 4:2:0, got %d", got)
+	}
+}
+
+func TestMatchSourceSubsampling(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		path       string
+		policy     SubsamplingPolicy
+		subsampled bool
+	}{
+		{"testdata/jpeg/subsampling_444.jpg", SubsamplingMatchSource, false},
+		{"testdata/jpeg/subsampling_444.jpg", SubsamplingEncoderDefault, true},
+		{"testdata/jpeg/subsampling_420.jpg", SubsamplingMatchSource, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			if _, err := os.Stat(tt.path); os.IsNotExist(err) {
+				t.Skip("Test file not found:", tt.path)
+			}
+
+			config := ConverterConfig{}
+			config.JPEGToAVIF.Subsampling = tt.policy
+			outputPath := filepath.Join(tempDir, "out.avif")
+			result, err := NewConverter(config).ToAVIF(tt.path, outputPath)
+			if err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+
+			// Chroma follows the source at the configured CQ
+			_, subsampled := av1ConfigFlags(t, outputPath)
+			if subsampled != tt.subsampled || result.Quality != 25 {
+				t.Errorf("Expected subsampled=%v at CQ 25, got %v at CQ %d", tt.subsampled, subsampled, result.Quality)
+			}
+			if (result.Subsampling == "4:2:0") != subsampled {
+				t.Errorf("Result reports %s but the AV1 config says subsampled=%v", result.Subsampling, subsampled)
+			}
+			t.Logf("%s source: %s output at CQ %d, %d bytes", result.SourceSubsampling, result.Subsampling, result.Quality, result.OutputSize)
+		})
+	}
+}
+
+func TestMatchSourceSubsamplingSearch(t *testing.T) {
+	inputPath := "testdata/jpeg/subsampling_444.jpg"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+	}
+
+	config := ConverterConfig{}
+	config.TargetSSIM.Score = 0.9
+	outputPath := filepath.Join(t.TempDir(), "out.avif")
+	result, err := NewConverter(config).ToAVIF(inputPath, outputPath)
+	if errors.As(err, new(*FormatError)) {
+		t.Skipf("Target not reachable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+
+	// Every CQ the search tries keeps full chroma
+	if _, subsampled := av1ConfigFlags(t, outputPath); subsampled || result.Subsampling != "4:4:4" {
+		t.Errorf("Expected 4:4:4 output at CQ %d, got %s", result.Quality, result.Subsampling)
+	}
+	t.Logf("%s output at CQ %d, SSIM %.4f", result.Subsampling, result.Quality, result.SSIM)
+}
+
+func TestMatchSourceSubsamplingWebP(t *testing.T) {
+	inputPath := "testdata/jpeg/subsampling_444.jpg"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+	}
+	tempDir := t.TempDir()
+
+	convert := func(policy SubsamplingPolicy) []byte {
+		t.Helper()
+		config := ConverterConfig{}
+		config.JPEGToWebP.Subsampling = policy
+		outputPath := filepath.Join(tempDir, "out.webp")
+		if _, err := NewConverter(config).ToWebP(inputPath, outputPath); err != nil {
+			t.Fatalf("Conversion failed: %v", err)
+		}
+		output, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		return output
+	}
+
+	// Sharp YUV conversion changes the encoded chroma of a 4:4:4 source
+	if bytes.Equal(convert(SubsamplingMatchSource), convert(SubsamplingEncoderDefault)) {
+		t.Error("Expected sharp YUV output to differ from the encoder default")
+	}
+}
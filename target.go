@@ -0,0 +1,99 @@
+package nextgenimage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Upper ends of the quality ranges searched in target size mode. libvips takes the AVIF CQ
+// as its 1-100 quality, so higher is better for both formats.
+const (
+	webpMaxQuality = 100
+	avifMaxQuality = 100
+)
+
+// TargetSize caps the output size. Lossy encodes (JPEG input) search for the highest
+// WebP quality or AVIF CQ that fits, any other output must fit as encoded.
+type TargetSize struct {
+	MaxBytes   int64 // Maximum output size in bytes, 0 disables target size mode
+	MinQuality int   // Lowest WebP quality or AVIF CQ the search may pick (default: 1)
+}
+
+// searchQuality bisects [target.MinQuality, maxQuality] for the highest quality whose output
+// fits in target.MaxBytes, assuming output size grows with quality. The same decoded image is
+// encoded at every step, and the output and the chosen quality are returned.
+func searchQuality(ctx context.Context, target TargetSize, maxQuality int, encode func(quality int) ([]byte, error)) ([]byte, int, error) {
+	var best []byte
+	bestQuality := 0
+
+	low, high := target.MinQuality, maxQuality
+	for low <= high {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		quality := low + (high-low)/2
+		outputBuffer, err := encode(quality)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if int64(len(outputBuffer)) <= target.MaxBytes {
+			best, bestQuality = outputBuffer, quality
+			low = quality + 1
+		} else {
+			high = quality - 1
+		}
+	}
+
+	if best == nil {
+		return nil, 0, NewFormatError(fmt.Errorf("output does not fit in %d bytes even at quality %d", target.MaxBytes, target.MinQuality))
+	}
+
+	return best, bestQuality, nil
+}
+
+// encodeLossy encodes image at the configured quality, or searches for one in SSIM or target
+// size mode, SSIM taking precedence. Searches never go below minQuality or the configured
+// minimum. It returns the output, the quality used and the SSIM reached, which is 0 outside
+// SSIM target mode.
+func (c *Converter) encodeLossy(ctx context.Context, image *vips.ImageRef, quality, minQuality, maxQuality int, encode func(quality int) ([]byte, error)) ([]byte, int, float64, error) {
+	switch {
+	case c.config.TargetSSIM.Score > 0:
+		reference, err := newSSIMReference(image)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to read source pixels: %w", NewFormatError(err))
+		}
+		score := func(candidate []byte) (float64, error) {
+			s, err := reference.score(candidate)
+			if err != nil {
+				return 0, fmt.Errorf("failed to compute ssim: %w", NewFormatError(err))
+			}
+			return s, nil
+		}
+		target := c.config.TargetSSIM
+		target.MinQuality = max(target.MinQuality, minQuality)
+		return searchSSIM(ctx, target, maxQuality, encode, score)
+
+	case c.config.TargetSize.MaxBytes > 0:
+		target := c.config.TargetSize
+		target.MinQuality = max(target.MinQuality, minQuality)
+		outputBuffer, quality, err := searchQuality(ctx, target, maxQuality, encode)
+		return outputBuffer, quality, 0, err
+
+	default:
+		outputBuffer, err := encode(quality)
+		return outputBuffer, quality, 0, err
+	}
+}
+
+// checkTargetSize rejects outputs larger than the target size
+func (c *Converter) checkTargetSize(outputBuffer []byte) error {
+	maxBytes := c.config.TargetSize.MaxBytes
+	if maxBytes > 0 && int64(len(outputBuffer)) > maxBytes {
+		return NewFormatError(fmt.Errorf("output file size (%d) exceeds the target size (%d)", len(outputBuffer), maxBytes))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package nextgenimage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchQuality(t *testing.T) {
+	// Output size grows by 100 bytes per quality step
+	calls := 0
+	encode := func(quality int) ([]byte, error) {
+		calls++
+		return make([]byte, quality*100), nil
+	}
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+		expected int
+	}{
+		{"Exact", 5000, 50},
+		{"Between", 5050, 50},
+		{"Everything fits", 100000, 100},
+		{"Only lowest fits", 100, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			buf, quality, err := searchQuality(context.Background(), TargetSize{MaxBytes: tt.maxBytes, MinQuality: 1}, 100, encode)
+			if err != nil {
+				t.Fatalf("searchQuality failed: %v", err)
+			}
+			if quality != tt.expected {
+				t.Errorf("Expected quality %d, got %d", tt.expected, quality)
+			}
+			if len(buf) != quality*100 {
+				t.Errorf("Returned output does not match quality %d", quality)
+			}
+			if calls > 7 {
+				t.Errorf("Expected at most 7 encodes for 100 qualities, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestSearchQualityNothingFits(t *testing.T) {
+	encode := func(quality int) ([]byte, error) {
+		return make([]byte, 1000+quality), nil
+	}
+
+	_, _, err := searchQuality(context.Background(), TargetSize{MaxBytes: 500, MinQuality: 10}, 100, encode)
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) {
+		t.Errorf("Expected FormatError, got %v", err)
+	}
+}
+
+func TestSearchQualityErrors(t *testing.T) {
+	encodeErr := errors.New("encode failed")
+	_, _, err := searchQuality(context.Background(), TargetSize{MaxBytes: 500, MinQuality: 1}, 100, func(int) ([]byte, error) {
+		return nil, encodeErr
+	})
+	if !errors.Is(err, encodeErr) {
+		t.Errorf("Expected encode error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = searchQuality(ctx, TargetSize{MaxBytes: 500, MinQuality: 1}, 100, func(int) ([]byte, error) {
+		t.Error("Encoder called after cancellation")
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTargetSize(t *testing.T) {
+	inputPath := "testdata/test_original.jpg"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+	tempDir := t.TempDir()
+
+	formats := []struct {
+		name    string
+		convert func(c *Converter, in, out string) (*ConversionResult, error)
+	}{
+		{"WebP", (*Converter).ToWebP},
+		{"AVIF", (*Converter).ToAVIF},
+	}
+
+	for _, format := range formats {
+		t.Run(format.name, func(t *testing.T) {
+			outputPath := filepath.Join(tempDir, "test."+format.name)
+
+			// Budget half of what the default quality produces
+			base, err := format.convert(NewConverter(ConverterConfig{}), inputPath, outputPath)
+			if err != nil {
+				t.Fatalf("Conversion failed: %v", err)
+			}
+
+			config := ConverterConfig{}
+			config.TargetSize.MaxBytes = base.OutputSize / 2
+			result, err := format.convert(NewConverter(config), inputPath, outputPath)
+			if err != nil {
+				t.Fatalf("Target size conversion failed: %v", err)
+			}
+			if result.OutputSize > config.TargetSize.MaxBytes {
+				t.Errorf("Output size %d exceeds target %d", result.OutputSize, config.TargetSize.MaxBytes)
+			}
+			if result.Quality < 1 || result.Quality >= base.Quality {
+				t.Errorf("Expected a quality below %d, got %d", base.Quality, result.Quality)
+			}
+			t.Logf("Target %d bytes: quality %d, %d bytes", config.TargetSize.MaxBytes, result.Quality, result.OutputSize)
+
+			// Nothing fits in a few bytes
+			config.TargetSize.MaxBytes = 10
+			_, err = format.convert(NewConverter(config), inputPath, outputPath)
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) {
+				t.Errorf("Expected FormatError for an impossible target, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTargetSizeLossless(t *testing.T) {
+	inputPath := "testdata/test_original.png"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+
+	// Lossless output has no quality to search, it fits or fails
+	config := ConverterConfig{}
+	config.TargetSize.MaxBytes = 10
+	_, err := NewConverter(config).ToWebP(inputPath, filepath.Join(t.TempDir(), "test.webp"))
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) {
+		t.Errorf("Expected FormatError, got %v", err)
+	}
+}
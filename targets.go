@@ -0,0 +1,120 @@
+package nextgenimage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// OutputSpec describes one output of ToTargets
+type OutputSpec struct {
+	Format ImageType        // ImageTypeWebP or ImageTypeAVIF
+	Path   string           // Output file path
+	Config *ConverterConfig // Encoder settings for this output, nil uses the converter's
+}
+
+// TargetResult is the outcome of one OutputSpec
+type TargetResult struct {
+	Spec   OutputSpec
+	Result *ConversionResult // nil when Err is set
+	Err    error             // Why this output failed, other outputs are still written
+}
+
+// ToTargets decodes an image once and encodes it to every spec, writing each output that succeeds.
+// Decoding follows the converter's Metadata and ColorManagement settings for all outputs,
+// a spec's Config only changes how its output is encoded. Each Elapsed covers that encode alone.
+func (c *Converter) ToTargets(inputPath string, specs []OutputSpec) ([]TargetResult, error) {
+	return c.ToTargetsContext(context.Background(), inputPath, specs)
+}
+
+// ToTargetsContext is ToTargets aborting when ctx is done.
+// On cancellation or deadline it returns ctx.Err() and writes no further outputs.
+// As with ToWebPContext, a libvips call already running finishes in the background first.
+func (c *Converter) ToTargetsContext(ctx context.Context, inputPath string, specs []OutputSpec) ([]TargetResult, error) {
+	// Read input file
+	inputBuffer, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	outputBuffers, results, err := c.ToTargetsBytesContext(ctx, inputBuffer, specs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		if err := writeOutputFile(ctx, results[i].Spec.Path, outputBuffers[i]); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			results[i].Result, results[i].Err = nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// ToTargetsBytes decodes an in-memory image once and encodes it to every spec, ignoring their paths.
+// The returned buffers line up with the specs and are nil for outputs that failed.
+func (c *Converter) ToTargetsBytes(inputBuffer []byte, specs []OutputSpec) ([][]byte, []TargetResult, error) {
+	return c.ToTargetsBytesContext(context.Background(), inputBuffer, specs)
+}
+
+// ToTargetsBytesContext is ToTargetsBytes aborting when ctx is done
+func (c *Converter) ToTargetsBytesContext(ctx context.Context, inputBuffer []byte, specs []OutputSpec) ([][]byte, []TargetResult, error) {
+	var results []TargetResult
+	var outputBuffers [][]byte
+	_, err := runWithContext(ctx, func() ([]byte, error) {
+		var err error
+		outputBuffers, results, err = c.toTargets(ctx, inputBuffer, specs)
+		return nil, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return outputBuffers, results, nil
+}
+
+// toTargets runs the shared decode and one encode per spec, checking ctx between encodes
+func (c *Converter) toTargets(ctx context.Context, inputBuffer []byte, specs []OutputSpec) ([][]byte, []TargetResult, error) {
+	input, err := c.decodeInput(ctx, inputBuffer)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer input.Close()
+
+	outputBuffers := make([][]byte, len(specs))
+	results := make([]TargetResult, len(specs))
+	for i, spec := range specs {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		encoder := c
+		if spec.Config != nil {
+			encoder = NewConverter(*spec.Config)
+		}
+
+		start := time.Now()
+		results[i].Spec = spec
+		switch spec.Format {
+		case ImageTypeWebP:
+			outputBuffers[i], results[i].Result, results[i].Err = encoder.encodeWebP(ctx, input, start)
+		case ImageTypeAVIF:
+			outputBuffers[i], results[i].Result, results[i].Err = encoder.encodeAVIF(ctx, input, start)
+		default:
+			results[i].Err = newKindError(ErrUnsupportedFormat, fmt.Errorf("unsupported output format: %s", spec.Format))
+		}
+	}
+
+	// An encode cut short by cancellation is not a per-output failure
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return outputBuffers, results, nil
+}
@@ -0,0 +1,85 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestToTargets(t *testing.T) {
+	inputPaths := []string{"testdata/test_original.jpg", "testdata/test_original.png", "testdata/test_original.gif"}
+	for _, path := range inputPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Skip("Test file not found:", path)
+			return
+		}
+	}
+	converter := NewConverter(ConverterConfig{})
+
+	lowQuality := ConverterConfig{}
+	lowQuality.JPEGToWebP.Quality = 40
+
+	for _, inputPath := range inputPaths {
+		t.Run(filepath.Base(inputPath), func(t *testing.T) {
+			tempDir := t.TempDir()
+			specs := []OutputSpec{
+				{Format: ImageTypeWebP, Path: filepath.Join(tempDir, "out.webp")},
+				{Format: ImageTypeAVIF, Path: filepath.Join(tempDir, "out.avif")},
+				{Format: ImageTypeWebP, Path: filepath.Join(tempDir, "low.webp"), Config: &lowQuality},
+				{Format: ImageTypePNG, Path: filepath.Join(tempDir, "out.png")},
+			}
+
+			results, err := converter.ToTargets(inputPath, specs)
+			if err != nil {
+				t.Fatalf("ToTargets failed: %v", err)
+			}
+			if len(results) != len(specs) {
+				t.Fatalf("Expected %d results, got %d", len(specs), len(results))
+			}
+
+			input, err := os.ReadFile(inputPath)
+			if err != nil {
+				t.Fatalf("Failed to read input: %v", err)
+			}
+			single := map[ImageType]func([]byte) ([]byte, error){
+				ImageTypeWebP: converter.ToWebPBytes,
+				ImageTypeAVIF: converter.ToAVIFBytes,
+			}
+
+			// The shared decode gives the same bytes as converting each format on its own
+			for _, target := range results[:2] {
+				expected, expectedErr := single[target.Spec.Format](input)
+				if (target.Err != nil) != (expectedErr != nil) {
+					t.Errorf("%s: shared decode returned %v, single conversion %v", target.Spec.Format, target.Err, expectedErr)
+					continue
+				}
+				if target.Err != nil {
+					continue
+				}
+				output, err := os.ReadFile(target.Spec.Path)
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", target.Spec.Path, err)
+				}
+				if !bytes.Equal(output, expected) {
+					t.Errorf("%s: output differs from the single conversion (%d vs %d bytes)", target.Spec.Format, len(output), len(expected))
+				}
+			}
+
+			// Per-spec settings apply to their output only
+			if low, def := results[2], results[0]; low.Err == nil && def.Err == nil && def.Result.InputType == ImageTypeJPEG {
+				if low.Result.Quality != 40 || def.Result.Quality != 80 {
+					t.Errorf("Expected qualities 40 and 80, got %d and %d", low.Result.Quality, def.Result.Quality)
+				}
+			}
+
+			if !errors.Is(results[3].Err, ErrUnsupportedFormat) {
+				t.Errorf("Expected ErrUnsupportedFormat for a PNG output, got %v", results[3].Err)
+			}
+			if _, err := os.Stat(specs[3].Path); !os.IsNotExist(err) {
+				t.Error("Expected no file for the failed output")
+			}
+		})
+	}
+}
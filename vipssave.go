@@ -0,0 +1,154 @@
+package nextgenimage
+
+/*
+#cgo pkg-config: vips
+#include <stdlib.h>
+#include <vips/vips.h>
+
+static VipsImage *new_image(const void *pixels, size_t size, int width, int height, int bands,
+	VipsBandFormat format, VipsInterpretation interpretation) {
+	VipsImage *image = vips_image_new_from_memory_copy(pixels, size, width, height, bands, format);
+	if (image != NULL) {
+		image->Type = interpretation;
+	}
+	return image;
+}
+
+static int write_to_buffer(VipsImage *image, const char *suffix, void **buf, size_t *size) {
+	return vips_image_write_to_buffer(image, suffix, buf, size, NULL);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// vipsFieldICC is the libvips name of the ICC profile blob
+const vipsFieldICC = "icc-profile-data"
+
+// saveBlobFields are the metadata blobs the savers embed
+var saveBlobFields = []string{vipsFieldICC, vipsFieldEXIF, vipsFieldXMP}
+
+// saveOptions builds a libvips saver suffix with options, such as ".webp[Q=80,preset=photo]"
+type saveOptions struct {
+	suffix  string
+	options []string
+}
+
+// set adds an option
+func (s *saveOptions) set(name string, value any) {
+	s.options = append(s.options, fmt.Sprintf("%s=%v", name, value))
+}
+
+// String returns the suffix with its options
+func (s *saveOptions) String() string {
+	return s.suffix + "[" + strings.Join(s.options, ",") + "]"
+}
+
+// saveImage encodes an image with the saver options given. govips only passes a fixed set
+// of saver options, so the pixels and kept metadata are copied into a new libvips image,
+// which is saved directly. Animated images keep their page height, delays and loop count.
+func saveImage(image *vips.ImageRef, options *saveOptions, stripMetadata bool) ([]byte, error) {
+	pixels, err := image.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(pixels) == 0 {
+		return nil, errors.New("image has no pixels")
+	}
+
+	out := C.new_image(unsafe.Pointer(&pixels[0]), C.size_t(len(pixels)),
+		C.int(image.Width()), C.int(image.Height()), C.int(image.Bands()),
+		C.VipsBandFormat(image.BandFormat()), C.VipsInterpretation(image.Interpretation()))
+	if out == nil {
+		return nil, vipsError()
+	}
+	defer C.g_object_unref(C.gpointer(out))
+
+	fields := image.ImageFields()
+	if !stripMetadata {
+		for _, field := range saveBlobFields {
+			if !slices.Contains(fields, field) {
+				continue
+			}
+			if blob := image.GetBlob(field); len(blob) > 0 {
+				setImageBlob(out, field, blob)
+			}
+		}
+	}
+	if err := setImageAnimation(out, image, fields); err != nil {
+		return nil, err
+	}
+
+	cSuffix := C.CString(options.String())
+	defer C.free(unsafe.Pointer(cSuffix))
+
+	var buf unsafe.Pointer
+	var size C.size_t
+	if C.write_to_buffer(out, cSuffix, &buf, &size) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(buf))
+
+	return C.GoBytes(buf, C.int(size)), nil
+}
+
+// setImageBlob copies a metadata blob to an image
+func setImageBlob(image *C.VipsImage, name string, blob []byte) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	C.vips_image_set_blob_copy(image, cName, unsafe.Pointer(&blob[0]), C.size_t(len(blob)))
+}
+
+// setImageInt sets an integer metadata field of an image
+func setImageInt(image *C.VipsImage, name string, value int) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	C.vips_image_set_int(image, cName, C.int(value))
+}
+
+// setImageAnimation copies the page height, frame delays and loop count of an animation
+func setImageAnimation(out *C.VipsImage, image *vips.ImageRef, fields []string) error {
+	pageHeight := image.PageHeight()
+	if pageHeight <= 0 || pageHeight >= image.Height() {
+		return nil
+	}
+	setImageInt(out, "page-height", pageHeight)
+
+	if slices.Contains(fields, "delay") {
+		delay, err := image.PageDelay()
+		if err != nil {
+			return err
+		}
+		if len(delay) > 0 {
+			cDelay := make([]C.int, len(delay))
+			for i, d := range delay {
+				cDelay[i] = C.int(d)
+			}
+			cName := C.CString("delay")
+			defer C.free(unsafe.Pointer(cName))
+			C.vips_image_set_array_int(out, cName, &cDelay[0], C.int(len(cDelay)))
+		}
+	}
+	if slices.Contains(fields, "loop") {
+		setImageInt(out, "loop", image.GetInt("loop"))
+	}
+	return nil
+}
+
+// vipsError returns and clears the libvips error buffer
+func vipsError() error {
+	message := strings.TrimSpace(C.GoString(C.vips_error_buffer()))
+	C.vips_error_clear()
+	if message == "" {
+		message = "unknown libvips error"
+	}
+	return errors.New(message)
+}
@@ -1,131 +1,320 @@
 package nextgenimage
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/davidbyttow/govips/v2/vips"
 )
 
 // ToWebP converts an image to WebP format
-func (c *Converter) ToWebP(inputPath, outputPath string) error {
-	// Check input file
-	inputInfo, err := os.Stat(inputPath)
+func (c *Converter) ToWebP(inputPath, outputPath string) (*ConversionResult, error) {
+	return c.ToWebPContext(context.Background(), inputPath, outputPath)
+}
+
+// ToWebPContext converts an image to WebP format, aborting when ctx is done.
+// On cancellation or deadline it returns ctx.Err() and leaves no file at outputPath.
+// The conversion stops at its next context check, and a libvips call already running
+// finishes in the background first.
+func (c *Converter) ToWebPContext(ctx context.Context, inputPath, outputPath string) (*ConversionResult, error) {
+	// Read input file
+	inputBuffer, err := os.ReadFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to stat input file: %w", err)
+		return nil, fmt.Errorf("failed to read input file: %w", err)
 	}
 
-	// Load image
-	image, err := vips.NewImageFromFile(inputPath)
+	outputBuffer, result, err := c.ToWebPBytesContext(ctx, inputBuffer)
 	if err != nil {
-		return fmt.Errorf("failed to load image: %w", NewFormatError(err))
+		return nil, err
 	}
-	defer image.Close()
 
-	// Auto-rotate based on EXIF orientation
-	err = image.AutoRotate()
+	if err := writeOutputFile(ctx, outputPath, outputBuffer); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ToWebPStream reads an image from r and writes the WebP encoded result to w.
+// Nothing is written to w when the conversion fails.
+func (c *Converter) ToWebPStream(r io.Reader, w io.Writer) error {
+	_, err := c.ToWebPStreamContext(context.Background(), r, w)
+	return err
+}
+
+// ToWebPStreamContext is ToWebPStream aborting when ctx is done, returning the same
+// ConversionResult as ToWebPContext
+func (c *Converter) ToWebPStreamContext(ctx context.Context, r io.Reader, w io.Writer) (*ConversionResult, error) {
+	inputBuffer, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	outputBuffer, result, err := c.ToWebPBytesContext(ctx, inputBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(outputBuffer); err != nil {
+		return nil, fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return result, nil
+}
+
+// ToWebPBytes converts an in-memory image to WebP format and returns the encoded bytes
+func (c *Converter) ToWebPBytes(inputBuffer []byte) ([]byte, error) {
+	outputBuffer, _, err := c.ToWebPBytesContext(context.Background(), inputBuffer)
+	return outputBuffer, err
+}
+
+// ToWebPBytesContext is ToWebPBytes aborting when ctx is done, returning the same
+// ConversionResult as ToWebPContext
+func (c *Converter) ToWebPBytesContext(ctx context.Context, inputBuffer []byte) ([]byte, *ConversionResult, error) {
+	var result *ConversionResult
+	outputBuffer, err := runWithContext(ctx, func() ([]byte, error) {
+		outputBuffer, r, err := c.toWebP(ctx, inputBuffer)
+		result = r
+		return outputBuffer, err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to auto-rotate: %w", NewFormatError(err))
+		return nil, nil, err
 	}
+	return outputBuffer, result, nil
+}
+
+// toWebP runs the WebP conversion pipeline, checking ctx between the expensive steps
+func (c *Converter) toWebP(ctx context.Context, inputBuffer []byte) ([]byte, *ConversionResult, error) {
+	start := time.Now()
 
-	// Detect input format using magic bytes
-	imgType, err := DetectImageType(inputPath)
+	input, err := c.decodeInput(ctx, inputBuffer)
 	if err != nil {
-		return fmt.Errorf("failed to detect image type: %w", err)
+		return nil, nil, err
+	}
+	defer input.Close()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
+	return c.encodeWebP(ctx, input, start)
+}
+
+// encodeWebP encodes a decoded input as WebP
+func (c *Converter) encodeWebP(ctx context.Context, input *decodedInput, start time.Time) ([]byte, *ConversionResult, error) {
+	inputBuffer, imgType, image := input.buf, input.imgType, input.image
+	stripMetadata := input.stripMetadata
 
-	if !imgType.IsSupported() {
-		return fmt.Errorf("unsupported image format: %s", imgType)
+	result := &ConversionResult{
+		InputType:    imgType,
+		OutputFormat: ImageTypeWebP,
+		InputSize:    int64(len(inputBuffer)),
+		Width:        image.Width(),
+		Height:       image.Height(),
+		Frames:       1,
+	}
+	if err := checkDimensions(result.Width, result.Height, webpMaxDimension); err != nil {
+		return nil, nil, err
 	}
 
-	var params *vips.WebpExportParams
+	// libvips only embeds a WebP ICC profile given as a file path
+	iccProfile := ""
+	if !stripMetadata {
+		var cleanup func()
+		var err error
+		iccProfile, cleanup, err = writeICCProfile(image)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer cleanup()
+	}
+
+	// Every export shares the metadata settings
+	base := newWebPExportParams()
+	base.StripMetadata = stripMetadata
+	base.IccProfile = iccProfile
+
 	var outputBuffer []byte
+	var err error
 
 	switch imgType {
 	case ImageTypeJPEG:
 		// JPEG to WebP: lossy conversion
-		params = vips.NewWebpExportParams()
+		params := *base
 		params.Quality = c.config.JPEGToWebP.Quality
 		params.Lossless = false
-		params.StripMetadata = true // Strip all metadata during export
+		if err := c.config.JPEGToWebP.apply(&params); err != nil {
+			return nil, nil, err
+		}
+		// Lossy WebP is always 4:2:0, sharp YUV keeps 4:4:4 colors from bleeding
+		if c.config.JPEGToWebP.Subsampling == SubsamplingMatchSource && input.subsampling == subsampling444 {
+			params.smartSubsample = true
+		}
 
-		outputBuffer, _, err = image.ExportWebp(params)
+		var quality int
+		outputBuffer, quality, result.SSIM, err = c.jpegToWebP(ctx, input, &params)
 		if err != nil {
-			return fmt.Errorf("failed to export webp: %w", NewFormatError(err))
+			return nil, nil, err
 		}
+		result.Mode = EncoderModeLossy
+		result.Quality = quality
 
 	case ImageTypePNG:
-		// PNG to WebP: lossless conversion
-		params = vips.NewWebpExportParams()
-		params.Lossless = true
-		params.StripMetadata = true // Strip all metadata during export
-
-		outputBuffer, _, err = image.ExportWebp(params)
-		if err != nil {
-			return fmt.Errorf("failed to export webp: %w", NewFormatError(err))
+		// PNG to WebP: lossless by default, optionally near-lossless or lossy
+		params := *base
+		if err := c.config.PNGToWebP.apply(&params); err != nil {
+			return nil, nil, err
 		}
 
-		// Try near-lossless if configured
-		if c.config.PNGToWebP.TryNearLossless {
-			nearLosslessParams := vips.NewWebpExportParams()
-			nearLosslessParams.Lossless = false
-			nearLosslessParams.NearLossless = true
-			nearLosslessParams.Quality = 100
-			nearLosslessParams.StripMetadata = true // Strip all metadata during export
-
-			nearLosslessBuffer, _, err2 := image.ExportWebp(nearLosslessParams)
-			if err2 == nil && len(nearLosslessBuffer) < len(outputBuffer) {
-				outputBuffer = nearLosslessBuffer
-			}
+		encoding, err := c.pngToWebP(ctx, image, &params)
+		if err != nil {
+			return nil, nil, err
 		}
+		outputBuffer = encoding.buf
+		result.Mode = encoding.mode
+		result.Quality = encoding.quality
+		result.SSIM = encoding.ssim
+		result.ContentClass = encoding.class
 
 	case ImageTypeGIF:
 		// GIF to WebP: animated webp conversion
-		// Load as animated image
-		animParams := vips.NewImportParams()
-		animParams.NumPages.Set(-1) // Load all pages/frames
-
-		animImage, err := vips.LoadImageFromFile(inputPath, animParams)
+		outputBuffer, err = c.gifToWebP(ctx, input, base, result)
 		if err != nil {
-			return fmt.Errorf("failed to load animated gif: %w", NewFormatError(err))
+			return nil, nil, err
 		}
-		defer animImage.Close()
 
-		// Export as animated WebP
-		params = vips.NewWebpExportParams()
-		params.Lossless = true // GIF frames are lossless
-		params.StripMetadata = true // Strip all metadata during export
+	}
+
+	// Check if output saves enough over the input
+	if err := c.checkSavings(len(inputBuffer), len(outputBuffer)); err != nil {
+		return nil, nil, err
+	}
+	if err := c.checkTargetSize(outputBuffer); err != nil {
+		return nil, nil, err
+	}
+
+	result.SourceQuality = input.quality
+	result.SourceSubsampling = input.subsampling
+	result.Subsampling = outputSubsampling(ImageTypeWebP, result.Mode, result.Quality, AVIFSubsamplingAuto)
+	result.OutputSize = int64(len(outputBuffer))
+	result.Elapsed = time.Since(start)
 
-		// Get page height for animation
-		pageHeight := animImage.PageHeight()
-		// Note: The current govips version doesn't expose animation delay/loop
-		// parameters directly. The animation will be preserved but timing
-		// may use defaults if pageHeight > 0 (animated image)
-		_ = pageHeight // Mark as intentionally unused
+	return outputBuffer, result, nil
+}
 
-		outputBuffer, _, err = animImage.ExportWebp(params)
+// jpegToWebP encodes a JPEG lossily and returns the output, effective quality and SSIM.
+// Adaptive quality caps the quality by the source quality.
+func (c *Converter) jpegToWebP(ctx context.Context, input *decodedInput, params *webpExportParams) ([]byte, int, float64, error) {
+	// Spend no more quality than the source still holds
+	maxQuality := webpMaxQuality
+	if c.config.JPEGToWebP.AdaptiveQuality && input.quality > 0 {
+		maxQuality = adaptiveQualityCap(input.quality, webpMaxQuality)
+		params.Quality = min(params.Quality, maxQuality)
+	}
+
+	return c.encodeLossy(ctx, input.image, params.Quality, 0, maxQuality, func(quality int) ([]byte, error) {
+		params.Quality = quality
+		buf, err := params.export(input.image)
 		if err != nil {
-			return fmt.Errorf("failed to export animated webp: %w", NewFormatError(err))
+			return nil, fmt.Errorf("failed to export webp: %w", newKindError(ErrEncodeFailed, err))
 		}
+		return buf, nil
+	})
+}
 
+// gifToWebP encodes a GIF as animated WebP, losslessly, lossily or both keeping the smaller,
+// and fills in the frame count, mode and quality of result
+func (c *Converter) gifToWebP(ctx context.Context, input *decodedInput, base *webpExportParams, result *ConversionResult) ([]byte, error) {
+	animImage, err := input.animatedImage()
+	if err != nil {
+		return nil, err
 	}
 
-	// Check if output is smaller than input
-	if int64(len(outputBuffer)) >= inputInfo.Size() {
-		return NewFormatError(fmt.Errorf("output file size (%d) is not smaller than input (%d)", len(outputBuffer), inputInfo.Size()))
+	// Read the exact frame delays and loop count from the GIF itself
+	anim, err := parseGIFAnimation(input.buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gif animation: %w", newKindError(ErrDecodeFailed, err))
+	}
+
+	// Get page height for animation
+	pageHeight := animImage.PageHeight()
+	if pageHeight > 0 {
+		result.Height = pageHeight
+		result.Frames = animImage.Height() / pageHeight
+	}
+
+	if err := setGIFTiming(animImage, anim, result.Frames); err != nil {
+		return nil, err
+	}
+
+	// libvips versions disagree on loop count semantics, so write the ANIM and ANMF
+	// chunks ourselves to make the timing match the GIF exactly
+	exportAnimation := func(params *webpExportParams) ([]byte, error) {
+		buf, err := params.export(animImage)
+		if err != nil {
+			return nil, err
+		}
+		return setWebPAnimation(buf, anim.webpLoopCount(), anim.frameDurations())
 	}
 
-	// Create output directory if needed
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	gifConfig := c.config.GIFToWebP
+	var outputBuffer []byte
+
+	// Export as lossless animated WebP, which keeps palette frames exact
+	if !gifConfig.Lossy || gifConfig.TryBoth {
+		params := *base
+		params.Lossless = true
+		if err := gifConfig.apply(&params); err != nil {
+			return nil, err
+		}
+
+		outputBuffer, err = exportAnimation(&params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export animated webp: %w", newKindError(ErrEncodeFailed, err))
+		}
+		result.Mode = EncoderModeLossless
 	}
 
-	// Write output file
-	if err := os.WriteFile(outputPath, outputBuffer, 0600); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+	// Export as lossy animated WebP, which suits photographic and dithered GIFs
+	if gifConfig.Lossy || gifConfig.TryBoth {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		params := *base
+		params.Lossless = false
+		params.Quality = gifConfig.Quality
+		params.alphaQuality = gifConfig.AlphaQuality
+		if err := gifConfig.apply(&params); err != nil {
+			return nil, err
+		}
+
+		lossyBuffer, err := exportAnimation(&params)
+
+		switch {
+		case err != nil && outputBuffer == nil:
+			return nil, fmt.Errorf("failed to export animated webp: %w", newKindError(ErrEncodeFailed, err))
+		case err == nil && (outputBuffer == nil || len(lossyBuffer) < len(outputBuffer)):
+			// Keep the smaller result when trying both
+			outputBuffer = lossyBuffer
+			result.Mode = EncoderModeLossy
+			result.Quality = params.Quality
+		}
 	}
 
+	return outputBuffer, nil
+}
+
+// setGIFTiming hands the GIF timing to the encoder so frame timestamps are computed from it
+func setGIFTiming(animImage *vips.ImageRef, anim *gifAnimation, frames int) error {
+	if frames <= 1 || frames != anim.frames {
+		return nil
+	}
+	if err := animImage.SetPageDelay(anim.frameDurations()); err != nil {
+		return fmt.Errorf("failed to set frame delays: %w", newKindError(ErrEncodeFailed, err))
+	}
+	animImage.SetInt("loop", anim.webpLoopCount())
 	return nil
 }
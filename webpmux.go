@@ -0,0 +1,149 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// WebP RIFF layout
+const (
+	riffHeaderSize     = 12 // "RIFF", size, "WEBP"
+	riffChunkHeader    = 8  // FourCC, size
+	webpAnimSize       = 6  // Background color(4), loop count(2)
+	webpAnmfHeaderSize = 16 // X(3) Y(3) width-1(3) height-1(3) duration(3) flags(1)
+	webpMaxDuration    = 1<<24 - 1
+)
+
+// webpChunk locates a top-level chunk inside a WebP file
+type webpChunk struct {
+	fourCC string
+	offset int // Offset of the payload
+	size   int // Payload size without padding
+}
+
+// readWebPChunks lists the top-level chunks of a WebP file
+func readWebPChunks(buf []byte) ([]webpChunk, error) {
+	if len(buf) < riffHeaderSize || !bytes.Equal(buf[:4], webpMagic) || !bytes.Equal(buf[8:12], webpType) {
+		return nil, fmt.Errorf("missing WebP RIFF header")
+	}
+
+	end := len(buf)
+	if riffSize := uint64(binary.LittleEndian.Uint32(buf[4:8])); riffSize < uint64(end-riffChunkHeader) {
+		end = riffChunkHeader + int(riffSize)
+	}
+
+	var chunks []webpChunk
+	pos := riffHeaderSize
+	for pos+riffChunkHeader <= end {
+		if uint64(binary.LittleEndian.Uint32(buf[pos+4:pos+8])) > uint64(end-pos-riffChunkHeader) {
+			return nil, fmt.Errorf("truncated WebP chunk at offset %d", pos)
+		}
+		size := int(binary.LittleEndian.Uint32(buf[pos+4 : pos+8]))
+		chunks = append(chunks, webpChunk{
+			fourCC: string(buf[pos : pos+4]),
+			offset: pos + riffChunkHeader,
+			size:   size,
+		})
+		// Chunks are padded to an even size
+		pos += riffChunkHeader + size + size&1
+	}
+
+	return chunks, nil
+}
+
+// webpAnimation holds the timing information of an animated WebP
+type webpAnimation struct {
+	loopCount int   // 0 means forever
+	durations []int // Per-frame duration in milliseconds
+}
+
+// readWebPAnimation reads the loop count and frame durations of an animated WebP.
+// It returns nil for still images.
+func readWebPAnimation(buf []byte) (*webpAnimation, error) {
+	chunks, err := readWebPChunks(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var anim *webpAnimation
+	for _, chunk := range chunks {
+		switch chunk.fourCC {
+		case "ANIM":
+			if chunk.size < webpAnimSize {
+				return nil, fmt.Errorf("truncated WebP ANIM chunk")
+			}
+			anim = &webpAnimation{loopCount: int(binary.LittleEndian.Uint16(buf[chunk.offset+4:]))}
+		case "ANMF":
+			if anim == nil || chunk.size < webpAnmfHeaderSize {
+				return nil, fmt.Errorf("invalid WebP ANMF chunk")
+			}
+			anim.durations = append(anim.durations, readUint24(buf[chunk.offset+12:]))
+		}
+	}
+
+	return anim, nil
+}
+
+// setWebPAnimation writes the loop count into the ANIM chunk and, when the frame count
+// matches, the per-frame durations into the ANMF chunks. The encoder may merge identical
+// consecutive frames, in which case the durations it computed are kept.
+// Still images are returned unchanged.
+func setWebPAnimation(buf []byte, loopCount int, durations []int) ([]byte, error) {
+	chunks, err := readWebPChunks(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []webpChunk
+	anim := -1
+	for i, chunk := range chunks {
+		switch chunk.fourCC {
+		case "ANIM":
+			anim = i
+		case "ANMF":
+			frames = append(frames, chunk)
+		}
+	}
+	if anim < 0 {
+		return buf, nil
+	}
+	if chunks[anim].size < webpAnimSize {
+		return nil, fmt.Errorf("truncated WebP ANIM chunk")
+	}
+
+	out := make([]byte, len(buf))
+	copy(out, buf)
+
+	if loopCount > gifMaxLoopCount {
+		loopCount = gifMaxLoopCount
+	}
+	binary.LittleEndian.PutUint16(out[chunks[anim].offset+4:], uint16(loopCount))
+
+	if len(frames) == len(durations) {
+		for i, frame := range frames {
+			if frame.size < webpAnmfHeaderSize {
+				return nil, fmt.Errorf("truncated WebP ANMF chunk")
+			}
+			duration := durations[i]
+			if duration > webpMaxDuration {
+				duration = webpMaxDuration
+			}
+			putUint24(out[frame.offset+12:], duration)
+		}
+	}
+
+	return out, nil
+}
+
+// readUint24 reads a little-endian 24-bit integer
+func readUint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// putUint24 writes a little-endian 24-bit integer
+func putUint24(b []byte, v int) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
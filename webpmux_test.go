@@ -0,0 +1,125 @@
+package nextgenimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildAnimatedWebP builds a minimal animated WebP container with empty frame payloads
+func buildAnimatedWebP(loopCount int, durations []int) []byte {
+	chunk := func(fourCC string, payload []byte) []byte {
+		var b bytes.Buffer
+		b.WriteString(fourCC)
+		_ = binary.Write(&b, binary.LittleEndian, uint32(len(payload)))
+		b.Write(payload)
+		if len(payload)%2 == 1 {
+			b.WriteByte(0)
+		}
+		return b.Bytes()
+	}
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	body.Write(chunk("VP8X", make([]byte, 10)))
+
+	anim := make([]byte, webpAnimSize)
+	binary.LittleEndian.PutUint16(anim[4:], uint16(loopCount))
+	body.Write(chunk("ANIM", anim))
+
+	for _, d := range durations {
+		anmf := make([]byte, webpAnmfHeaderSize+1) // Odd size to exercise padding
+		putUint24(anmf[12:], d)
+		body.Write(chunk("ANMF", anmf))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	_ = binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func TestReadWebPAnimation(t *testing.T) {
+	buf := buildAnimatedWebP(3, []int{40, 100, 200})
+
+	anim, err := readWebPAnimation(buf)
+	if err != nil {
+		t.Fatalf("readWebPAnimation failed: %v", err)
+	}
+	if anim == nil {
+		t.Fatal("Expected animation info")
+	}
+	if anim.loopCount != 3 {
+		t.Errorf("Expected loop count 3, got %d", anim.loopCount)
+	}
+	if len(anim.durations) != 3 || anim.durations[0] != 40 || anim.durations[1] != 100 || anim.durations[2] != 200 {
+		t.Errorf("Unexpected durations: %v", anim.durations)
+	}
+}
+
+func TestSetWebPAnimation(t *testing.T) {
+	buf := buildAnimatedWebP(0, []int{100, 100})
+
+	out, err := setWebPAnimation(buf, 4, []int{40, 250})
+	if err != nil {
+		t.Fatalf("setWebPAnimation failed: %v", err)
+	}
+
+	anim, err := readWebPAnimation(out)
+	if err != nil {
+		t.Fatalf("readWebPAnimation failed: %v", err)
+	}
+	if anim.loopCount != 4 {
+		t.Errorf("Expected loop count 4, got %d", anim.loopCount)
+	}
+	if anim.durations[0] != 40 || anim.durations[1] != 250 {
+		t.Errorf("Unexpected durations: %v", anim.durations)
+	}
+
+	// The input buffer is left untouched
+	orig, _ := readWebPAnimation(buf)
+	if orig.loopCount != 0 || orig.durations[0] != 100 {
+		t.Error("Input buffer was modified")
+	}
+
+	// Mismatched frame counts keep the encoder's durations
+	out, err = setWebPAnimation(buf, 1, []int{40, 40, 40})
+	if err != nil {
+		t.Fatalf("setWebPAnimation failed: %v", err)
+	}
+	anim, _ = readWebPAnimation(out)
+	if anim.loopCount != 1 || anim.durations[0] != 100 || anim.durations[1] != 100 {
+		t.Errorf("Unexpected animation after mismatched durations: %+v", anim)
+	}
+}
+
+func TestSetWebPAnimationStillImage(t *testing.T) {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	body.WriteString("VP8L")
+	_ = binary.Write(&body, binary.LittleEndian, uint32(2))
+	body.Write([]byte{0, 0})
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(body.Len()))
+	buf.Write(body.Bytes())
+
+	out, err := setWebPAnimation(buf.Bytes(), 3, []int{100})
+	if err != nil {
+		t.Fatalf("setWebPAnimation failed: %v", err)
+	}
+	if !bytes.Equal(out, buf.Bytes()) {
+		t.Error("Still image should be returned unchanged")
+	}
+
+	anim, err := readWebPAnimation(buf.Bytes())
+	if err != nil || anim != nil {
+		t.Errorf("Expected no animation for still image, got %+v, %v", anim, err)
+	}
+
+	if _, err := readWebPChunks([]byte("RIFF\x00\x00\x00\x00WEBX")); err == nil {
+		t.Error("Expected error for invalid header")
+	}
+}
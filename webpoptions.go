@@ -0,0 +1,112 @@
+package nextgenimage
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// WebPPreset selects a libwebp tuning preset for a kind of content
+type WebPPreset int
+
+const (
+	// WebPPresetDefault uses the libwebp defaults (default)
+	WebPPresetDefault WebPPreset = iota
+	WebPPresetPicture
+	WebPPresetPhoto
+	WebPPresetDrawing
+	WebPPresetIcon
+	WebPPresetText
+)
+
+// webpPresetNames are the libvips names of the presets
+var webpPresetNames = []string{"default", "picture", "photo", "drawing", "icon", "text"}
+
+// WebP encoder option ranges
+const (
+	webpMinEffort           = 1
+	webpMaxEffort           = 6
+	webpDefaultEffort       = 4
+	webpDefaultNearLossless = 100 // libvips' level before it was configurable
+	webpExactAlphaQuality   = 100 // libwebp alpha_q that keeps alpha exact
+)
+
+// WebPEncoderOptions tunes the WebP encoder, trading encode time against size
+type WebPEncoderOptions struct {
+	Effort         int        // Reduction effort (method) 1-6, higher is slower and smaller (default: 4)
+	Preset         WebPPreset // Default: WebPPresetDefault
+	SmartSubsample bool       // Sharp YUV conversion for lossy output, slower with less color bleeding (default: false)
+}
+
+// withDefaults fills in unset options
+func (o WebPEncoderOptions) withDefaults() WebPEncoderOptions {
+	if o.Effort == 0 {
+		o.Effort = webpDefaultEffort
+	}
+	return o
+}
+
+// apply validates the options and sets them on the export parameters
+func (o WebPEncoderOptions) apply(params *webpExportParams) error {
+	if o.Effort < webpMinEffort || o.Effort > webpMaxEffort {
+		return fmt.Errorf("webp effort %d is out of range %d-%d", o.Effort, webpMinEffort, webpMaxEffort)
+	}
+	if o.Preset < WebPPresetDefault || o.Preset > WebPPresetText {
+		return fmt.Errorf("unknown webp preset %d", o.Preset)
+	}
+
+	params.ReductionEffort = o.Effort
+	params.preset = o.Preset
+	params.smartSubsample = o.SmartSubsample
+	return nil
+}
+
+// webpExportParams adds the webpsave options govips doesn't pass to its export parameters
+type webpExportParams struct {
+	vips.WebpExportParams
+	preset         WebPPreset
+	smartSubsample bool
+	alphaQuality   int // Lossy alpha quality 1-100, 0 keeps alpha exact
+}
+
+// newWebPExportParams returns the govips defaults with exact alpha
+func newWebPExportParams() *webpExportParams {
+	return &webpExportParams{WebpExportParams: *vips.NewWebpExportParams()}
+}
+
+// direct returns true when an option needs the direct libvips save
+func (p *webpExportParams) direct() bool {
+	return p.preset != WebPPresetDefault || p.smartSubsample ||
+		(p.alphaQuality > 0 && p.alphaQuality < webpExactAlphaQuality)
+}
+
+// saveOptions returns the webpsave options for the direct libvips save. The ICC profile
+// travels as image metadata, so the profile path is not passed.
+func (p *webpExportParams) saveOptions() *saveOptions {
+	options := &saveOptions{suffix: ".webp"}
+	if p.Quality > 0 {
+		options.set("Q", p.Quality)
+	}
+	options.set("lossless", p.Lossless)
+	options.set("near_lossless", p.NearLossless)
+	options.set("reduction_effort", p.ReductionEffort)
+	if p.preset != WebPPresetDefault {
+		options.set("preset", webpPresetNames[p.preset])
+	}
+	if p.smartSubsample {
+		options.set("smart_subsample", true)
+	}
+	if p.alphaQuality > 0 {
+		options.set("alpha_q", p.alphaQuality)
+	}
+	return options
+}
+
+// export encodes the image as WebP, through govips unless an option needs the direct save
+func (p *webpExportParams) export(image *vips.ImageRef) ([]byte, error) {
+	if p.direct() {
+		return saveImage(image, p.saveOptions(), p.StripMetadata)
+	}
+	buf, _, err := image.ExportWebp(&p.WebpExportParams)
+	return buf, err
+}
@@ -0,0 +1,144 @@
+package nextgenimage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+func TestWebPEncoderOptionsApply(t *testing.T) {
+	if defaults := (WebPEncoderOptions{}).withDefaults(); defaults.Effort != 4 {
+		t.Errorf("Expected effort 4 by default, got %d", defaults.Effort)
+	}
+
+	params := &webpExportParams{}
+	if err := (WebPEncoderOptions{Effort: 6, Preset: WebPPresetText, SmartSubsample: true}).apply(params); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if params.ReductionEffort != 6 || params.preset != WebPPresetText || !params.smartSubsample {
+		t.Errorf("Expected reduction effort 6, text preset and smart subsampling, got %+v", params)
+	}
+
+	for _, options := range []WebPEncoderOptions{{Effort: 7}, {Effort: 4, Preset: 6}} {
+		if err := options.apply(&webpExportParams{}); err == nil {
+			t.Errorf("Expected an error for %+v", options)
+		}
+	}
+}
+
+func TestWebPSaveOptions(t *testing.T) {
+	params := &webpExportParams{WebpExportParams: vips.WebpExportParams{Quality: 75, ReductionEffort: 4}}
+	if params.direct() {
+		t.Error("Default options should export through govips")
+	}
+	params.alphaQuality = webpExactAlphaQuality
+	if params.direct() {
+		t.Error("Exact alpha should export through govips")
+	}
+
+	tests := []struct {
+		preset         WebPPreset
+		smartSubsample bool
+		alphaQuality   int
+		expected       string
+	}{
+		{WebPPresetPhoto, false, 0, ".webp[Q=75,lossless=false,near_lossless=false,reduction_effort=4,preset=photo]"},
+		{WebPPresetDefault, true, 0, ".webp[Q=75,lossless=false,near_lossless=false,reduction_effort=4,smart_subsample=true]"},
+		{WebPPresetIcon, false, 40, ".webp[Q=75,lossless=false,near_lossless=false,reduction_effort=4,preset=icon,alpha_q=40]"},
+	}
+	for _, tt := range tests {
+		params.preset, params.smartSubsample, params.alphaQuality = tt.preset, tt.smartSubsample, tt.alphaQuality
+		if !params.direct() {
+			t.Errorf("Expected a direct save for %+v", tt)
+		}
+		if got := params.saveOptions().String(); got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestWebPEncoderOptions(t *testing.T) {
+	inputPath := "testdata/test_original.png"
+	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+		t.Skip("Test file not found:", inputPath)
+		return
+	}
+	tempDir := t.TempDir()
+
+	convert := func(config ConverterConfig) *ConversionResult {
+		t.Helper()
+		result, err := NewConverter(config).ToWebP(inputPath, filepath.Join(tempDir, "test.webp"))
+		if err != nil {
+			t.Fatalf("Conversion failed: %v", err)
+		}
+		return result
+	}
+
+	// More effort never makes lossless output larger
+	fast := ConverterConfig{}
+	fast.PNGToWebP.Effort = 1
+	slow := ConverterConfig{}
+	slow.PNGToWebP.Effort = 6
+	fastResult, slowResult := convert(fast), convert(slow)
+	if slowResult.OutputSize > fastResult.OutputSize {
+		t.Errorf("Effort 6 (%d bytes) is larger than effort 1 (%d bytes)", slowResult.OutputSize, fastResult.OutputSize)
+	}
+
+	// A lower near-lossless level is picked when it beats lossless
+	nearLossless := ConverterConfig{}
+	nearLossless.PNGToWebP.TryNearLossless = true
+	nearLossless.PNGToWebP.NearLosslessLevel = 40
+	result := convert(nearLossless)
+	if result.Mode == EncoderModeNearLossless && result.Quality != 40 {
+		t.Errorf("Expected near-lossless level 40 in the result, got %d", result.Quality)
+	}
+	t.Logf("Effort 1: %d bytes, effort 6: %d bytes, near-lossless 40: %d bytes (%s)",
+		fastResult.OutputSize, slowResult.OutputSize, result.OutputSize, result.Mode)
+}
+
+func TestWebPDirectSave(t *testing.T) {
+	jpegPath := "testdata/test_original.jpg"
+	alphaPath := "testdata/png/alpha_semitransparent.png"
+	for _, path := range []string{jpegPath, alphaPath} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Skip("Test file not found:", path)
+		}
+	}
+	tempDir := t.TempDir()
+
+	// A preset and sharp YUV conversion go straight to libvips
+	config := ConverterConfig{}
+	config.JPEGToWebP.Preset = WebPPresetPhoto
+	config.JPEGToWebP.SmartSubsample = true
+	result, err := NewConverter(config).ToWebP(jpegPath, filepath.Join(tempDir, "photo.webp"))
+	if err != nil {
+		t.Fatalf("JPEG to WebP with a preset failed: %v", err)
+	}
+	if result.Mode != EncoderModeLossy || result.OutputSize == 0 {
+		t.Errorf("Expected lossy output, got %s with %d bytes", result.Mode, result.OutputSize)
+	}
+
+	// A lower alpha quality never makes lossy output larger
+	convert := func(alphaQuality int) *ConversionResult {
+		t.Helper()
+		config := ConverterConfig{}
+		config.PNGToWebP.Lossy = true
+		config.PNGToWebP.AlphaQuality = alphaQuality
+		result, err := NewConverter(config).ToWebP(alphaPath, filepath.Join(tempDir, "alpha.webp"))
+		if errors.As(err, new(*FormatError)) {
+			t.Skipf("Lossy output is not smaller than the PNG: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("PNG to WebP at alpha quality %d failed: %v", alphaQuality, err)
+		}
+		return result
+	}
+	exact, coarse := convert(100), convert(10)
+	if coarse.OutputSize > exact.OutputSize {
+		t.Errorf("Alpha quality 10 (%d bytes) is larger than 100 (%d bytes)", coarse.OutputSize, exact.OutputSize)
+	}
+	t.Logf("Alpha quality 100: %d bytes, 10: %d bytes", exact.OutputSize, coarse.OutputSize)
+}